@@ -0,0 +1,603 @@
+// vrrsh 是驱动一次运行中的 VRR 仿真的交互式控制台：查看节点的 pset、vset 和路由表，
+// 发送数据、追踪路径，停止、重启节点，加入、离开子网，切断、恢复链路，并在虚拟时钟下逐步推进时间。
+//
+//	vrrsh -gen ring:8 -virtual -seed 1
+//	vrr> step 10
+//	vrr> vset 8082
+//	vrr> trace on
+//	vrr> send 8085 8083 hello
+//	vrr> step 1
+//	vrr> trace 1a2b3c4d5e6f7081
+//	vrr> kill 8082
+//	vrr> join 9000 1 2
+//	vrr> leave 8085
+//
+// 命令也可以从管道读取，每行一条，# 开头的行为注释。
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// send 命令使用的端口，所有节点在该端口上打印收到的数据
+const shellPort = 0
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("vrrsh", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	topology := fs.String("topology", "", "topology file (JSON)")
+	gen := fs.String("gen", "", "generated topology: line:N, ring:N, grid:WxH or random:N:RADIUS")
+	virtual := fs.Bool("virtual", false, "run on a virtual clock (overrides the topology file)")
+	seed := fs.Int64("seed", 0, "random seed (overrides the topology file, 0 keeps it)")
+	logSpec := fs.String("log", "warn", `log levels, e.g. "info,hello=debug,routing=warn"`)
+	prompt := fs.String("prompt", "vrr> ", "prompt printed before each command")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var topo *network.Topology
+	var err error
+	switch {
+	case *topology != "" && *gen != "":
+		err = errors.New("-topology and -gen are mutually exclusive")
+	case *topology != "":
+		topo, err = network.LoadTopology(*topology)
+	case *gen != "":
+		if topo, err = network.GenerateTopology(*gen, 8081); err == nil {
+			topo.Latency = 20
+		}
+	default:
+		err = errors.New("one of -topology or -gen is required")
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "vrrsh: %v\n", err)
+		return 2
+	}
+	if *virtual {
+		topo.Virtual = true
+	}
+	if *seed != 0 {
+		topo.Seed = *seed
+	}
+
+	levels, err := vrr.ParseLogLevels(*logSpec)
+	if err != nil {
+		fmt.Fprintf(stderr, "vrrsh: %v\n", err)
+		return 2
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: levels.Level(vrr.LOG_NETWORK)})))
+	sim, err := topo.Build()
+	if err != nil {
+		fmt.Fprintf(stderr, "vrrsh: %v\n", err)
+		return 2
+	}
+	logger := vrr.NewLogger(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug})), levels)
+	sim.SetLogger(logger)
+
+	sh := newShell(sim, stdout, logger)
+	sim.Start()
+	defer sim.Stop()
+	sh.loop(stdin, *prompt)
+	return 0
+}
+
+// shell 保存控制台的状态
+type shell struct {
+	sim     *network.Sim
+	logger  *vrr.Logger
+	out     io.Writer
+	outLock sync.Mutex // 真实时钟下节点在自己的 goroutine 中打印收到的数据
+	start   time.Time
+	tracer  *network.TraceCollector // nil 表示追踪关闭
+	killed  map[uint32][]uint32     // 被 kill 的节点及其之前所在的子网
+}
+
+// command 是一条控制台命令
+type command struct {
+	usage string
+	help  string
+	run   func(sh *shell, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"help":    {"help", "list commands", (*shell).cmdHelp},
+		"nodes":   {"nodes", "summary of all nodes", (*shell).cmdNodes},
+		"node":    {"node ID", "pset, pset state, vset and routing table of a node", (*shell).cmdNode},
+		"pset":    {"pset ID", "physical neighbors of a node", (*shell).cmdPset},
+		"vset":    {"vset ID", "virtual neighbors of a node", (*shell).cmdVset},
+		"routes":  {"routes ID", "routing table of a node", (*shell).cmdRoutes},
+		"ring":    {"ring", "check the virtual ring against the ideal ring", (*shell).cmdRing},
+		"stats":   {"stats", "network message statistics", (*shell).cmdStats},
+		"send":    {"send SRC DST TEXT...", "send data from SRC to DST", (*shell).cmdSend},
+		"kill":    {"kill ID", "stop a node and remove it from the network", (*shell).cmdKill},
+		"restart": {"restart ID", "clear a node's state and start it in its previous subnets", (*shell).cmdRestart},
+		"join":    {"join ID SUBNET...", "add a node to subnets, creating and starting it if it does not exist", (*shell).cmdJoin},
+		"leave":   {"leave ID [SUBNET...]", "remove a node from subnets, or leave the network gracefully", (*shell).cmdLeave},
+		"cut":     {"cut A B", "cut the link between A and B in both directions", (*shell).cmdCut},
+		"heal":    {"heal A B", "restore the link between A and B", (*shell).cmdHeal},
+		"trace":   {"trace [on|off|ID]", "turn tracing of sent data on or off, list traces or show one", (*shell).cmdTrace},
+		"step":    {"step [DURATION]", "run the simulation for DURATION (seconds or e.g. 500ms, default 1s)", (*shell).cmdStep},
+		"time":    {"time", "simulated time since start", (*shell).cmdTime},
+		"dot":     {"dot [FILE]", "write a Graphviz DOT graph to FILE or print it", (*shell).cmdDOT},
+	}
+}
+
+func newShell(sim *network.Sim, out io.Writer, logger *vrr.Logger) *shell {
+	sh := &shell{sim: sim, logger: logger, out: out, start: sim.Clock.Now(), killed: make(map[uint32][]uint32)}
+	for _, n := range sim.Nodes {
+		sh.handle(n)
+	}
+	return sh
+}
+
+// loop 逐行读取并执行命令，直到输入结束或 quit
+func (sh *shell) loop(in io.Reader, prompt string) {
+	scanner := bufio.NewScanner(in)
+	for {
+		sh.printf("%s", prompt)
+		if !scanner.Scan() {
+			sh.printf("\n")
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if fields[0] == "quit" || fields[0] == "exit" {
+			return
+		}
+		cmd, ok := commands[fields[0]]
+		if !ok {
+			sh.printf("error: unknown command %q, try help\n", fields[0])
+			continue
+		}
+		if err := cmd.run(sh, fields[1:]); err != nil {
+			sh.printf("error: %v\n", err)
+		}
+	}
+}
+
+func (sh *shell) printf(format string, args ...any) {
+	sh.outLock.Lock()
+	defer sh.outLock.Unlock()
+	fmt.Fprintf(sh.out, format, args...)
+}
+
+// handle 在节点的 shellPort 上打印收到的数据
+func (sh *shell) handle(n *vrr.Node) {
+	id := n.ID
+	n.Handle(shellPort, func(d vrr.Delivery) {
+		sh.printf("[%v] %d received from %d (%d hops): %s\n", sh.elapsed(), id, d.Src, d.Hops, d.Data)
+	})
+}
+
+func (sh *shell) elapsed() time.Duration {
+	return sh.sim.Clock.Now().Sub(sh.start)
+}
+
+// -----------------------查询-----------------------
+
+func (sh *shell) cmdHelp(args []string) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintf(tw, "quit\texit the shell\n")
+	tw.Flush()
+	sh.printf("%s", b.String())
+	return nil
+}
+
+func (sh *shell) cmdNodes(args []string) error {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATE\tACTIVE\tRING\tSUBNETS\tPSET\tVSET\tROUTES")
+	for _, n := range sh.sim.Nodes {
+		state := "running"
+		if _, ok := sh.killed[n.ID]; ok {
+			state = "killed"
+		} else if n.Stopped() {
+			state = "stopped"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%v\t%d\t%v\t%d\t%d\t%d\n", n.ID, state, n.IsActive(), n.GetRingID(), sh.sim.Network.GetSubnets(n.ID),
+			len(n.PsetManager.GetAll()), len(n.VsetManager.GetAll()), len(n.RoutingTable.Routes()))
+	}
+	tw.Flush()
+	sh.printf("%s", b.String())
+	return nil
+}
+
+func (sh *shell) cmdNode(args []string) error {
+	n, err := sh.node(args, 1)
+	if err != nil {
+		return err
+	}
+	sh.printf("Node %d (active %v, ring %d, subnets %v)\n", n.ID, n.IsActive(), n.GetRingID(), sh.sim.Network.GetSubnets(n.ID))
+	sh.printf("%s\n%s\n%s\n%s\n", n.PsetManager.String(), n.PsetStateManager.String(), n.VsetManager.String(), n.RoutingTable.String())
+	return nil
+}
+
+func (sh *shell) cmdPset(args []string) error {
+	n, err := sh.node(args, 1)
+	if err != nil {
+		return err
+	}
+	sh.printf("%s\n%s\n", n.PsetManager.String(), n.PsetStateManager.String())
+	return nil
+}
+
+func (sh *shell) cmdVset(args []string) error {
+	n, err := sh.node(args, 1)
+	if err != nil {
+		return err
+	}
+	sh.printf("%s\n", n.VsetManager.String())
+	return nil
+}
+
+func (sh *shell) cmdRoutes(args []string) error {
+	n, err := sh.node(args, 1)
+	if err != nil {
+		return err
+	}
+	sh.printf("%s\n", n.RoutingTable.String())
+	return nil
+}
+
+func (sh *shell) cmdRing(args []string) error {
+	sh.printf("%s\n", sh.sim.Network.CheckRing())
+	return nil
+}
+
+func (sh *shell) cmdStats(args []string) error {
+	stats := sh.sim.Network.Snapshot().Stats
+	sh.printf("messages: %d sent, %d dropped\n", stats.Total, stats.Dropped)
+	sh.printf("\ttransmissions: %s\n\tdrops: %s\n", network.FormatCounts(stats.Transmissions), network.FormatCounts(stats.Drops))
+	return nil
+}
+
+func (sh *shell) cmdTime(args []string) error {
+	sh.printf("%v\n", sh.elapsed())
+	return nil
+}
+
+func (sh *shell) cmdDOT(args []string) error {
+	if len(args) == 0 {
+		var b strings.Builder
+		if err := sh.sim.Network.WriteDOT(&b, network.DefaultDOTOptions()); err != nil {
+			return err
+		}
+		sh.printf("%s", b.String())
+		return nil
+	}
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	if err := sh.sim.Network.WriteDOT(f, network.DefaultDOTOptions()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	sh.printf("wrote %s\n", args[0])
+	return nil
+}
+
+// -----------------------操作-----------------------
+
+func (sh *shell) cmdSend(args []string) error {
+	if len(args) < 3 {
+		return errors.New("usage: " + commands["send"].usage)
+	}
+	src, err := sh.node(args[:1], 1)
+	if err != nil {
+		return err
+	}
+	dst, err := parseID(args[1])
+	if err != nil {
+		return err
+	}
+	data := []byte(strings.Join(args[2:], " "))
+	if sh.tracer == nil {
+		if !src.SendDataPort(dst, shellPort, data) {
+			return fmt.Errorf("node %d has no route to %d", src.ID, dst)
+		}
+		sh.printf("sent %d -> %d\n", src.ID, dst)
+		return nil
+	}
+	traceID, ok := src.SendDataTraced(dst, shellPort, data)
+	if !ok {
+		return fmt.Errorf("node %d has no route to %d (trace %016x)", src.ID, dst, traceID)
+	}
+	sh.printf("sent %d -> %d, trace %016x\n", src.ID, dst, traceID)
+	return nil
+}
+
+func (sh *shell) cmdKill(args []string) error {
+	n, err := sh.node(args, 1)
+	if err != nil {
+		return err
+	}
+	if _, ok := sh.killed[n.ID]; ok {
+		return fmt.Errorf("node %d is already killed", n.ID)
+	}
+	subnets := sh.sim.Network.GetSubnets(n.ID)
+	n.Stop()
+	sh.sim.Network.UnregisterNode(n.ID)
+	sh.killed[n.ID] = subnets
+	sh.printf("node %d killed (subnets %v)\n", n.ID, subnets)
+	return nil
+}
+
+func (sh *shell) cmdRestart(args []string) error {
+	n, err := sh.node(args, 1)
+	if err != nil {
+		return err
+	}
+	subnets, ok := sh.killed[n.ID]
+	if !ok {
+		subnets = sh.sim.Network.GetSubnets(n.ID)
+	}
+	n.Stop()
+	n.Reset()
+	sh.sim.Network.JoinSubnets(n, subnets...)
+	n.Start()
+	delete(sh.killed, n.ID)
+	sh.printf("node %d restarted in subnets %v\n", n.ID, subnets)
+	return nil
+}
+
+func (sh *shell) cmdJoin(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: " + commands["join"].usage)
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	subnets, err := parseIDs(args[1:])
+	if err != nil {
+		return err
+	}
+	if n := sh.sim.Node(id); n != nil {
+		if _, ok := sh.killed[id]; ok {
+			return fmt.Errorf("node %d is killed, use restart", id)
+		}
+		sh.sim.Network.JoinSubnets(n, subnets...)
+		sh.printf("node %d joined subnets %v\n", id, subnets)
+		return nil
+	}
+
+	n, err := sh.sim.AddNode(id, subnets...)
+	if err != nil {
+		return err
+	}
+	n.SetLogger(sh.logger)
+	if sh.tracer != nil {
+		n.SetTracer(sh.tracer)
+	}
+	sh.handle(n)
+	n.Start()
+	sh.printf("node %d created in subnets %v\n", id, subnets)
+	return nil
+}
+
+func (sh *shell) cmdLeave(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: " + commands["leave"].usage)
+	}
+	n, err := sh.node(args[:1], 1)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		if n.Stopped() {
+			return fmt.Errorf("node %d is not running", n.ID)
+		}
+		n.Leave()
+		sh.printf("node %d left the network\n", n.ID)
+		return nil
+	}
+	subnets, err := parseIDs(args[1:])
+	if err != nil {
+		return err
+	}
+	sh.sim.Network.UnregisterNodeFromSubnets(n.ID, subnets...)
+	sh.printf("node %d left subnets %v\n", n.ID, subnets)
+	return nil
+}
+
+func (sh *shell) cmdCut(args []string) error {
+	a, b, err := sh.link(args)
+	if err != nil {
+		return err
+	}
+	sh.sim.Network.CutLink(a, b)
+	sh.printf("link %d <-> %d cut\n", a, b)
+	return nil
+}
+
+func (sh *shell) cmdHeal(args []string) error {
+	a, b, err := sh.link(args)
+	if err != nil {
+		return err
+	}
+	sh.sim.Network.HealLink(a, b)
+	sh.printf("link %d <-> %d healed\n", a, b)
+	return nil
+}
+
+func (sh *shell) cmdTrace(args []string) error {
+	// 网络和节点的追踪钩子都由锁保护，实时时钟下也可以在节点运行时开关追踪
+	switch {
+	case len(args) == 1 && args[0] == "on":
+		if sh.tracer == nil {
+			sh.tracer = network.NewTraceCollector()
+			sh.sim.SetTracer(sh.tracer)
+		}
+		sh.printf("tracing on\n")
+	case len(args) == 1 && args[0] == "off":
+		sh.tracer = nil
+		sh.sim.SetTracer(nil)
+		sh.printf("tracing off\n")
+	case len(args) == 0:
+		if sh.tracer == nil {
+			return errors.New("tracing is off")
+		}
+		for _, id := range sh.tracer.IDs() {
+			t, _ := sh.tracer.Trace(id)
+			sh.printf("%016x  %d -> %d  %s\n", id, t.Src, t.Dst, traceStatus(t))
+		}
+	case len(args) == 1:
+		if sh.tracer == nil {
+			return errors.New("tracing is off")
+		}
+		id, err := strconv.ParseUint(args[0], 16, 64)
+		if err != nil {
+			return fmt.Errorf("invalid trace id %q", args[0])
+		}
+		t, ok := sh.tracer.Trace(id)
+		if !ok {
+			return fmt.Errorf("no trace %016x", id)
+		}
+		sh.printf("trace %016x  %d -> %d  %s\n", id, t.Src, t.Dst, traceStatus(t))
+		sh.printf("path %v\n", t.Path())
+		for i, hop := range t.Hops {
+			if hop.Received.IsZero() {
+				sh.printf("\t%d. %d -> %d  not received\n", i+1, hop.From, hop.To)
+				continue
+			}
+			sh.printf("\t%d. %d -> %d  %v\n", i+1, hop.From, hop.To, hop.Latency)
+		}
+		if stretch, ok := sh.sim.Network.Stretch(t); ok {
+			sh.printf("stretch %.2f\n", stretch)
+		}
+	default:
+		return errors.New("usage: " + commands["trace"].usage)
+	}
+	return nil
+}
+
+func (sh *shell) cmdStep(args []string) error {
+	d := time.Second
+	if len(args) > 0 {
+		var err error
+		if d, err = parseDuration(args[0]); err != nil {
+			return err
+		}
+	}
+	sh.sim.Run(d)
+	sh.printf("t=%v\n", sh.elapsed())
+	return nil
+}
+
+// -----------------------辅助函数-----------------------
+
+// node 检查参数个数并返回第一个参数对应的节点
+func (sh *shell) node(args []string, want int) (*vrr.Node, error) {
+	if len(args) != want {
+		return nil, fmt.Errorf("expected %d argument(s)", want)
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return nil, err
+	}
+	n := sh.sim.Node(id)
+	if n == nil {
+		return nil, fmt.Errorf("unknown node %d", id)
+	}
+	return n, nil
+}
+
+// link 返回两个参数对应的已知节点ID
+func (sh *shell) link(args []string) (uint32, uint32, error) {
+	if len(args) != 2 {
+		return 0, 0, errors.New("expected 2 node ids")
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, id := range ids {
+		if sh.sim.Node(id) == nil {
+			return 0, 0, fmt.Errorf("unknown node %d", id)
+		}
+	}
+	return ids[0], ids[1], nil
+}
+
+func parseID(s string) (uint32, error) {
+	id, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid id %q", s)
+	}
+	return uint32(id), nil
+}
+
+func parseIDs(args []string) ([]uint32, error) {
+	ids := make([]uint32, 0, len(args))
+	for _, a := range args {
+		id, err := parseID(a)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseDuration 解析 Go 时长，不带单位的数字按秒计
+func parseDuration(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		if secs <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func traceStatus(t *network.Trace) string {
+	switch {
+	case t.Delivered:
+		return fmt.Sprintf("delivered in %d hops, %v", len(t.Hops), t.Latency())
+	case t.Dropped:
+		return fmt.Sprintf("dropped at %d: %s", t.DropNode, t.DropReason)
+	}
+	return fmt.Sprintf("in flight, %d hops so far", len(t.Hops))
+}
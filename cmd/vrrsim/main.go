@@ -0,0 +1,423 @@
+// vrrsim 根据拓扑文件(或生成的拓扑)运行一次 VRR 仿真，可以注入数据流量和场景文件中的故障，
+// 结束时输出每个节点的 pset、vset、路由表和网络统计。虚拟环收敛时退出状态为 0，
+// 未收敛为 1，参数或运行错误为 2。
+//
+//	vrrsim -topology test/testdata/bootstrap.json -duration 60s
+//	vrrsim -gen ring:16 -virtual -seed 1 -traffic 5 -format json -o result.json
+//	vrrsim -gen grid:4x4 -scenario faults.json -dot ring.dot -pcap trace.pcapng
+//	vrrsim -gen random:30:0.3 -virtual -admin :8080 -step 10ms
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 数据流量使用的端口
+const trafficPort = 9
+
+type options struct {
+	topology  string
+	gen       string
+	duration  time.Duration
+	virtual   bool
+	seed      int64
+	scenario  string
+	traffic   float64
+	start     time.Duration
+	size      int
+	format    string
+	output    string
+	dot       string
+	pcap      string
+	admin     string
+	step      time.Duration
+	logLevels string
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	var opts options
+	fs := flag.NewFlagSet("vrrsim", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.topology, "topology", "", "topology file (JSON)")
+	fs.StringVar(&opts.gen, "gen", "", "generated topology: line:N, ring:N, grid:WxH or random:N:RADIUS")
+	fs.DurationVar(&opts.duration, "duration", 30*time.Second, "simulated time to run")
+	fs.BoolVar(&opts.virtual, "virtual", false, "run on a virtual clock (overrides the topology file)")
+	fs.Int64Var(&opts.seed, "seed", 0, "random seed (overrides the topology file, 0 keeps it)")
+	fs.StringVar(&opts.scenario, "scenario", "", "fault injection scenario file (JSON)")
+	fs.Float64Var(&opts.traffic, "traffic", 0, "data packets per second between random node pairs")
+	fs.DurationVar(&opts.start, "traffic-start", 10*time.Second, "when to start sending traffic")
+	fs.IntVar(&opts.size, "traffic-size", 32, "payload size of each data packet in bytes")
+	fs.StringVar(&opts.format, "format", "text", "output format: text or json")
+	fs.StringVar(&opts.output, "o", "", "write the result to this file instead of stdout")
+	fs.StringVar(&opts.dot, "dot", "", "write a Graphviz DOT graph of the final state to this file")
+	fs.StringVar(&opts.pcap, "pcap", "", "capture all messages to this pcapng file")
+	fs.StringVar(&opts.admin, "admin", "", "serve the HTTP admin interface on this address while running")
+	fs.DurationVar(&opts.step, "step", 100*time.Millisecond, "how often to check the ring (and yield to the admin interface)")
+	fs.StringVar(&opts.logLevels, "log", "warn", `log levels, e.g. "info,hello=debug,routing=warn"`)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	converged, err := simulate(opts, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "vrrsim: %v\n", err)
+		return 2
+	}
+	if !converged {
+		return 1
+	}
+	return 0
+}
+
+// Result 是一次仿真的输出
+type Result struct {
+	Duration    string            `json:"duration"`
+	Virtual     bool              `json:"virtual"`
+	Converged   bool              `json:"converged"`
+	ConvergedAt string            `json:"converged_at,omitempty"` // 第一次检查到收敛的时刻
+	Traffic     *TrafficStats     `json:"traffic,omitempty"`
+	Timeline    []TimelineEntry   `json:"timeline,omitempty"`
+	Snapshot    network.Snapshot  `json:"snapshot"`
+	nodes       []*vrr.Node       // 文本输出使用
+	timeline    *network.Timeline // 文本输出使用
+}
+
+// TrafficStats 统计注入的数据包
+type TrafficStats struct {
+	Sent      int     `json:"sent"`
+	NoRoute   int     `json:"no_route"` // 源节点没有路由，未发出
+	Delivered int     `json:"delivered"`
+	Dropped   int     `json:"dropped"`
+	Latency   string  `json:"avg_latency"`
+	Stretch   float64 `json:"avg_stretch"`
+}
+
+// TimelineEntry 是场景时间线上的一条记录
+type TimelineEntry struct {
+	At     string `json:"at"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+func simulate(opts options, stdout, stderr io.Writer) (bool, error) {
+	if opts.format != "text" && opts.format != "json" {
+		return false, fmt.Errorf("unknown format %q", opts.format)
+	}
+	topo, err := loadTopology(opts)
+	if err != nil {
+		return false, err
+	}
+	if opts.virtual {
+		topo.Virtual = true
+	}
+	if opts.seed != 0 {
+		topo.Seed = opts.seed
+	}
+
+	levels, err := vrr.ParseLogLevels(opts.logLevels)
+	if err != nil {
+		return false, err
+	}
+	// Build 在设置日志之前注册节点，这些日志经过默认日志输出
+	slog.SetDefault(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: levels.Level(vrr.LOG_NETWORK)})))
+	sim, err := topo.Build()
+	if err != nil {
+		return false, err
+	}
+	handler := slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+	sim.SetLogger(vrr.NewLogger(slog.New(handler), levels))
+
+	var sc *network.Scenario
+	if opts.scenario != "" {
+		if sc, err = network.LoadScenario(opts.scenario); err != nil {
+			return false, err
+		}
+	}
+
+	if opts.pcap != "" {
+		f, err := os.Create(opts.pcap)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		capture, err := network.NewPcapWriter(f)
+		if err != nil {
+			return false, err
+		}
+		sim.Network.SetCapture(capture)
+		defer sim.Network.SetCapture(nil)
+	}
+
+	admin := network.NewAdminServer(sim.Network)
+	if opts.admin != "" {
+		srv := &http.Server{Addr: opts.admin, Handler: admin}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Fprintf(stderr, "vrrsim: admin: %v\n", err)
+			}
+		}()
+		defer srv.Close()
+	}
+
+	var traffic *trafficGen
+	if opts.traffic > 0 {
+		traffic = newTrafficGen(sim, opts, topo.Seed)
+	}
+
+	result := &Result{Duration: opts.duration.String(), Virtual: topo.Virtual, nodes: sim.Nodes}
+	start := sim.Clock.Now()
+	admin.Do(func() {
+		if sc != nil {
+			result.timeline, err = sim.Schedule(sc)
+		}
+		if traffic != nil {
+			traffic.schedule()
+		}
+		sim.Start()
+	})
+	if err != nil {
+		return false, err
+	}
+	defer sim.Stop()
+
+	// 按 step 推进仿真并检查虚拟环，记录第一次收敛的时刻
+	for elapsed := time.Duration(0); elapsed < opts.duration; {
+		step := min(opts.step, opts.duration-elapsed)
+		admin.Do(func() {
+			sim.Run(step)
+			elapsed = sim.Clock.Now().Sub(start)
+			if result.ConvergedAt == "" && sim.Network.CheckRing().OK() {
+				result.ConvergedAt = elapsed.String()
+			}
+		})
+	}
+
+	admin.Do(func() {
+		result.Snapshot = sim.Network.Snapshot()
+		if traffic != nil {
+			result.Traffic = traffic.stats()
+		}
+		if opts.dot != "" {
+			err = writeDOT(sim.Network, opts.dot)
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	result.Converged = result.Snapshot.Ring.OK
+	if result.timeline != nil {
+		for _, e := range result.timeline.Entries() {
+			result.Timeline = append(result.Timeline, TimelineEntry{At: e.At.String(), Kind: e.Kind, Detail: e.Detail})
+		}
+	}
+
+	out := stdout
+	if opts.output != "" {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		out = f
+	}
+	if opts.format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(result)
+	} else {
+		err = writeText(out, result)
+	}
+	return result.Converged, err
+}
+
+// loadTopology 读取拓扑文件或按 -gen 生成拓扑
+func loadTopology(opts options) (*network.Topology, error) {
+	switch {
+	case opts.topology != "" && opts.gen != "":
+		return nil, errors.New("-topology and -gen are mutually exclusive")
+	case opts.topology != "":
+		return network.LoadTopology(opts.topology)
+	case opts.gen != "":
+		topo, err := network.GenerateTopology(opts.gen, 8081)
+		if err != nil {
+			return nil, err
+		}
+		topo.Latency = 20
+		return topo, nil
+	}
+	return nil, errors.New("one of -topology or -gen is required")
+}
+
+// trafficGen 按固定速率在随机节点对之间发送带追踪ID的数据包
+type trafficGen struct {
+	sim       *network.Sim
+	opts      options
+	rng       *rand.Rand
+	collector *network.TraceCollector
+	lock      sync.Mutex // 真实时钟下发送在定时器 goroutine 中执行
+	sent      []uint64
+	noRoute   int
+}
+
+func newTrafficGen(sim *network.Sim, opts options, seed int64) *trafficGen {
+	g := &trafficGen{
+		sim:       sim,
+		opts:      opts,
+		rng:       rand.New(rand.NewSource(seed)),
+		collector: network.NewTraceCollector(),
+	}
+	sim.SetTracer(g.collector)
+	for _, n := range sim.Nodes {
+		n.Handle(trafficPort, func(vrr.Delivery) {})
+	}
+	return g
+}
+
+// schedule 在仿真时钟上调度 traffic-start 之后的所有发送
+func (g *trafficGen) schedule() {
+	if len(g.sim.Nodes) < 2 {
+		return
+	}
+	interval := time.Duration(float64(time.Second) / g.opts.traffic)
+	var tick func()
+	tick = func() {
+		src := g.sim.Nodes[g.rng.Intn(len(g.sim.Nodes))]
+		dst := g.sim.Nodes[g.rng.Intn(len(g.sim.Nodes)-1)]
+		if dst == src {
+			dst = g.sim.Nodes[len(g.sim.Nodes)-1]
+		}
+		if !src.Stopped() {
+			id, ok := src.SendDataTraced(dst.ID, trafficPort, make([]byte, g.opts.size))
+			g.lock.Lock()
+			if ok {
+				g.sent = append(g.sent, id)
+			} else {
+				g.noRoute++
+			}
+			g.lock.Unlock()
+		}
+		g.sim.Clock.AfterFunc(interval, tick)
+	}
+	g.sim.Clock.AfterFunc(g.opts.start, tick)
+}
+
+func (g *trafficGen) stats() *TrafficStats {
+	g.lock.Lock()
+	sent := append([]uint64(nil), g.sent...)
+	stats := &TrafficStats{Sent: len(sent), NoRoute: g.noRoute}
+	g.lock.Unlock()
+
+	var latency time.Duration
+	var stretch float64
+	stretched := 0
+	for _, id := range sent {
+		t, ok := g.collector.Trace(id)
+		switch {
+		case !ok:
+		case t.Delivered:
+			stats.Delivered++
+			latency += t.Latency()
+			if s, ok := g.sim.Network.Stretch(t); ok {
+				stretch += s
+				stretched++
+			}
+		case t.Dropped:
+			stats.Dropped++
+		}
+	}
+	if stats.Delivered > 0 {
+		latency /= time.Duration(stats.Delivered)
+	}
+	stats.Latency = latency.String()
+	if stretched > 0 {
+		stats.Stretch = stretch / float64(stretched)
+	}
+	return stats
+}
+
+func writeDOT(nw *network.Network, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := nw.WriteDOT(f, network.DefaultDOTOptions()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeText 以与 test/utils.go 中打印函数相同的格式输出结果
+func writeText(w io.Writer, r *Result) error {
+	var b strings.Builder
+	clock := "real"
+	if r.Virtual {
+		clock = "virtual"
+	}
+	fmt.Fprintf(&b, "ran %s on the %s clock, %d nodes\n", r.Duration, clock, len(r.Snapshot.Nodes))
+
+	ring := r.Snapshot.Ring
+	if ring.OK {
+		fmt.Fprintf(&b, "ring: consistent %v", ring.Rings)
+		if r.ConvergedAt != "" {
+			fmt.Fprintf(&b, ", first converged at %s", r.ConvergedAt)
+		}
+		b.WriteString("\n")
+	} else {
+		fmt.Fprintf(&b, "ring: %d problem(s) %v\n", len(ring.Problems), ring.Rings)
+		for _, p := range ring.Problems {
+			fmt.Fprintf(&b, "\t- %s\n", p)
+		}
+	}
+
+	stats := r.Snapshot.Stats
+	fmt.Fprintf(&b, "messages: %d sent, %d dropped\n", stats.Total, stats.Dropped)
+	fmt.Fprintf(&b, "\ttransmissions: %s\n", network.FormatCounts(stats.Transmissions))
+	fmt.Fprintf(&b, "\tdrops: %s\n", network.FormatCounts(stats.Drops))
+	if t := r.Traffic; t != nil {
+		fmt.Fprintf(&b, "traffic: %d sent, %d without route, %d delivered, %d dropped, avg latency %s, avg stretch %.2f\n",
+			t.Sent, t.NoRoute, t.Delivered, t.Dropped, t.Latency, t.Stretch)
+	}
+	if r.timeline != nil {
+		b.WriteString("timeline:\n")
+		b.WriteString(r.timeline.String())
+	}
+
+	for _, n := range r.nodes {
+		fmt.Fprintf(&b, "\nNode %d (active %v, ring %d, subnets %v)\n", n.ID, n.IsActive(), n.GetRingID(), summary(r.Snapshot, n.ID).Subnets)
+		fmt.Fprintf(&b, "\t%s\n", n.PsetManager.String())
+		fmt.Fprintf(&b, "\t%s\n", n.PsetStateManager.String())
+		fmt.Fprintf(&b, "\t%s\n", n.VsetManager.String())
+		fmt.Fprintf(&b, "\t%s\n", n.RoutingTable.String())
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// summary 返回快照中 id 节点的概要
+func summary(snap network.Snapshot, id uint32) network.NodeSummary {
+	for _, n := range snap.Nodes {
+		if n.ID == id {
+			return n.NodeSummary
+		}
+	}
+	return network.NodeSummary{ID: id}
+}
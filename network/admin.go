@@ -0,0 +1,262 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+/*
+管理接口：AdminServer 是一个 http.Handler，以 JSON 形式暴露节点和网络的状态，
+并提供发送数据、启停节点和切断链路的操作，便于用 curl 或网页查看运行中的仿真。
+
+	GET  /nodes                      所有节点的概要
+	GET  /nodes/{id}                 节点的 pset、pset 状态、vset、路由表和计数器
+	GET  /nodes/{id}/pset            物理邻居集
+	GET  /nodes/{id}/pset-state      按状态分类的物理邻居
+	GET  /nodes/{id}/vset            虚拟邻居集
+	GET  /nodes/{id}/routes          路由表
+	GET  /nodes/{id}/counters        节点的指标
+	GET  /topology                   子网拓扑和物理链路
+	GET  /stats                      网络层的消息统计
+	GET  /ring                       虚拟环一致性检查
+	GET  /metrics                    Prometheus 文本格式的指标
+	GET  /dot                        Graphviz DOT 图，参数 path=a-b 叠加显示 vset-path
+	POST /nodes/{id}/send            {"dst": 8085, "port": 0, "data": "hello"}
+	POST /nodes/{id}/stop            停止节点
+	POST /nodes/{id}/start           启动已停止的节点
+	POST /links/cut                  {"a": 8081, "b": 8082}，切断双向链路
+	POST /links/restore              {"a": 8081, "b": 8082}，恢复切断前的链路设置
+
+虚拟时钟下仿真由调用方推进，推进时钟的代码应通过 AdminServer.Do 执行，与请求互斥。
+*/
+
+// AdminServer 是网络的 HTTP 管理接口
+type AdminServer struct {
+	network *Network
+	mux     *http.ServeMux
+	lock    sync.Mutex // 请求之间、请求与 Do 之间互斥
+}
+
+// NewAdminServer 创建 network 的管理接口
+func NewAdminServer(network *Network) *AdminServer {
+	s := &AdminServer{
+		network: network,
+		mux:     http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /nodes", s.handleNodes)
+	s.mux.HandleFunc("GET /nodes/{id}", s.nodeHandler(func(n *vrr.Node) any { return s.network.nodeDetail(n) }))
+	s.mux.HandleFunc("GET /nodes/{id}/pset", s.nodeHandler(func(n *vrr.Node) any { return psetInfo(n) }))
+	s.mux.HandleFunc("GET /nodes/{id}/pset-state", s.nodeHandler(func(n *vrr.Node) any { return psetStateInfo(n) }))
+	s.mux.HandleFunc("GET /nodes/{id}/vset", s.nodeHandler(func(n *vrr.Node) any { return idList(n.VsetManager.GetAll()) }))
+	s.mux.HandleFunc("GET /nodes/{id}/routes", s.nodeHandler(func(n *vrr.Node) any { return routeInfo(n) }))
+	s.mux.HandleFunc("GET /nodes/{id}/counters", s.nodeHandler(func(n *vrr.Node) any { return counterInfo(n.Collect()) }))
+	s.mux.HandleFunc("GET /topology", s.handleTopology)
+	s.mux.HandleFunc("GET /stats", s.handleStats)
+	s.mux.HandleFunc("GET /ring", s.handleRing)
+	s.mux.HandleFunc("GET /metrics", s.handleMetrics)
+	s.mux.HandleFunc("GET /dot", s.handleDOT)
+	s.mux.HandleFunc("POST /nodes/{id}/send", s.handleSend)
+	s.mux.HandleFunc("POST /nodes/{id}/stop", s.handleStop)
+	s.mux.HandleFunc("POST /nodes/{id}/start", s.handleStart)
+	s.mux.HandleFunc("POST /links/cut", s.handleLink(true))
+	s.mux.HandleFunc("POST /links/restore", s.handleLink(false))
+	return s
+}
+
+// ServeHTTP 实现 http.Handler
+func (s *AdminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.mux.ServeHTTP(w, r)
+}
+
+// Do 在与请求互斥的情况下执行 f，用于推进虚拟时钟等修改仿真状态的操作
+func (s *AdminServer) Do(f func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	f()
+}
+
+// -----------------------请求格式-----------------------
+
+// SendRequest 是 POST /nodes/{id}/send 的请求体
+type SendRequest struct {
+	Dst  uint32 `json:"dst"`
+	Port uint16 `json:"port"`
+	Data string `json:"data"`
+}
+
+// SendResult 是 POST /nodes/{id}/send 的响应
+type SendResult struct {
+	Sent    bool   `json:"sent"`
+	TraceID uint64 `json:"trace_id"`
+}
+
+// LinkRequest 是 POST /links/cut 和 /links/restore 的请求体
+type LinkRequest struct {
+	A uint32 `json:"a"`
+	B uint32 `json:"b"`
+}
+
+// -----------------------查询-----------------------
+
+func (s *AdminServer) handleNodes(w http.ResponseWriter, r *http.Request) {
+	nodes := s.network.nodeSnapshot()
+	summaries := make([]NodeSummary, 0, len(nodes))
+	for _, n := range nodes {
+		summaries = append(summaries, s.network.nodeSummary(n))
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].ID < summaries[j].ID })
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// nodeHandler 返回查询 {id} 节点并输出 view(node) 的处理函数
+func (s *AdminServer) nodeHandler(view func(n *vrr.Node) any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, err := s.node(r)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, view(n))
+	}
+}
+
+func (s *AdminServer) handleTopology(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.network.topologyInfo())
+}
+
+func (s *AdminServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.network.statsInfo())
+}
+
+func (s *AdminServer) handleRing(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.network.ringInfo())
+}
+
+func (s *AdminServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.network.Registry().ServeHTTP(w, r)
+}
+
+func (s *AdminServer) handleDOT(w http.ResponseWriter, r *http.Request) {
+	opts := DefaultDOTOptions()
+	for _, p := range r.URL.Query()["path"] {
+		a, b, ok := strings.Cut(p, "-")
+		ea, errA := strconv.ParseUint(a, 10, 32)
+		eb, errB := strconv.ParseUint(b, 10, 32)
+		if !ok || errA != nil || errB != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid path %q, expected a-b", p))
+			return
+		}
+		opts.Paths = append(opts.Paths, [2]uint32{uint32(ea), uint32(eb)})
+	}
+	w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	s.network.WriteDOT(w, opts)
+}
+
+// -----------------------操作-----------------------
+
+func (s *AdminServer) handleSend(w http.ResponseWriter, r *http.Request) {
+	n, err := s.node(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	var req SendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Dst == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("dst is required"))
+		return
+	}
+	traceID, sent := n.SendDataTraced(req.Dst, req.Port, []byte(req.Data))
+	writeJSON(w, http.StatusOK, SendResult{Sent: sent, TraceID: traceID})
+}
+
+func (s *AdminServer) handleStop(w http.ResponseWriter, r *http.Request) {
+	n, err := s.node(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	n.Stop()
+	writeJSON(w, http.StatusOK, s.network.nodeSummary(n))
+}
+
+func (s *AdminServer) handleStart(w http.ResponseWriter, r *http.Request) {
+	n, err := s.node(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if !n.Stopped() {
+		writeError(w, http.StatusConflict, fmt.Errorf("node %d is not stopped", n.ID))
+		return
+	}
+	n.Start()
+	writeJSON(w, http.StatusOK, s.network.nodeSummary(n))
+}
+
+// handleLink 返回切断(cut 为 true)或恢复链路的处理函数
+func (s *AdminServer) handleLink(cut bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req LinkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		nodes := s.network.nodeSnapshot()
+		for _, id := range []uint32{req.A, req.B} {
+			if nodes[id] == nil {
+				writeError(w, http.StatusNotFound, fmt.Errorf("unknown node %d", id))
+				return
+			}
+		}
+		if cut {
+			s.network.CutLink(req.A, req.B)
+		} else {
+			s.network.HealLink(req.A, req.B)
+		}
+		writeJSON(w, http.StatusOK, req)
+	}
+}
+
+// -----------------------辅助函数-----------------------
+
+// node 查找路径参数 {id} 对应的已注册节点
+func (s *AdminServer) node(r *http.Request) (*vrr.Node, error) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node id %q", r.PathValue("id"))
+	}
+	s.network.nodesMux.RLock()
+	n, ok := s.network.Nodes[uint32(id)]
+	s.network.nodesMux.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown node %d", id)
+	}
+	return n, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
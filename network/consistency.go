@@ -0,0 +1,243 @@
+package network
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 虚拟环一致性检查：根据物理拓扑把已注册的节点划分为连通分量，每个连通分量应形成一个虚拟环。
+// 对每个节点，由所在连通分量中的节点ID计算理想的 vset 并与 VsetManager 比较；
+// 对每一对虚拟邻居，沿路由表逐跳检查两者之间是否存在一条完整且前后一致的 vset-path。
+
+// 不一致的类型
+const (
+	PROBLEM_INACTIVE    = "inactive"         // 节点未活跃
+	PROBLEM_MISSING     = "missing-neighbor" // vset 缺少理想的虚拟邻居
+	PROBLEM_EXTRA       = "extra-neighbor"   // vset 中有不应存在的节点
+	PROBLEM_NO_PATH     = "no-path"          // 虚拟邻居之间没有 vset-path
+	PROBLEM_BROKEN_PATH = "broken-path"      // vset-path 在中途断开或前后不一致
+)
+
+// RingProblem 描述一处与理想虚拟环不一致的状态
+type RingProblem struct {
+	Node   uint32 // 出现问题的节点
+	Peer   uint32 // 相关的虚拟邻居，0 表示无
+	Kind   string // 不一致的类型，PROBLEM_*
+	Detail string
+}
+
+func (p RingProblem) String() string {
+	if p.Peer == 0 {
+		return fmt.Sprintf("node %d: %s", p.Node, p.Kind)
+	}
+	if p.Detail == "" {
+		return fmt.Sprintf("node %d: %s %d", p.Node, p.Kind, p.Peer)
+	}
+	return fmt.Sprintf("node %d: %s %d (%s)", p.Node, p.Kind, p.Peer, p.Detail)
+}
+
+// RingReport 是一次一致性检查的结果
+type RingReport struct {
+	Rings    [][]uint32    // 每个物理连通分量中的节点ID（升序），各自应形成一个虚拟环
+	Problems []RingProblem // 发现的不一致，为空表示已收敛
+}
+
+// OK 判断虚拟环是否已与理想状态一致
+func (r *RingReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// String 返回检查结果的可读字符串表示形式
+func (r *RingReport) String() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("rings %v", r.Rings))
+	if r.OK() {
+		builder.WriteString(": consistent")
+		return builder.String()
+	}
+	builder.WriteString(fmt.Sprintf(": %d problem(s)", len(r.Problems)))
+	for _, p := range r.Problems {
+		builder.WriteString("\n\t- " + p.String())
+	}
+	return builder.String()
+}
+
+// IdealVset 计算在 ids 构成的虚拟环上节点 id 应有的虚拟邻居：
+// 顺时针和逆时针方向各 size/2 个最近的节点，节点不足 size 个时为其余所有节点
+func IdealVset(ids []uint32, id uint32, size int) []uint32 {
+	return vrr.RingNeighbors(id, ids, size)
+}
+
+// CheckRing 检查所有已注册节点的 vset 和路由表是否与理想的虚拟环一致
+func (network *Network) CheckRing() *RingReport {
+	network.nodesMux.RLock()
+	nodes := make(map[uint32]*vrr.Node, len(network.Nodes))
+	for id, n := range network.Nodes {
+		nodes[id] = n
+	}
+	network.nodesMux.RUnlock()
+
+	routes := make(map[uint32][]vrr.RoutingTableEntry, len(nodes))
+	for id, n := range nodes {
+		routes[id] = n.RoutingTable.Routes()
+	}
+
+	report := &RingReport{Rings: network.components(nodes)}
+	checked := make(map[[2]uint32]bool)
+	for _, ring := range report.Rings {
+		for _, id := range ring {
+			n := nodes[id]
+			if !n.IsActive() {
+				report.Problems = append(report.Problems, RingProblem{Node: id, Kind: PROBLEM_INACTIVE})
+			}
+
+			ideal := IdealVset(ring, id, n.Config().VsetSize)
+			vset := n.VsetManager.GetAll()
+			for _, peer := range ideal {
+				if !vrr.ContainsID(vset, peer) {
+					report.Problems = append(report.Problems, RingProblem{Node: id, Peer: peer, Kind: PROBLEM_MISSING})
+				}
+			}
+			for _, peer := range vset {
+				if !vrr.ContainsID(ideal, peer) {
+					report.Problems = append(report.Problems, RingProblem{Node: id, Peer: peer, Kind: PROBLEM_EXTRA})
+				}
+			}
+
+			// 每对虚拟邻居只检查一次路径
+			for _, peer := range vset {
+				key := [2]uint32{id, peer}
+				if peer < id {
+					key = [2]uint32{peer, id}
+				}
+				if checked[key] {
+					continue
+				}
+				checked[key] = true
+				if p, ok := checkPath(nodes, routes, id, peer); !ok {
+					report.Problems = append(report.Problems, p)
+				}
+			}
+		}
+	}
+	return report
+}
+
+// WaitConverged 每隔 interval 检查一次虚拟环，直到与理想状态一致或经过 timeout。
+// 虚拟时钟下推进虚拟时间，否则等待真实时间；返回从调用开始到最后一次检查经过的时间，
+// 以及最后一次检查的结果（结果 OK 时即为收敛时间）
+func (network *Network) WaitConverged(timeout, interval time.Duration) (time.Duration, *RingReport) {
+	start := network.clock.Now()
+	vc, virtual := network.clock.(*vrr.VirtualClock)
+	for {
+		report := network.CheckRing()
+		elapsed := network.clock.Now().Sub(start)
+		if report.OK() || elapsed >= timeout {
+			return elapsed, report
+		}
+		if virtual {
+			vc.RunFor(interval)
+		} else {
+			time.Sleep(interval)
+		}
+	}
+}
+
+// adjacency 返回 nodes 之间可用的物理链接：同一子网中的两个节点互为邻居，
+// 任一方向完全丢包的链路不算作链接
+func (network *Network) adjacency(nodes map[uint32]*vrr.Node) map[uint32][]uint32 {
+	network.topologyMux.RLock()
+	subnets := make([][]uint32, 0, len(network.SubnetTopology))
+	for _, members := range network.SubnetTopology {
+		subnets = append(subnets, append([]uint32(nil), members...))
+	}
+	network.topologyMux.RUnlock()
+
+	adj := make(map[uint32][]uint32, len(nodes))
+	for _, members := range subnets {
+		for i, a := range members {
+			for _, b := range members[i+1:] {
+				if nodes[a] == nil || nodes[b] == nil {
+					continue
+				}
+				if network.GetLinkProfile(a, b).PacketLoss >= 1 || network.GetLinkProfile(b, a).PacketLoss >= 1 {
+					continue
+				}
+				adj[a] = append(adj[a], b)
+				adj[b] = append(adj[b], a)
+			}
+		}
+	}
+	return adj
+}
+
+// components 按物理连通性把节点划分为连通分量：同一子网中链路未被切断的两个节点相邻
+func (network *Network) components(nodes map[uint32]*vrr.Node) [][]uint32 {
+	adj := network.adjacency(nodes)
+
+	ids := make([]uint32, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var rings [][]uint32
+	seen := make(map[uint32]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ring := []uint32{id}
+		for queue := []uint32{id}; len(queue) > 0; queue = queue[1:] {
+			for _, next := range adj[queue[0]] {
+				if !seen[next] {
+					seen[next] = true
+					ring = append(ring, next)
+					queue = append(queue, next)
+				}
+			}
+		}
+		sort.Slice(ring, func(i, j int) bool { return ring[i] < ring[j] })
+		rings = append(rings, ring)
+	}
+	return rings
+}
+
+// checkPath 检查 a 与 b 之间是否存在一条完整的 vset-path：从 a 出发沿朝向 b 的下一跳逐跳前进，
+// 每一跳都要有同一路径的条目，且朝向 a 的下一跳指回上一跳，最终到达 b
+func checkPath(nodes map[uint32]*vrr.Node, routes map[uint32][]vrr.RoutingTableEntry, a, b uint32) (RingProblem, bool) {
+	problem := RingProblem{Node: a, Peer: b, Kind: PROBLEM_NO_PATH}
+	for _, start := range routes[a] {
+		if !isPathBetween(start, a, b) {
+			continue
+		}
+		key := routeKey{start.PathId, start.Ea, start.Eb}
+		walk := walkVsetPath(nodes, func(node uint32) (vrr.RoutingTableEntry, bool) {
+			return entryIn(routes[node], key)
+		}, key, a, b)
+		if walk.complete {
+			return RingProblem{}, true
+		}
+		problem.Kind = PROBLEM_BROKEN_PATH
+		problem.Detail = walk.problem.String()
+	}
+	return problem, false
+}
+
+// isPathBetween 判断路由条目的两个端点是否为 a 和 b
+func isPathBetween(entry vrr.RoutingTableEntry, a, b uint32) bool {
+	return (entry.Ea == a && entry.Eb == b) || (entry.Ea == b && entry.Eb == a)
+}
+
+// nextTowards 返回路由条目中朝向端点 e 的下一跳
+func nextTowards(entry vrr.RoutingTableEntry, e uint32) uint32 {
+	if entry.Ea == e {
+		return entry.Na
+	}
+	return entry.Nb
+}
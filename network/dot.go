@@ -0,0 +1,219 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// Graphviz 导出：把网络的物理拓扑和虚拟环画成一张 DOT 图。
+//   - 节点：活跃节点实心填充，非活跃节点虚线边框
+//   - 物理链路：同一子网中的两个节点之间的细线，颜色按两端各自记录的 pset 状态中较差的一个
+//   - 虚拟环：按ID排序后相邻节点之间的灰色点线，不参与布局
+//   - vset 边：互为虚拟邻居时为蓝色实线，只有一方记录对方时为蓝色虚线
+//   - vset-path：按端点对选择的路径逐跳叠加显示，每条路径一种颜色，走不通的部分用虚线
+
+// DOTOptions 控制导出的内容
+type DOTOptions struct {
+	Physical bool        // 物理链路
+	Ring     bool        // 按ID排序的虚拟环
+	Vset     bool        // vset 边
+	Paths    [][2]uint32 // 叠加显示端点为这些节点对的全部 vset-path
+}
+
+// DefaultDOTOptions 返回显示物理链路、虚拟环和 vset 边，不叠加路径的选项
+func DefaultDOTOptions() DOTOptions {
+	return DOTOptions{Physical: true, Ring: true, Vset: true}
+}
+
+// 物理链路按 pset 状态的颜色，状态按 PSET_* 编号
+var psetColors = []string{"darkgreen", "orange", "red", "gray"}
+
+// 叠加路径依次使用的颜色
+var pathColors = []string{"purple", "crimson", "darkcyan", "goldenrod", "magenta", "sienna"}
+
+// PathHop 是 vset-path 上的一跳
+type PathHop struct {
+	From, To uint32
+}
+
+// WriteDOT 按 opts 输出当前网络的 DOT 图
+func (network *Network) WriteDOT(w io.Writer, opts DOTOptions) error {
+	nodes := network.nodeSnapshot()
+
+	ids := make([]uint32, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var b strings.Builder
+	b.WriteString("graph vrr {\n")
+	b.WriteString("\tgraph [overlap=false, splines=true];\n")
+	b.WriteString("\tnode [shape=circle, fontsize=10];\n")
+
+	for _, id := range ids {
+		if nodes[id].IsActive() {
+			fmt.Fprintf(&b, "\t%d [style=filled, fillcolor=lightblue];\n", id)
+		} else {
+			fmt.Fprintf(&b, "\t%d [style=dashed];\n", id)
+		}
+	}
+
+	if opts.Physical {
+		for _, link := range network.physicalLinks(nodes) {
+			a, c := link[0], link[1]
+			sa, sc := nodes[a].PsetManager.GetStatus(c), nodes[c].PsetManager.GetStatus(a)
+			fmt.Fprintf(&b, "\t%d -- %d [color=%s, tooltip=\"%d: %s, %d: %s\"];\n",
+				a, c, psetColors[worsePsetStatus(sa, sc)], a, vrr.PsetStatusString(sa), c, vrr.PsetStatusString(sc))
+		}
+	}
+
+	if opts.Ring {
+		var ring []uint32
+		for _, id := range ids {
+			if nodes[id].IsActive() {
+				ring = append(ring, id)
+			}
+		}
+		// 两个节点时首尾相接的边与第一条边重合，只画一条
+		edges := len(ring)
+		if edges == 2 {
+			edges = 1
+		}
+		for i := 0; i < edges && len(ring) > 1; i++ {
+			fmt.Fprintf(&b, "\t%d -- %d [style=dotted, color=gray50, constraint=false];\n", ring[i], ring[(i+1)%len(ring)])
+		}
+	}
+
+	if opts.Vset {
+		for _, a := range ids {
+			for _, c := range nodes[a].VsetManager.GetAll() {
+				peer, ok := nodes[c]
+				mutual := ok && peer.VsetManager.Contains(a)
+				if mutual && c < a {
+					continue // 互为邻居的边只画一次
+				}
+				style := "solid"
+				if !mutual {
+					style = "dashed"
+				}
+				fmt.Fprintf(&b, "\t%d -- %d [color=blue, style=%s, penwidth=2, constraint=false];\n", a, c, style)
+			}
+		}
+	}
+
+	color := 0
+	for _, pair := range opts.Paths {
+		for _, path := range vsetPaths(nodes, pair[0], pair[1]) {
+			c := pathColors[color%len(pathColors)]
+			color++
+			for i, hop := range path.Hops {
+				fmt.Fprintf(&b, "\t%d -- %d [color=%s, penwidth=3, constraint=false, label=\"%d\", tooltip=\"path %d hop %d\"];\n",
+					hop.From, hop.To, c, i+1, path.PathId, i+1)
+			}
+			if !path.Complete {
+				last := pair[0]
+				if len(path.Hops) > 0 {
+					last = path.Hops[len(path.Hops)-1].To
+				}
+				fmt.Fprintf(&b, "\t%d -- %d [color=%s, style=dashed, constraint=false, tooltip=\"path %d broken\"];\n", last, pair[1], c, path.PathId)
+			}
+		}
+	}
+
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// VsetPath 是一条 vset-path 从一个端点出发的逐跳路径
+type VsetPath struct {
+	PathId   uint32
+	Hops     []PathHop
+	Complete bool // 是否走到了另一个端点
+}
+
+// VsetPaths 返回 a 与 b 之间的所有 vset-path：从 a 的路由表中端点为 a、b 的每个条目出发，
+// 沿朝向 b 的下一跳逐跳前进，直到到达 b 或路径断开(见 walkVsetPath)
+func (network *Network) VsetPaths(a, b uint32) []VsetPath {
+	nodes := network.nodeSnapshot()
+	return vsetPaths(nodes, a, b)
+}
+
+func vsetPaths(nodes map[uint32]*vrr.Node, a, b uint32) []VsetPath {
+	start, ok := nodes[a]
+	if !ok {
+		return nil
+	}
+	var paths []VsetPath
+	for _, entry := range start.RoutingTable.RoutesTo(b) {
+		if !isPathBetween(entry, a, b) {
+			continue
+		}
+		key := routeKey{entry.PathId, entry.Ea, entry.Eb}
+		walk := walkVsetPath(nodes, func(node uint32) (vrr.RoutingTableEntry, bool) {
+			if n, ok := nodes[node]; ok {
+				return entryIn(n.RoutingTable.RoutesTo(key.eb), key)
+			}
+			return vrr.RoutingTableEntry{}, false
+		}, key, a, b)
+		paths = append(paths, VsetPath{PathId: entry.PathId, Hops: walk.hops, Complete: walk.complete})
+	}
+	return paths
+}
+
+// physicalLinks 返回 nodes 之间的物理链路(同一子网中的两个节点)，较小的ID在前，按ID排序
+func (network *Network) physicalLinks(nodes map[uint32]*vrr.Node) [][2]uint32 {
+	network.topologyMux.RLock()
+	seen := make(map[[2]uint32]bool)
+	var links [][2]uint32
+	for _, members := range network.SubnetTopology {
+		for i, a := range members {
+			for _, c := range members[i+1:] {
+				if nodes[a] == nil || nodes[c] == nil || a == c {
+					continue
+				}
+				link := [2]uint32{a, c}
+				if c < a {
+					link = [2]uint32{c, a}
+				}
+				if !seen[link] {
+					seen[link] = true
+					links = append(links, link)
+				}
+			}
+		}
+	}
+	network.topologyMux.RUnlock()
+
+	sort.Slice(links, func(i, j int) bool {
+		if links[i][0] != links[j][0] {
+			return links[i][0] < links[j][0]
+		}
+		return links[i][1] < links[j][1]
+	})
+	return links
+}
+
+// worsePsetStatus 返回两个 pset 状态中较差的一个：failed 比 pending 差，pending 比 unknown 差，linked 最好
+func worsePsetStatus(a, b uint32) uint32 {
+	rank := func(s uint32) int {
+		switch s {
+		case vrr.PSET_LINKED:
+			return 0
+		case vrr.PSET_UNKNOWN:
+			return 1
+		case vrr.PSET_PENDING:
+			return 2
+		}
+		return 3
+	}
+	if rank(a) >= rank(b) {
+		return a
+	}
+	return b
+}
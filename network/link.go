@@ -0,0 +1,185 @@
+package network
+
+import (
+	"math"
+	"time"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// LatencyDist 链路延迟的分布类型
+type LatencyDist uint8
+
+const (
+	LATENCY_FIXED   LatencyDist = iota // 固定延迟 Latency
+	LATENCY_UNIFORM                    // 在 [Latency-Jitter, Latency+Jitter] 内均匀分布
+	LATENCY_NORMAL                     // 均值 Latency、标准差 Jitter 的正态分布
+)
+
+// LinkProfile 描述一条链路的延迟、丢包和带宽特性
+type LinkProfile struct {
+	Dist       LatencyDist   // 延迟分布
+	Latency    time.Duration // 传播延迟（分布的均值）
+	Jitter     time.Duration // 抖动（均匀分布的半宽或正态分布的标准差）
+	PacketLoss float32       // 丢包率 (0.0 - 1.0)
+	Bandwidth  uint64        // 带宽 (bit/s)，0 表示不限速
+}
+
+// linkKey 表示一条有向链路
+type linkKey struct {
+	src, dst uint32
+}
+
+// SetLinkProfile 设置 src -> dst 方向链路的特性，优先级高于子网和全局设置。
+// 双向链路需要分别设置两个方向。
+func (network *Network) SetLinkProfile(src, dst uint32, profile LinkProfile) {
+	network.linkMux.Lock()
+	defer network.linkMux.Unlock()
+	network.linkProfiles[linkKey{src, dst}] = profile
+}
+
+// ClearLinkProfile 删除 src -> dst 方向链路的特性设置
+func (network *Network) ClearLinkProfile(src, dst uint32) {
+	network.linkMux.Lock()
+	defer network.linkMux.Unlock()
+	delete(network.linkProfiles, linkKey{src, dst})
+}
+
+// CutLink 把 a 与 b 之间两个方向的链路丢包率设为 1，并记下第一次切断前的链路设置
+func (network *Network) CutLink(a, b uint32) {
+	network.linkMux.Lock()
+	defer network.linkMux.Unlock()
+	for _, k := range []linkKey{{a, b}, {b, a}} {
+		if _, ok := network.savedLinks[k]; !ok {
+			if prev, ok := network.linkProfiles[k]; ok {
+				network.savedLinks[k] = &prev
+			} else {
+				network.savedLinks[k] = nil
+			}
+		}
+		network.linkProfiles[k] = LinkProfile{PacketLoss: 1}
+	}
+}
+
+// HealLink 恢复 CutLink 之前 a 与 b 之间两个方向的链路设置，没有切断过的方向删除单独设置
+func (network *Network) HealLink(a, b uint32) {
+	network.linkMux.Lock()
+	defer network.linkMux.Unlock()
+	for _, k := range []linkKey{{a, b}, {b, a}} {
+		if prev := network.savedLinks[k]; prev != nil {
+			network.linkProfiles[k] = *prev
+		} else {
+			delete(network.linkProfiles, k)
+		}
+		delete(network.savedLinks, k)
+	}
+}
+
+// SetSubnetProfile 设置子网内所有链路的特性，优先级高于全局的 Latency/PacketLoss
+func (network *Network) SetSubnetProfile(subnetID uint32, profile LinkProfile) {
+	network.linkMux.Lock()
+	defer network.linkMux.Unlock()
+	network.subnetProfiles[subnetID] = profile
+}
+
+// ClearSubnetProfile 删除子网的链路特性设置
+func (network *Network) ClearSubnetProfile(subnetID uint32) {
+	network.linkMux.Lock()
+	defer network.linkMux.Unlock()
+	delete(network.subnetProfiles, subnetID)
+}
+
+// SetPacketLoss 设置全局丢包率并返回原来的值。仿真运行中修改全局丢包率应使用它，
+// 与 GetLinkProfile 的读取同步
+func (network *Network) SetPacketLoss(loss float32) float32 {
+	network.linkMux.Lock()
+	defer network.linkMux.Unlock()
+	prev := network.PacketLoss
+	network.PacketLoss = loss
+	return prev
+}
+
+// GetLinkProfile 返回 src -> dst 方向实际生效的链路特性：
+// 链路设置 > 共同子网中ID最小者的子网设置 > 全局 Latency/PacketLoss
+func (network *Network) GetLinkProfile(src, dst uint32) LinkProfile {
+	// 先读取拓扑再加链路锁，避免两把锁嵌套
+	network.topologyMux.RLock()
+	srcSubnets, dstSubnets := network.NodeToSubnet[src], network.NodeToSubnet[dst]
+	network.topologyMux.RUnlock()
+
+	network.linkMux.RLock()
+	defer network.linkMux.RUnlock()
+
+	if profile, ok := network.linkProfiles[linkKey{src, dst}]; ok {
+		return profile
+	}
+
+	found := false
+	var best uint32
+	for _, s := range srcSubnets {
+		if _, ok := network.subnetProfiles[s]; !ok || !containsSubnet(dstSubnets, s) {
+			continue
+		}
+		if !found || s < best {
+			best, found = s, true
+		}
+	}
+	if found {
+		return network.subnetProfiles[best]
+	}
+
+	return LinkProfile{Dist: LATENCY_FIXED, Latency: network.Latency, PacketLoss: network.PacketLoss}
+}
+
+// linkDelay 计算消息在 src -> dst 链路上的总延迟：排队 + 发送 + 传播。
+// 设置了带宽时按编码后的报文长度计算发送时间，并累计到链路的发送队列上。
+func (network *Network) linkDelay(src, dst uint32, profile LinkProfile, msg vrr.Message) time.Duration {
+	delay := network.sampleLatency(profile)
+
+	if profile.Bandwidth > 0 {
+		size := vrr.VRR_HEADER_LEN
+		if packet, err := msg.MarshalBinary(); err == nil {
+			size = len(packet)
+		}
+		txTime := time.Duration(uint64(size) * 8 * uint64(time.Second) / profile.Bandwidth)
+
+		now := network.clock.Now()
+		network.linkMux.Lock()
+		start := network.linkBusyUntil[linkKey{src, dst}]
+		if start.Before(now) {
+			start = now
+		}
+		done := start.Add(txTime)
+		network.linkBusyUntil[linkKey{src, dst}] = done
+		network.linkMux.Unlock()
+
+		delay += done.Sub(now)
+	}
+	return delay
+}
+
+// sampleLatency 按链路的延迟分布采样一次传播延迟，结果不小于0
+func (network *Network) sampleLatency(profile LinkProfile) time.Duration {
+	latency := profile.Latency
+	if profile.Jitter > 0 {
+		network.rngMux.Lock()
+		switch profile.Dist {
+		case LATENCY_UNIFORM:
+			latency += time.Duration((network.rng.Float64()*2 - 1) * float64(profile.Jitter))
+		case LATENCY_NORMAL:
+			latency += time.Duration(network.rng.NormFloat64() * float64(profile.Jitter))
+		}
+		network.rngMux.Unlock()
+	}
+	return time.Duration(math.Max(0, float64(latency)))
+}
+
+// containsSubnet 判断子网列表中是否包含指定子网
+func containsSubnet(subnets []uint32, subnetID uint32) bool {
+	for _, s := range subnets {
+		if s == subnetID {
+			return true
+		}
+	}
+	return false
+}
@@ -1,8 +1,8 @@
 package network
 
 import (
-	"log"
 	"math/rand"
+	"strconv"
 	"sync"
 	"time"
 
@@ -14,14 +14,34 @@ type Network struct {
 	Nodes    map[uint32]*vrr.Node // 所有节点的映射表
 	nodesMux sync.RWMutex         // 保护节点映射表的读写锁
 
-	// 网络延迟和丢包模拟参数
+	// 网络延迟和丢包模拟参数（全局默认值，可被子网和链路设置覆盖）
 	Latency    time.Duration // 模拟网络延迟
 	PacketLoss float32       // 丢包率 (0.0 - 1.0)
 
+	// 链路模型
+	linkProfiles   map[linkKey]LinkProfile  // 有向链路的特性设置
+	subnetProfiles map[uint32]LinkProfile   // 子网的链路特性设置
+	linkBusyUntil  map[linkKey]time.Time    // 有向链路发送队列的空闲时刻
+	savedLinks     map[linkKey]*LinkProfile // CutLink 之前的链路设置，nil 表示没有单独设置
+	linkMux        sync.RWMutex
+
+	// 时钟、随机数（丢包、延迟抖动）与日志
+	clock  vrr.Clock
+	rng    *rand.Rand
+	rngMux sync.Mutex
+	logger *vrr.Logger
+
 	// 统计信息
-	TotalMessages   uint64       // 总发送消息数
-	DroppedMessages uint64       // 丢失消息数
-	statsMux        sync.RWMutex // 统计信息锁
+	TotalMessages   uint64          // 总发送消息数
+	DroppedMessages uint64          // 丢失消息数
+	statsMux        sync.RWMutex    // 统计信息锁
+	transmissions   *vrr.CounterVec // 按消息类型统计的单跳发送数，广播按接收者分别计数
+	drops           *vrr.CounterVec // 按发送节点、消息类型和原因统计的丢弃数
+	registry        *vrr.Registry   // 网络和所有已注册节点的指标
+	tracer          vrr.Tracer      // 带有 TraceID 的消息的发送和丢弃事件钩子
+	tracerMux       sync.RWMutex
+	capture         *PcapWriter // 抓包输出，nil 表示不抓包
+	captureMux      sync.RWMutex
 
 	SubnetTopology map[uint32][]uint32 // 新增：子网拓扑。key: 子网ID, value: 该子网中的节点ID列表
 	NodeToSubnet   map[uint32][]uint32 // 新增：节点到子网的反向映射。key: 节点ID, value: 该节点所属的子网ID列表
@@ -35,35 +55,44 @@ func (network *Network) deliverMessage(msg vrr.Message) {
 	network.nodesMux.RUnlock()
 
 	if !exists {
-		log.Printf("Network: Target node %d not found, dropping message", msg.NextHop)
+		network.logger.Warn(vrr.LOG_NETWORK, "target node not found, dropping message", "type", vrr.GetMessageTypeString(msg.Type), "next_hop", msg.NextHop)
+		network.countDrop(msg, vrr.DROP_UNKNOWN_TARGET)
+		return
+	}
+
+	// 虚拟时间模式下同步处理
+	if vrr.IsVirtual(network.clock) {
+		network.captureMessage(CAPTURE_DELIVER, msg, "")
+		targetNode.Receive(msg)
 		return
 	}
 
 	// 尝试投递到目标节点的inbox
 	select {
 	case targetNode.InboxChan <- msg:
+		network.captureMessage(CAPTURE_DELIVER, msg, "")
 		/* 		log.Printf("Network: Delivered message type %s from Node %d to Node %d",
 		vrr.GetMessageTypeString(msg.Type), msg.Src, msg.NextHop) */
 	default:
 		// inbox满了，丢弃消息
-		log.Printf("Network: Node %d inbox full, dropping message", msg.NextHop)
-		network.statsMux.Lock()
-		network.DroppedMessages++
-		network.statsMux.Unlock()
+		network.logger.Warn(vrr.LOG_NETWORK, "inbox full, dropping message", "type", vrr.GetMessageTypeString(msg.Type), "next_hop", msg.NextHop)
+		network.countDrop(msg, vrr.DROP_INBOX_FULL)
 	}
 }
 
-// shouldDropPacket 根据丢包率决定是否丢包
-func (network *Network) shouldDropPacket() bool {
+// shouldDropPacket 根据链路的丢包率决定是否丢包
+func (network *Network) shouldDropPacket(profile LinkProfile) bool {
 	// 如果丢包率设置为0或更低，则从不丢包
-	if network.PacketLoss <= 0 {
+	if profile.PacketLoss <= 0 {
 		return false
 	}
 	// 如果丢包率设置为1或更高，则总是丢包
-	if network.PacketLoss >= 1.0 {
+	if profile.PacketLoss >= 1.0 {
 		return true
 	}
-	shouldDropPacket := rand.Float32() < network.PacketLoss
+	network.rngMux.Lock()
+	shouldDropPacket := network.rng.Float32() < profile.PacketLoss
+	network.rngMux.Unlock()
 	return shouldDropPacket
 }
 
@@ -71,13 +100,43 @@ func (network *Network) shouldDropPacket() bool {
 
 // NewNetwork 创建 Network
 func NewNetwork(Latency time.Duration, PacketLoss float32) *Network {
-	return &Network{
+	network := &Network{
 		Nodes:          make(map[uint32]*vrr.Node),
 		Latency:        Latency,
 		PacketLoss:     PacketLoss,
 		SubnetTopology: make(map[uint32][]uint32), // 初始化
 		NodeToSubnet:   make(map[uint32][]uint32), // 初始化
+		linkProfiles:   make(map[linkKey]LinkProfile),
+		subnetProfiles: make(map[uint32]LinkProfile),
+		linkBusyUntil:  make(map[linkKey]time.Time),
+		savedLinks:     make(map[linkKey]*LinkProfile),
+		clock:          vrr.RealClock,
+		rng:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger:         vrr.DefaultLogger(),
+		transmissions:  vrr.NewCounterVec("type"),
+		drops:          vrr.NewCounterVec("node", "type", "reason"),
+		registry:       vrr.NewRegistry(),
 	}
+	network.registry.Register(network)
+	return network
+}
+
+// SetLogger 设置网络的日志，默认为 vrr.DefaultLogger()
+func (network *Network) SetLogger(logger *vrr.Logger) {
+	network.logger = logger
+}
+
+// SetClock 设置网络使用的时钟，需要与所有节点使用同一个时钟。
+// 使用 vrr.VirtualClock 时，消息的延迟投递作为虚拟时钟上的事件执行，到达时同步调用 Node.Receive。
+func (network *Network) SetClock(clock vrr.Clock) {
+	network.clock = clock
+}
+
+// SetSeed 用固定种子重置网络的随机数生成器（丢包和延迟抖动）
+func (network *Network) SetSeed(seed int64) {
+	network.rngMux.Lock()
+	defer network.rngMux.Unlock()
+	network.rng = rand.New(rand.NewSource(seed))
 }
 
 // RegisterNode 注册节点到子网
@@ -85,6 +144,7 @@ func (network *Network) RegisterNode(node *vrr.Node, subnetIDs ...uint32) {
 	network.nodesMux.Lock()
 	defer network.nodesMux.Unlock()
 	network.Nodes[node.ID] = node
+	network.registry.Register(node)
 
 	network.topologyMux.Lock()
 	defer network.topologyMux.Unlock()
@@ -93,7 +153,7 @@ func (network *Network) RegisterNode(node *vrr.Node, subnetIDs ...uint32) {
 		network.SubnetTopology[subnetID] = append(network.SubnetTopology[subnetID], node.ID)
 	}
 
-	log.Printf("Network: Registered node %d to subnet(s) %v", node.ID, subnetIDs)
+	network.logger.Info(vrr.LOG_NETWORK, "node registered", "node", node.ID, "subnets", subnetIDs)
 }
 
 // UnregisterNode 从网络注销节点 (完全移除)
@@ -121,9 +181,12 @@ func (network *Network) UnregisterNode(nodeID uint32) {
 	}
 
 	// 2. 从主节点列表中移除节点
+	if node, ok := network.Nodes[nodeID]; ok {
+		network.registry.Unregister(node)
+	}
 	delete(network.Nodes, nodeID)
 
-	log.Printf("Network: Unregistered node %d", nodeID)
+	network.logger.Info(vrr.LOG_NETWORK, "node unregistered", "node", nodeID)
 }
 
 // UnregisterNodeFromSubnets 将节点从指定的子网列表中注销
@@ -169,13 +232,46 @@ func (network *Network) UnregisterNodeFromSubnets(nodeID uint32, subnetsToLeave
 		// 关键修复：如果节点不再属于任何子网，则从两个映射中都删除它
 		if len(newCurrentSubnets) == 0 {
 			delete(network.NodeToSubnet, nodeID)
+			if node, ok := network.Nodes[nodeID]; ok {
+				network.registry.Unregister(node)
+			}
 			delete(network.Nodes, nodeID) // <--- 你指出的问题在这里被修复
 		} else {
 			network.NodeToSubnet[nodeID] = newCurrentSubnets
 		}
 	}
 
-	log.Printf("Network: Unregistered node %d from subnet(s) %v", nodeID, subnetsToLeave)
+	network.logger.Info(vrr.LOG_NETWORK, "node left subnets", "node", nodeID, "subnets", subnetsToLeave)
+}
+
+// JoinSubnets 将节点加入指定的子网，节点已在的子网被忽略；节点未注册时同时注册
+func (network *Network) JoinSubnets(node *vrr.Node, subnetIDs ...uint32) {
+	// 严格遵守加锁顺序: 1. nodesMux, 2. topologyMux
+	network.nodesMux.Lock()
+	defer network.nodesMux.Unlock()
+	network.topologyMux.Lock()
+	defer network.topologyMux.Unlock()
+
+	network.Nodes[node.ID] = node
+	network.registry.Register(node)
+	current := network.NodeToSubnet[node.ID]
+	for _, subnetID := range subnetIDs {
+		if containsSubnet(current, subnetID) {
+			continue
+		}
+		current = append(current, subnetID)
+		network.SubnetTopology[subnetID] = append(network.SubnetTopology[subnetID], node.ID)
+	}
+	network.NodeToSubnet[node.ID] = current
+
+	network.logger.Info(vrr.LOG_NETWORK, "node joined subnets", "node", node.ID, "subnets", subnetIDs)
+}
+
+// GetSubnets 返回节点当前所在的子网
+func (network *Network) GetSubnets(nodeID uint32) []uint32 {
+	network.topologyMux.RLock()
+	defer network.topologyMux.RUnlock()
+	return append([]uint32(nil), network.NodeToSubnet[nodeID]...)
 }
 
 // Send 发送消息的核心实现
@@ -183,17 +279,19 @@ func (network *Network) Send(msg vrr.Message) {
 	// --- 广播逻辑 ---
 	if msg.NextHop == 0 {
 		network.topologyMux.RLock()
-		defer network.topologyMux.RUnlock()
 
 		// 找到发送者所在的子网
 		senderSubnets, ok := network.NodeToSubnet[msg.Src]
 		if !ok || len(senderSubnets) == 0 {
-			log.Printf("Network: Broadcast failed. Sender %d is not in any subnet.", msg.Src)
+			network.topologyMux.RUnlock()
+			network.logger.Warn(vrr.LOG_NETWORK, "broadcast failed, sender is not in any subnet", "type", vrr.GetMessageTypeString(msg.Type), "src", msg.Src)
+			network.countDrop(msg, vrr.DROP_UNKNOWN_TARGET)
 			return
 		}
 
 		// 使用一个 map 来防止向同一个节点发送多次广播（当一个节点属于多个子网时）
 		sentTo := make(map[uint32]bool)
+		targets := make([]uint32, 0)
 
 		// 遍历发送者所在的所有子网
 		for _, subnetID := range senderSubnets {
@@ -205,12 +303,18 @@ func (network *Network) Send(msg vrr.Message) {
 					continue
 				}
 
-				broadcastMsg := msg
-				broadcastMsg.NextHop = targetNodeID
-				network.sendMessage(broadcastMsg)
+				targets = append(targets, targetNodeID)
 				sentTo[targetNodeID] = true
 			}
 		}
+		network.topologyMux.RUnlock()
+
+		// 释放拓扑锁后再发送，查询链路特性时需要重新读取拓扑
+		for _, targetNodeID := range targets {
+			broadcastMsg := msg
+			broadcastMsg.NextHop = targetNodeID
+			network.sendMessage(broadcastMsg)
+		}
 		return
 	}
 
@@ -224,22 +328,26 @@ func (network *Network) sendMessage(msg vrr.Message) {
 	network.statsMux.Lock()
 	network.TotalMessages++
 	network.statsMux.Unlock()
+	network.transmissions.Inc(vrr.MessageTypeLabel(msg.Type))
+	network.trace(vrr.TRACE_SEND, msg, hopSender(msg), msg.NextHop, "")
+	network.captureMessage(CAPTURE_SEND, msg, "")
+
+	// 链路的发送端是本跳的实际发送者
+	from := hopSender(msg)
+	profile := network.GetLinkProfile(from, msg.NextHop)
 
 	// 模拟丢包
-	if network.shouldDropPacket() {
-		network.statsMux.Lock()
-		network.DroppedMessages++
-		network.statsMux.Unlock()
-		log.Printf("Network: Packet dropped from Node %d to Node %d", msg.Src, msg.NextHop)
+	if network.shouldDropPacket(profile) {
+		network.countDrop(msg, vrr.DROP_LOSS)
+		network.logger.Debug(vrr.LOG_NETWORK, "packet dropped", "type", vrr.GetMessageTypeString(msg.Type), "src", msg.Src, "next_hop", msg.NextHop)
 		return
 	}
 
-	// 模拟网络延迟
-	if network.Latency > 0 {
-		go func() {
-			time.Sleep(network.Latency)
+	// 模拟网络延迟；虚拟时间模式下即使没有延迟也作为事件调度，避免收发递归
+	if delay := network.linkDelay(from, msg.NextHop, profile, msg); delay > 0 || vrr.IsVirtual(network.clock) {
+		network.clock.AfterFunc(delay, func() {
 			network.deliverMessage(msg)
-		}()
+		})
 	} else {
 		network.deliverMessage(msg)
 	}
@@ -252,6 +360,73 @@ func (network *Network) GetMsgInfo() (totalMsgs, droppedMsgs uint64) {
 	return network.TotalMessages, network.DroppedMessages
 }
 
+// countDrop 记录一条因 reason 被丢弃的消息，计入本跳的发送节点
+func (network *Network) countDrop(msg vrr.Message, reason string) {
+	network.statsMux.Lock()
+	network.DroppedMessages++
+	network.statsMux.Unlock()
+	network.drops.Inc(strconv.FormatUint(uint64(hopSender(msg)), 10), vrr.MessageTypeLabel(msg.Type), reason)
+	network.trace(vrr.TRACE_DROP, msg, hopSender(msg), msg.NextHop, reason)
+	network.captureMessage(CAPTURE_DROP, msg, reason)
+}
+
+// SetTracer 设置网络的追踪钩子，nil 表示不追踪。节点的钩子需要通过 Node.SetTracer 单独设置
+func (network *Network) SetTracer(tracer vrr.Tracer) {
+	network.tracerMux.Lock()
+	defer network.tracerMux.Unlock()
+	network.tracer = tracer
+}
+
+// trace 在消息带有 TraceID 且设置了追踪钩子时记录网络上的事件
+func (network *Network) trace(kind string, msg vrr.Message, node, peer uint32, reason string) {
+	network.tracerMux.RLock()
+	tracer := network.tracer
+	network.tracerMux.RUnlock()
+	if tracer == nil || msg.TraceID == 0 {
+		return
+	}
+	ev := vrr.NewTraceEvent(kind, network.clock.Now(), node, peer, msg)
+	ev.Reason = reason
+	tracer.TraceEvent(ev)
+}
+
+// hopSender 返回本跳的实际发送者
+func hopSender(msg vrr.Message) uint32 {
+	if msg.Sender == 0 {
+		return msg.Src
+	}
+	return msg.Sender
+}
+
+// Registry 返回网络的指标注册表，其中包含网络本身和所有已注册的节点
+func (network *Network) Registry() *vrr.Registry {
+	return network.registry
+}
+
+// Collect 返回网络层的丢弃数、单跳发送数和节点数
+func (network *Network) Collect() []vrr.MetricFamily {
+	network.nodesMux.RLock()
+	nodes := len(network.Nodes)
+	network.nodesMux.RUnlock()
+
+	return []vrr.MetricFamily{
+		{Name: "vrr_messages_dropped_total", Help: "Messages dropped, by message type and reason.", Type: vrr.METRIC_COUNTER, Samples: network.drops.Samples()},
+		{Name: "vrr_network_transmissions_total", Help: "Single-hop transmissions on the network, by message type.", Type: vrr.METRIC_COUNTER, Samples: network.transmissions.Samples()},
+		{Name: "vrr_nodes", Help: "Number of nodes registered on the network.", Type: vrr.METRIC_GAUGE, Samples: []vrr.Sample{{Value: float64(nodes)}}},
+	}
+}
+
+// nodeSnapshot 返回已注册节点映射表的副本
+func (network *Network) nodeSnapshot() map[uint32]*vrr.Node {
+	network.nodesMux.RLock()
+	nodes := make(map[uint32]*vrr.Node, len(network.Nodes))
+	for id, n := range network.Nodes {
+		nodes[id] = n
+	}
+	network.nodesMux.RUnlock()
+	return nodes
+}
+
 // GetAllNodes 获取所有注册的节点ID
 func (network *Network) GetAllNodes() []uint32 {
 	network.nodesMux.RLock()
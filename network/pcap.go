@@ -0,0 +1,269 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+/*
+抓包导出：网络把每一次发送、投递和丢弃写成 pcapng 文件中的一个 Enhanced Packet Block，
+时间戳使用网络的时钟(虚拟时间模式下为仿真时间，精度为纳秒)。链路类型为 LINKTYPE_USER0(147)，
+每个包的内容是 8 字节的抓包头加上 Message.MarshalBinary 编码的 VRR 报文：
+
+	0       1       2       3       4
+	+-------+-------+-------+-------+
+	|Version| Event | Reason|  Rsvd |   Version = 1，Event/Reason 见 CAPTURE_* 和 captureReasons
+	+-------+-------+-------+-------+
+	|            Subnet             |   本跳所在的子网，未知时为 0
+	+-------------------------------+
+	|    VRR 报文(报头 + Payload)    |
+	+-------------------------------+
+
+丢弃事件另外带有写明原因的 opt_comment 选项。Wireshark 的解析脚本见 tools/wireshark/vrr.lua。
+*/
+
+// 抓包事件
+const (
+	CAPTURE_SEND    = 0 // 网络发出一跳
+	CAPTURE_DELIVER = 1 // 消息到达下一跳节点
+	CAPTURE_DROP    = 2 // 消息被网络丢弃
+)
+
+const (
+	CAPTURE_VERSION    = 1
+	CAPTURE_HEADER_LEN = 8
+	LINKTYPE_USER0     = 147
+)
+
+// captureReasons 是抓包头中 Reason 字段的取值，下标即编码
+var captureReasons = []string{"", vrr.DROP_LOSS, vrr.DROP_INBOX_FULL, vrr.DROP_UNKNOWN_TARGET, vrr.DROP_SEND_ERROR, vrr.DROP_NO_ROUTE, vrr.DROP_INVALID, vrr.DROP_RECV_FULL}
+
+// pcapng 块类型和选项
+const (
+	pcapngSHB          = 0x0A0D0D0A
+	pcapngIDB          = 0x00000001
+	pcapngEPB          = 0x00000006
+	pcapngByteOrder    = 0x1A2B3C4D
+	pcapngOptEnd       = 0
+	pcapngOptComment   = 1
+	pcapngOptTsresol   = 9
+	pcapngTsresolNanos = 9
+)
+
+var ErrPcapFormat = errors.New("network: malformed pcapng capture")
+
+// CaptureRecord 是抓包文件中的一条记录
+type CaptureRecord struct {
+	Time   time.Time
+	Event  uint8  // CAPTURE_*
+	Reason string // 丢弃原因(vrr.DROP_*)，其余事件为空
+	Subnet uint32
+	Msg    vrr.Message
+}
+
+// PcapWriter 把抓包记录写成 pcapng 格式，可以被多个 goroutine 并发使用
+type PcapWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+	err  error // 第一次写入失败的错误，之后的写入都返回它
+}
+
+// NewPcapWriter 写入 pcapng 的节头和接口描述块，返回的 PcapWriter 继续写入数据包
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	// Section Header Block：字节序标记、版本 1.0、节长度未知
+	shb := binary.LittleEndian.AppendUint32(nil, pcapngByteOrder)
+	shb = binary.LittleEndian.AppendUint16(shb, 1)
+	shb = binary.LittleEndian.AppendUint16(shb, 0)
+	shb = binary.LittleEndian.AppendUint64(shb, ^uint64(0))
+
+	// Interface Description Block：LINKTYPE_USER0，不截断，纳秒时间戳
+	idb := binary.LittleEndian.AppendUint16(nil, LINKTYPE_USER0)
+	idb = binary.LittleEndian.AppendUint16(idb, 0)
+	idb = binary.LittleEndian.AppendUint32(idb, 0)
+	idb = appendPcapngOption(idb, pcapngOptTsresol, []byte{pcapngTsresolNanos})
+	idb = appendPcapngOption(idb, pcapngOptEnd, nil)
+
+	p := &PcapWriter{w: w}
+	if err := p.writeBlock(pcapngSHB, shb); err != nil {
+		return nil, err
+	}
+	if err := p.writeBlock(pcapngIDB, idb); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Write 写入一条记录
+func (p *PcapWriter) Write(rec CaptureRecord) error {
+	packet, err := rec.Msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	reason := 0
+	for i, r := range captureReasons {
+		if r == rec.Reason {
+			reason = i
+			break
+		}
+	}
+	data := []byte{CAPTURE_VERSION, rec.Event, uint8(reason), 0}
+	data = binary.BigEndian.AppendUint32(data, rec.Subnet)
+	data = append(data, packet...)
+
+	ts := uint64(rec.Time.UnixNano())
+	epb := binary.LittleEndian.AppendUint32(nil, 0) // 接口ID
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts>>32))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(data)))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(data)))
+	epb = append(epb, data...)
+	epb = append(epb, make([]byte, pad4(len(data)))...)
+	if rec.Event == CAPTURE_DROP {
+		epb = appendPcapngOption(epb, pcapngOptComment, []byte("drop: "+rec.Reason))
+		epb = appendPcapngOption(epb, pcapngOptEnd, nil)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.writeBlock(pcapngEPB, epb)
+}
+
+// writeBlock 写入一个块：类型、总长度、内容、总长度
+func (p *PcapWriter) writeBlock(blockType uint32, body []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	total := uint32(12 + len(body))
+	block := binary.LittleEndian.AppendUint32(nil, blockType)
+	block = binary.LittleEndian.AppendUint32(block, total)
+	block = append(block, body...)
+	block = binary.LittleEndian.AppendUint32(block, total)
+	_, p.err = p.w.Write(block)
+	return p.err
+}
+
+func appendPcapngOption(buf []byte, code uint16, value []byte) []byte {
+	buf = binary.LittleEndian.AppendUint16(buf, code)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(value)))
+	buf = append(buf, value...)
+	return append(buf, make([]byte, pad4(len(value)))...)
+}
+
+func pad4(n int) int {
+	return (4 - n%4) % 4
+}
+
+// ReadPcap 读取 PcapWriter 写出的抓包文件(小端字节序、单个 LINKTYPE_USER0 接口)，
+// 其他类型的块被跳过
+func ReadPcap(r io.Reader) ([]CaptureRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var records []CaptureRecord
+	for len(data) > 0 {
+		if len(data) < 12 {
+			return nil, fmt.Errorf("%w: truncated block", ErrPcapFormat)
+		}
+		blockType := binary.LittleEndian.Uint32(data[0:4])
+		total := int(binary.LittleEndian.Uint32(data[4:8]))
+		if total < 12 || total%4 != 0 || total > len(data) {
+			return nil, fmt.Errorf("%w: block length %d", ErrPcapFormat, total)
+		}
+		body := data[8 : total-4]
+		data = data[total:]
+
+		switch blockType {
+		case pcapngSHB:
+			if len(body) < 4 || binary.LittleEndian.Uint32(body[0:4]) != pcapngByteOrder {
+				return nil, fmt.Errorf("%w: not a little-endian section", ErrPcapFormat)
+			}
+		case pcapngIDB:
+			if len(body) < 2 || binary.LittleEndian.Uint16(body[0:2]) != LINKTYPE_USER0 {
+				return nil, fmt.Errorf("%w: unexpected link type", ErrPcapFormat)
+			}
+		case pcapngEPB:
+			rec, err := readCaptureRecord(body)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// readCaptureRecord 解析 Enhanced Packet Block 的内容
+func readCaptureRecord(body []byte) (CaptureRecord, error) {
+	if len(body) < 20 {
+		return CaptureRecord{}, fmt.Errorf("%w: short packet block", ErrPcapFormat)
+	}
+	ts := uint64(binary.LittleEndian.Uint32(body[4:8]))<<32 | uint64(binary.LittleEndian.Uint32(body[8:12]))
+	size := int(binary.LittleEndian.Uint32(body[12:16]))
+	data := body[20:]
+	if size > len(data) || size < CAPTURE_HEADER_LEN {
+		return CaptureRecord{}, fmt.Errorf("%w: captured length %d", ErrPcapFormat, size)
+	}
+	data = data[:size]
+	if data[0] != CAPTURE_VERSION || int(data[2]) >= len(captureReasons) {
+		return CaptureRecord{}, fmt.Errorf("%w: capture header %v", ErrPcapFormat, data[:4])
+	}
+
+	rec := CaptureRecord{
+		Time:   time.Unix(0, int64(ts)),
+		Event:  data[1],
+		Reason: captureReasons[data[2]],
+		Subnet: binary.BigEndian.Uint32(data[4:8]),
+	}
+	if err := rec.Msg.UnmarshalBinary(data[CAPTURE_HEADER_LEN:]); err != nil {
+		return CaptureRecord{}, err
+	}
+	return rec, nil
+}
+
+// SetCapture 把之后网络上的每一次发送、投递和丢弃写入 capture，nil 表示停止抓包
+func (network *Network) SetCapture(capture *PcapWriter) {
+	network.captureMux.Lock()
+	defer network.captureMux.Unlock()
+	network.capture = capture
+}
+
+// captureMessage 在开启抓包时记录一个事件
+func (network *Network) captureMessage(event uint8, msg vrr.Message, reason string) {
+	network.captureMux.RLock()
+	capture := network.capture
+	network.captureMux.RUnlock()
+	if capture == nil {
+		return
+	}
+
+	rec := CaptureRecord{
+		Time:   network.clock.Now(),
+		Event:  event,
+		Reason: reason,
+		Subnet: network.linkSubnet(hopSender(msg), msg.NextHop),
+		Msg:    msg,
+	}
+	if err := capture.Write(rec); err != nil {
+		network.logger.Warn(vrr.LOG_NETWORK, "failed to capture message", "type", vrr.GetMessageTypeString(msg.Type), "err", err)
+	}
+}
+
+// linkSubnet 返回 a 和 b 共同所在的第一个子网，没有时返回 0
+func (network *Network) linkSubnet(a, b uint32) uint32 {
+	network.topologyMux.RLock()
+	defer network.topologyMux.RUnlock()
+	peers := network.NodeToSubnet[b]
+	for _, subnetID := range network.NodeToSubnet[a] {
+		if containsSubnet(peers, subnetID) {
+			return subnetID
+		}
+	}
+	return 0
+}
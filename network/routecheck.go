@@ -0,0 +1,216 @@
+package network
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 路由表不变量检查：把所有节点路由表中的条目按 (PathId, Ea, Eb) 归并为路径，
+// 沿 Na/Nb 从 Ea 逐跳走到 Eb（走不通时再从 Eb 反向走），检查路径是否完整、无环、
+// 只经过已链接的物理邻居，并找出标识 <pid, ea> 重复的路径和拆除后遗留的孤立条目。
+
+// 路由表问题的类型
+const (
+	ROUTE_DANGLING  = "dangling"      // 半条路径：走到某个节点后下一跳没有该路径的条目，或提前终止
+	ROUTE_MISMATCH  = "mismatch"      // 相邻两跳的条目互相不指向对方
+	ROUTE_LOOP      = "loop"          // 沿路径前进时回到了已经经过的节点
+	ROUTE_UNLINKED  = "unlinked-hop"  // 下一跳不是 PSET_LINKED 状态的物理邻居
+	ROUTE_DUPLICATE = "duplicate-pid" // 同一个 <pid, ea> 被 Eb 不同的路径使用
+	ROUTE_ORPHAN    = "orphan"        // 从两个端点都无法到达的条目（通常是拆除后遗留的）
+)
+
+// RouteDiagnostic 描述路由表中的一处问题
+type RouteDiagnostic struct {
+	Kind   string // 问题类型，ROUTE_*
+	PathId uint32
+	Ea     uint32 // 路径的端点
+	Eb     uint32
+	Node   uint32 // 发现问题的节点
+	Peer   uint32 // 相关的下一跳或节点，0 表示无
+	Detail string
+}
+
+func (d RouteDiagnostic) String() string {
+	return fmt.Sprintf("path %d (%d-%d) at node %d: %s: %s", d.PathId, d.Ea, d.Eb, d.Node, d.Kind, d.Detail)
+}
+
+// routeKey 标识一条路径
+type routeKey struct {
+	pid, ea, eb uint32
+}
+
+// ValidateRoutes 检查所有已注册节点的路由表
+func (network *Network) ValidateRoutes() []RouteDiagnostic {
+	network.nodesMux.RLock()
+	nodes := make([]*vrr.Node, 0, len(network.Nodes))
+	for _, n := range network.Nodes {
+		nodes = append(nodes, n)
+	}
+	network.nodesMux.RUnlock()
+	return ValidateRoutes(nodes)
+}
+
+// ValidateRoutes 检查一组节点的路由表，返回按 PathId 排序的问题列表，没有问题时返回 nil。
+// 不在 nodes 中的节点视为没有任何条目
+func ValidateRoutes(nodes []*vrr.Node) []RouteDiagnostic {
+	byID := make(map[uint32]*vrr.Node, len(nodes))
+	paths := make(map[routeKey]map[uint32]vrr.RoutingTableEntry)
+	ends := make(map[[2]uint32]map[uint32]uint32) // <pid, ea> -> eb -> 发现该 eb 的节点
+	for _, n := range nodes {
+		byID[n.ID] = n
+		for _, entry := range n.RoutingTable.Routes() {
+			key := routeKey{entry.PathId, entry.Ea, entry.Eb}
+			if paths[key] == nil {
+				paths[key] = make(map[uint32]vrr.RoutingTableEntry)
+			}
+			paths[key][n.ID] = entry
+
+			id := [2]uint32{entry.PathId, entry.Ea}
+			if ends[id] == nil {
+				ends[id] = make(map[uint32]uint32)
+			}
+			if _, ok := ends[id][entry.Eb]; !ok {
+				ends[id][entry.Eb] = n.ID
+			}
+		}
+	}
+
+	var diags []RouteDiagnostic
+	for id, ebs := range ends {
+		if len(ebs) < 2 {
+			continue
+		}
+		for eb, node := range ebs {
+			diags = append(diags, RouteDiagnostic{
+				Kind: ROUTE_DUPLICATE, PathId: id[0], Ea: id[1], Eb: eb, Node: node,
+				Detail: fmt.Sprintf("<pid, ea> used by paths to %d different endpoints", len(ebs)),
+			})
+		}
+	}
+
+	for key, entries := range paths {
+		entryAt := func(node uint32) (vrr.RoutingTableEntry, bool) {
+			entry, ok := entries[node]
+			return entry, ok
+		}
+		visited := make(map[uint32]bool, len(entries))
+		walk := walkVsetPath(byID, entryAt, key, key.ea, key.eb)
+		walk.mark(visited)
+		if walk.problem != nil {
+			diags = append(diags, *walk.problem)
+		}
+		if !walk.complete {
+			// 从 Ea 走不通时再从 Eb 出发，标记另外半条路径上的条目
+			back := walkVsetPath(byID, entryAt, key, key.eb, key.ea)
+			back.mark(visited)
+			if _, ok := entries[key.ea]; !ok && back.problem != nil {
+				diags = append(diags, *back.problem)
+			}
+		}
+		for id := range entries {
+			if !visited[id] {
+				diags = append(diags, RouteDiagnostic{
+					Kind: ROUTE_ORPHAN, PathId: key.pid, Ea: key.ea, Eb: key.eb, Node: id,
+					Detail: "entry is not reachable from either endpoint",
+				})
+			}
+		}
+	}
+
+	sort.Slice(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.PathId != b.PathId {
+			return a.PathId < b.PathId
+		}
+		if a.Ea != b.Ea {
+			return a.Ea < b.Ea
+		}
+		if a.Eb != b.Eb {
+			return a.Eb < b.Eb
+		}
+		if a.Node != b.Node {
+			return a.Node < b.Node
+		}
+		return a.Kind < b.Kind
+	})
+	return diags
+}
+
+// pathWalk 是沿一条 vset-path 从一个端点走向另一个端点的结果
+type pathWalk struct {
+	hops     []PathHop        // 逐跳经过的链路
+	visited  []uint32         // 经过的有该路径条目的节点
+	complete bool             // 是否到达了另一个端点
+	problem  *RouteDiagnostic // 走不通的原因；起点没有该路径的条目时为 nil
+}
+
+// mark 把经过的节点记入 visited
+func (w pathWalk) mark(visited map[uint32]bool) {
+	for _, id := range w.visited {
+		visited[id] = true
+	}
+}
+
+// walkVsetPath 沿路径 key 从端点 from 逐跳走向端点 to，entryAt 返回节点上该路径的条目。
+// 每一跳都要有该路径的条目，条目朝向 from 的下一跳指回上一跳，朝向 to 的下一跳是已链接的物理邻居，
+// 且不能回到已经经过的节点。路由表检查、虚拟环检查和 DOT 导出都通过它遍历路径；
+// 不在 nodes 中的节点不检查链路状态
+func walkVsetPath(nodes map[uint32]*vrr.Node, entryAt func(node uint32) (vrr.RoutingTableEntry, bool), key routeKey, from, to uint32) pathWalk {
+	var w pathWalk
+	fail := func(kind string, node, peer uint32, format string, args ...any) pathWalk {
+		w.problem = &RouteDiagnostic{
+			Kind: kind, PathId: key.pid, Ea: key.ea, Eb: key.eb, Node: node, Peer: peer,
+			Detail: fmt.Sprintf(format, args...),
+		}
+		return w
+	}
+
+	seen := make(map[uint32]bool)
+	prev, cur := uint32(0), from
+	for {
+		entry, ok := entryAt(cur)
+		if !ok {
+			if prev == 0 {
+				return w
+			}
+			return fail(ROUTE_DANGLING, prev, cur, "next hop %d has no entry for the path", cur)
+		}
+		if seen[cur] {
+			return fail(ROUTE_LOOP, prev, cur, "path towards %d returns to node %d", to, cur)
+		}
+		seen[cur] = true
+		w.visited = append(w.visited, cur)
+
+		if back := nextTowards(entry, from); back != prev {
+			return fail(ROUTE_MISMATCH, cur, prev, "entry points back to %d instead of %d", back, prev)
+		}
+		next := nextTowards(entry, to)
+		if cur == to {
+			if next != 0 {
+				return fail(ROUTE_MISMATCH, cur, next, "endpoint still has next hop %d", next)
+			}
+			w.complete = true
+			return w
+		}
+		if next == 0 {
+			return fail(ROUTE_DANGLING, cur, 0, "path ends before reaching %d", to)
+		}
+		if n, ok := nodes[cur]; ok && n.PsetManager.GetStatus(next) != vrr.PSET_LINKED {
+			return fail(ROUTE_UNLINKED, cur, next, "next hop %d towards %d is not a linked neighbor", next, to)
+		}
+		w.hops = append(w.hops, PathHop{cur, next})
+		prev, cur = cur, next
+	}
+}
+
+// entryIn 在路由条目中查找路径 key 的条目
+func entryIn(entries []vrr.RoutingTableEntry, key routeKey) (vrr.RoutingTableEntry, bool) {
+	for _, entry := range entries {
+		if entry.PathId == key.pid && entry.Ea == key.ea && entry.Eb == key.eb {
+			return entry, true
+		}
+	}
+	return vrr.RoutingTableEntry{}, false
+}
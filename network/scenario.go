@@ -0,0 +1,307 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+/*
+故障注入场景文件（JSON）示例：
+
+	{
+	  "events": [
+	    {"at_ms": 5000,  "kind": "crash",        "node": 8082},
+	    {"at_ms": 8000,  "kind": "subnet-leave", "node": 8083, "subnets": [2]},
+	    {"at_ms": 10000, "kind": "loss-spike",   "subnets": [1], "loss": 0.5, "duration_ms": 2000},
+	    {"at_ms": 12000, "kind": "subnet-join",  "node": 8083, "subnets": [2]},
+	    {"at_ms": 15000, "kind": "link-cut",     "node": 8084, "peer": 8085},
+	    {"at_ms": 18000, "kind": "link-heal",    "node": 8084, "peer": 8085},
+	    {"at_ms": 20000, "kind": "restart",      "node": 8082}
+	  ]
+	}
+
+事件时间相对于 Sim.Schedule 被调用的时刻。
+*/
+
+// 场景事件类型
+const (
+	EVENT_CRASH        = "crash"        // 节点停止并从网络注销
+	EVENT_RESTART      = "restart"      // 节点清空状态后重新加入崩溃前所在的子网并启动
+	EVENT_LINK_CUT     = "link-cut"     // 切断 node 与 peer 之间的双向链路（丢包率 1）
+	EVENT_LINK_HEAL    = "link-heal"    // 恢复 link-cut 之前的链路设置
+	EVENT_SUBNET_LEAVE = "subnet-leave" // 节点离开指定子网
+	EVENT_SUBNET_JOIN  = "subnet-join"  // 节点加入指定子网
+	EVENT_LOSS_SPIKE   = "loss-spike"   // 指定子网（未指定时为全局）的丢包率在 duration 内升高
+)
+
+var eventKinds = []string{EVENT_CRASH, EVENT_RESTART, EVENT_LINK_CUT, EVENT_LINK_HEAL,
+	EVENT_SUBNET_LEAVE, EVENT_SUBNET_JOIN, EVENT_LOSS_SPIKE}
+
+// ScenarioEvent 描述一个定时的拓扑事件
+type ScenarioEvent struct {
+	At       float64  `json:"at_ms"`
+	Kind     string   `json:"kind"`
+	Node     uint32   `json:"node,omitempty"`
+	Peer     uint32   `json:"peer,omitempty"`
+	Subnets  []uint32 `json:"subnets,omitempty"`
+	Loss     float32  `json:"loss,omitempty"`
+	Duration float64  `json:"duration_ms,omitempty"`
+}
+
+// Scenario 是一组按时间调度的故障注入事件
+type Scenario struct {
+	Events []ScenarioEvent `json:"events"`
+}
+
+// LoadScenario 从 JSON 文件读取场景
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("network: read scenario: %w", err)
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario 解析并校验 JSON 格式的场景
+func ParseScenario(data []byte) (*Scenario, error) {
+	var sc Scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("network: parse scenario: %w", err)
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// Validate 检查事件类型和每类事件需要的参数
+func (sc *Scenario) Validate() error {
+	for i, ev := range sc.Events {
+		known := false
+		for _, k := range eventKinds {
+			if ev.Kind == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("network: event %d: unknown kind %q", i, ev.Kind)
+		}
+		if ev.At < 0 || ev.Duration < 0 {
+			return fmt.Errorf("network: event %d: negative time", i)
+		}
+
+		switch ev.Kind {
+		case EVENT_CRASH, EVENT_RESTART:
+			if ev.Node == 0 {
+				return fmt.Errorf("network: event %d: %s needs a node", i, ev.Kind)
+			}
+		case EVENT_LINK_CUT, EVENT_LINK_HEAL:
+			if ev.Node == 0 || ev.Peer == 0 {
+				return fmt.Errorf("network: event %d: %s needs a node and a peer", i, ev.Kind)
+			}
+		case EVENT_SUBNET_LEAVE, EVENT_SUBNET_JOIN:
+			if ev.Node == 0 || len(ev.Subnets) == 0 {
+				return fmt.Errorf("network: event %d: %s needs a node and subnets", i, ev.Kind)
+			}
+		case EVENT_LOSS_SPIKE:
+			if ev.Loss < 0 || ev.Loss > 1 {
+				return fmt.Errorf("network: event %d: loss %v out of range [0, 1]", i, ev.Loss)
+			}
+		}
+	}
+	return nil
+}
+
+// TimelineEntry 是时间线上的一条记录，At 为相对于场景开始的时间
+type TimelineEntry struct {
+	At     time.Duration
+	Kind   string
+	Detail string
+}
+
+// Timeline 按发生顺序记录场景事件，以及调用者通过 Record 添加的观测（例如收敛时刻）
+type Timeline struct {
+	clock   vrr.Clock
+	start   time.Time
+	mu      sync.Mutex
+	entries []TimelineEntry
+}
+
+// Record 在当前时刻记录一条时间线条目
+func (tl *Timeline) Record(kind, format string, args ...any) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.entries = append(tl.entries, TimelineEntry{
+		At:     tl.clock.Now().Sub(tl.start),
+		Kind:   kind,
+		Detail: fmt.Sprintf(format, args...),
+	})
+}
+
+// Entries 返回时间线条目的副本
+func (tl *Timeline) Entries() []TimelineEntry {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	return append([]TimelineEntry(nil), tl.entries...)
+}
+
+// String 返回时间线的可读表示，每行一个条目
+func (tl *Timeline) String() string {
+	var b strings.Builder
+	for _, e := range tl.Entries() {
+		fmt.Fprintf(&b, "%10v  %-12s %s\n", e.At, e.Kind, e.Detail)
+	}
+	return b.String()
+}
+
+// scenarioState 保存事件之间需要传递的状态
+type scenarioState struct {
+	mu        sync.Mutex
+	subnets   map[uint32][]uint32     // 崩溃节点崩溃前所在的子网
+	savedLoss map[uint32]*LinkProfile // loss-spike 之前的子网设置，nil 表示没有单独设置
+	timeline  *Timeline
+}
+
+// Schedule 在仿真时钟上调度场景中的所有事件，返回随事件执行而填充的时间线。
+// 虚拟时钟下事件在 Run 推进到对应时刻时执行。
+func (s *Sim) Schedule(sc *Scenario) (*Timeline, error) {
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	for i, ev := range sc.Events {
+		for _, id := range []uint32{ev.Node, ev.Peer} {
+			if id != 0 && s.Node(id) == nil {
+				return nil, fmt.Errorf("network: event %d: unknown node %d", i, id)
+			}
+		}
+	}
+
+	state := &scenarioState{
+		subnets:   make(map[uint32][]uint32),
+		savedLoss: make(map[uint32]*LinkProfile),
+		timeline:  &Timeline{clock: s.Clock, start: s.Clock.Now()},
+	}
+
+	// 按时间排序，同一时刻的事件保持文件中的顺序
+	events := append([]ScenarioEvent(nil), sc.Events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+	for _, ev := range events {
+		ev := ev
+		s.Clock.AfterFunc(millis(ev.At), func() {
+			s.apply(state, ev)
+		})
+	}
+	return state.timeline, nil
+}
+
+// apply 执行一个场景事件并记录到时间线
+func (s *Sim) apply(state *scenarioState, ev ScenarioEvent) {
+	network := s.Network
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	switch ev.Kind {
+	case EVENT_CRASH:
+		node := s.Node(ev.Node)
+		state.subnets[ev.Node] = network.GetSubnets(ev.Node)
+		node.Stop()
+		network.UnregisterNode(ev.Node)
+		state.timeline.Record(ev.Kind, "node %d crashed (subnets %v)", ev.Node, state.subnets[ev.Node])
+
+	case EVENT_RESTART:
+		node := s.Node(ev.Node)
+		subnets, ok := state.subnets[ev.Node]
+		if !ok {
+			subnets = network.GetSubnets(ev.Node)
+		}
+		node.Stop()
+		node.Reset()
+		network.JoinSubnets(node, subnets...)
+		node.Start()
+		delete(state.subnets, ev.Node)
+		state.timeline.Record(ev.Kind, "node %d restarted in subnets %v", ev.Node, subnets)
+
+	case EVENT_LINK_CUT:
+		network.CutLink(ev.Node, ev.Peer)
+		state.timeline.Record(ev.Kind, "link %d <-> %d cut", ev.Node, ev.Peer)
+
+	case EVENT_LINK_HEAL:
+		network.HealLink(ev.Node, ev.Peer)
+		state.timeline.Record(ev.Kind, "link %d <-> %d healed", ev.Node, ev.Peer)
+
+	case EVENT_SUBNET_LEAVE:
+		network.UnregisterNodeFromSubnets(ev.Node, ev.Subnets...)
+		state.timeline.Record(ev.Kind, "node %d left subnets %v", ev.Node, ev.Subnets)
+
+	case EVENT_SUBNET_JOIN:
+		network.JoinSubnets(s.Node(ev.Node), ev.Subnets...)
+		state.timeline.Record(ev.Kind, "node %d joined subnets %v", ev.Node, ev.Subnets)
+
+	case EVENT_LOSS_SPIKE:
+		s.applyLossSpike(state, ev)
+	}
+}
+
+// applyLossSpike 提高子网（或全局）的丢包率，duration 之后恢复原设置
+func (s *Sim) applyLossSpike(state *scenarioState, ev ScenarioEvent) {
+	network := s.Network
+
+	if len(ev.Subnets) == 0 {
+		prev := network.SetPacketLoss(ev.Loss)
+		state.timeline.Record(ev.Kind, "global packet loss %.2f -> %.2f", prev, ev.Loss)
+		if ev.Duration > 0 {
+			s.Clock.AfterFunc(millis(ev.Duration), func() {
+				network.SetPacketLoss(prev)
+				state.timeline.Record(ev.Kind, "global packet loss restored to %.2f", prev)
+			})
+		}
+		return
+	}
+
+	network.linkMux.Lock()
+	for _, subnetID := range ev.Subnets {
+		if _, saved := state.savedLoss[subnetID]; saved {
+			continue
+		}
+		if prev, ok := network.subnetProfiles[subnetID]; ok {
+			state.savedLoss[subnetID] = &prev
+		} else {
+			state.savedLoss[subnetID] = nil
+		}
+	}
+	network.linkMux.Unlock()
+
+	for _, subnetID := range ev.Subnets {
+		profile := LinkProfile{Dist: LATENCY_FIXED, Latency: network.Latency}
+		if prev := state.savedLoss[subnetID]; prev != nil {
+			profile = *prev
+		}
+		profile.PacketLoss = ev.Loss
+		network.SetSubnetProfile(subnetID, profile)
+	}
+	state.timeline.Record(ev.Kind, "subnets %v packet loss -> %.2f", ev.Subnets, ev.Loss)
+
+	if ev.Duration > 0 {
+		s.Clock.AfterFunc(millis(ev.Duration), func() {
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			for _, subnetID := range ev.Subnets {
+				if prev := state.savedLoss[subnetID]; prev != nil {
+					network.SetSubnetProfile(subnetID, *prev)
+				} else {
+					network.ClearSubnetProfile(subnetID)
+				}
+				delete(state.savedLoss, subnetID)
+			}
+			state.timeline.Record(ev.Kind, "subnets %v packet loss restored", ev.Subnets)
+		})
+	}
+}
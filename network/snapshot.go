@@ -0,0 +1,283 @@
+package network
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 状态快照：节点的 pset、vset、路由表和指标，子网拓扑，网络层统计和虚拟环检查结果，
+// 以可直接编码为 JSON 的形式输出，供管理接口和 cmd/vrrsim 使用。
+
+// Snapshot 是网络在某一时刻的完整状态
+type Snapshot struct {
+	Nodes    []NodeDetail `json:"nodes"`
+	Topology TopologyInfo `json:"topology"`
+	Stats    StatsInfo    `json:"stats"`
+	Ring     RingInfo     `json:"ring"`
+}
+
+// NodeSummary 是节点的概要
+type NodeSummary struct {
+	ID       uint32   `json:"id"`
+	Active   bool     `json:"active"`
+	Stopped  bool     `json:"stopped"`
+	RingID   uint32   `json:"ring_id"`
+	Subnets  []uint32 `json:"subnets"`
+	Pset     int      `json:"pset"`
+	VsetSize int      `json:"vset_size"`
+	Routes   int      `json:"routes"`
+}
+
+// NodeDetail 是节点的完整状态
+type NodeDetail struct {
+	NodeSummary
+	PsetNodes []PsetInfo        `json:"pset_nodes"`
+	PsetState PsetStateInfo     `json:"pset_state"`
+	Vset      []uint32          `json:"vset"`
+	RouteList []RouteInfo       `json:"route_list"`
+	Counters  map[string][]Stat `json:"counters"`
+}
+
+// PsetInfo 是一个物理邻居
+type PsetInfo struct {
+	ID            uint32 `json:"id"`
+	Status        string `json:"status"`
+	Active        bool   `json:"active"`
+	FailCount     int32  `json:"fail_count"`
+	Candidate     uint32 `json:"candidate,omitempty"`
+	CandidateHops uint8  `json:"candidate_hops,omitempty"`
+}
+
+// PsetStateInfo 是按状态分类的物理邻居
+type PsetStateInfo struct {
+	LinkActive    []uint32 `json:"link_active"`
+	LinkNotActive []uint32 `json:"link_not_active"`
+	Pending       []uint32 `json:"pending"`
+}
+
+// RouteInfo 是一个路由条目
+type RouteInfo struct {
+	PathID uint32 `json:"path_id"`
+	Ea     uint32 `json:"ea"`
+	Eb     uint32 `json:"eb"`
+	Na     uint32 `json:"na"`
+	Nb     uint32 `json:"nb"`
+}
+
+// Stat 是指标的一个样本，标签中不含 node
+type Stat struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// LinkInfo 是一条物理链路及两端记录的 pset 状态
+type LinkInfo struct {
+	A       uint32 `json:"a"`
+	B       uint32 `json:"b"`
+	StatusA string `json:"status_a"` // A 记录的 B 的状态
+	StatusB string `json:"status_b"` // B 记录的 A 的状态
+}
+
+// TopologyInfo 是网络的子网拓扑
+type TopologyInfo struct {
+	Subnets map[uint32][]uint32 `json:"subnets"`
+	Links   []LinkInfo          `json:"links"`
+}
+
+// StatsInfo 是网络层的消息统计
+type StatsInfo struct {
+	Total         uint64            `json:"total"`
+	Dropped       uint64            `json:"dropped"`
+	Transmissions map[string]uint64 `json:"transmissions"` // 按消息类型
+	Drops         map[string]uint64 `json:"drops"`         // 按丢弃原因
+}
+
+// FormatCounts 按名称排序输出 StatsInfo 中的计数，形如 "name=count name=count"，没有计数时为 "none"
+func FormatCounts(counts map[string]uint64) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%d", name, counts[name])
+	}
+	return strings.Join(parts, " ")
+}
+
+// RingInfo 是虚拟环一致性检查的结果
+type RingInfo struct {
+	OK       bool       `json:"ok"`
+	Rings    [][]uint32 `json:"rings"`
+	Problems []string   `json:"problems"`
+}
+
+// Snapshot 返回网络当前的完整状态，节点按ID排序
+func (network *Network) Snapshot() Snapshot {
+	nodes := network.nodeSnapshot()
+	ids := make([]uint32, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	snap := Snapshot{
+		Nodes:    make([]NodeDetail, 0, len(ids)),
+		Topology: network.topologyInfo(),
+		Stats:    network.statsInfo(),
+		Ring:     network.ringInfo(),
+	}
+	for _, id := range ids {
+		snap.Nodes = append(snap.Nodes, network.nodeDetail(nodes[id]))
+	}
+	return snap
+}
+
+func (network *Network) nodeSummary(n *vrr.Node) NodeSummary {
+	return NodeSummary{
+		ID:       n.ID,
+		Active:   n.IsActive(),
+		Stopped:  n.Stopped(),
+		RingID:   n.GetRingID(),
+		Subnets:  idList(network.GetSubnets(n.ID)),
+		Pset:     len(n.PsetManager.GetAll()),
+		VsetSize: len(n.VsetManager.GetAll()),
+		Routes:   len(n.RoutingTable.Routes()),
+	}
+}
+
+func (network *Network) nodeDetail(n *vrr.Node) NodeDetail {
+	return NodeDetail{
+		NodeSummary: network.nodeSummary(n),
+		PsetNodes:   psetInfo(n),
+		PsetState:   psetStateInfo(n),
+		Vset:        idList(n.VsetManager.GetAll()),
+		RouteList:   routeInfo(n),
+		Counters:    counterInfo(n.Collect()),
+	}
+}
+
+func psetInfo(n *vrr.Node) []PsetInfo {
+	pset := n.PsetManager.GetAll()
+	info := make([]PsetInfo, 0, len(pset))
+	for _, p := range pset {
+		info = append(info, PsetInfo{
+			ID:            p.NodeId,
+			Status:        vrr.PsetStatusString(p.Status),
+			Active:        p.Active,
+			FailCount:     p.FailCount,
+			Candidate:     p.Candidate,
+			CandidateHops: p.CandidateHops,
+		})
+	}
+	sort.Slice(info, func(i, j int) bool { return info[i].ID < info[j].ID })
+	return info
+}
+
+func psetStateInfo(n *vrr.Node) PsetStateInfo {
+	linkActive, linkNotActive, pending := n.PsetStateManager.Snapshot()
+	return PsetStateInfo{LinkActive: idList(linkActive), LinkNotActive: idList(linkNotActive), Pending: idList(pending)}
+}
+
+func routeInfo(n *vrr.Node) []RouteInfo {
+	routes := n.RoutingTable.Routes()
+	info := make([]RouteInfo, 0, len(routes))
+	for _, r := range routes {
+		info = append(info, RouteInfo{PathID: r.PathId, Ea: r.Ea, Eb: r.Eb, Na: r.Na, Nb: r.Nb})
+	}
+	return info
+}
+
+// counterInfo 按指标名称整理样本，去掉 node 标签
+func counterInfo(families []vrr.MetricFamily) map[string][]Stat {
+	counters := make(map[string][]Stat, len(families))
+	for _, f := range families {
+		stats := make([]Stat, 0, len(f.Samples))
+		for _, sample := range f.Samples {
+			stat := Stat{Value: sample.Value}
+			for _, l := range sample.Labels {
+				if l.Name == "node" {
+					continue
+				}
+				if stat.Labels == nil {
+					stat.Labels = make(map[string]string)
+				}
+				stat.Labels[l.Name] = l.Value
+			}
+			stats = append(stats, stat)
+		}
+		counters[f.Name] = stats
+	}
+	return counters
+}
+
+func (network *Network) topologyInfo() TopologyInfo {
+	nodes := network.nodeSnapshot()
+
+	info := TopologyInfo{Subnets: make(map[uint32][]uint32), Links: []LinkInfo{}}
+	network.topologyMux.RLock()
+	for subnet, members := range network.SubnetTopology {
+		info.Subnets[subnet] = idList(members)
+	}
+	network.topologyMux.RUnlock()
+
+	for _, link := range network.physicalLinks(nodes) {
+		a, b := link[0], link[1]
+		info.Links = append(info.Links, LinkInfo{
+			A:       a,
+			B:       b,
+			StatusA: vrr.PsetStatusString(nodes[a].PsetManager.GetStatus(b)),
+			StatusB: vrr.PsetStatusString(nodes[b].PsetManager.GetStatus(a)),
+		})
+	}
+	return info
+}
+
+func (network *Network) statsInfo() StatsInfo {
+	total, dropped := network.GetMsgInfo()
+	info := StatsInfo{
+		Total:         total,
+		Dropped:       dropped,
+		Transmissions: make(map[string]uint64),
+		Drops:         make(map[string]uint64),
+	}
+	for _, sample := range network.transmissions.Samples() {
+		info.Transmissions[labelValue(sample.Labels, "type")] += uint64(sample.Value)
+	}
+	for _, sample := range network.drops.Samples() {
+		info.Drops[labelValue(sample.Labels, "reason")] += uint64(sample.Value)
+	}
+	return info
+}
+
+func (network *Network) ringInfo() RingInfo {
+	report := network.CheckRing()
+	info := RingInfo{OK: report.OK(), Rings: report.Rings, Problems: []string{}}
+	for _, p := range report.Problems {
+		info.Problems = append(info.Problems, p.String())
+	}
+	return info
+}
+
+// idList 返回排序后的ID列表，nil 变为空列表以便输出 []
+func idList(ids []uint32) []uint32 {
+	sorted := append([]uint32{}, ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+func labelValue(labels []vrr.Label, name string) string {
+	for _, l := range labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,503 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+/*
+拓扑描述文件（JSON）示例：
+
+	{
+	  "latency_ms": 50,
+	  "packet_loss": 0,
+	  "seed": 42,
+	  "virtual": true,
+	  "bootstrap": "timeout",
+	  "config": {"vset_size": 8, "hello_ms": 500, "hello_jitter_ms": 300},
+	  "nodes": [
+	    {"id": 8085, "subnets": [1], "active": true},
+	    {"id": 8082, "subnets": [1, 2]},
+	    {"id": 8083, "subnets": [2]}
+	  ],
+	  "subnets": [
+	    {"id": 2, "dist": "uniform", "latency_ms": 30, "jitter_ms": 10}
+	  ],
+	  "links": [
+	    {"src": 8082, "dst": 8083, "bidirectional": true, "packet_loss": 0.1, "bandwidth_bps": 1000000}
+	  ]
+	}
+
+节点之间的连通性由子网（广播域）决定；点对点链路用只包含两个节点的子网表示。
+*/
+
+// Topology 描述一次仿真的网络拓扑
+type Topology struct {
+	Latency    float64 `json:"latency_ms"`          // 全局默认延迟（毫秒）
+	PacketLoss float32 `json:"packet_loss"`         // 全局默认丢包率
+	Seed       int64   `json:"seed,omitempty"`      // 随机种子，0 表示不固定
+	Virtual    bool    `json:"virtual,omitempty"`   // 是否在虚拟时钟上运行
+	Bootstrap  string  `json:"bootstrap,omitempty"` // 自举模式，默认 "timeout"
+
+	Config *NodeConfigSpec `json:"config,omitempty"` // 所有节点的协议参数，省略时使用默认值
+
+	Nodes   []NodeSpec   `json:"nodes"`
+	Subnets []SubnetSpec `json:"subnets,omitempty"` // 子网的链路特性
+	Links   []LinkSpec   `json:"links,omitempty"`   // 单条链路的特性
+}
+
+// NodeSpec 描述一个节点
+type NodeSpec struct {
+	ID      uint32   `json:"id"`
+	Subnets []uint32 `json:"subnets"`
+	Active  bool     `json:"active,omitempty"` // 是否作为初始活跃节点自举
+}
+
+// NodeConfigSpec 是 vrr.Config 中协议参数的文件表示，省略的字段使用默认值
+type NodeConfigSpec struct {
+	VsetSize      int     `json:"vset_size,omitempty"`
+	PsetSize      int     `json:"pset_size,omitempty"`
+	FailTimeout   int     `json:"fail_timeout,omitempty"`   // HELLO 周期数
+	ActiveTimeout int     `json:"active_timeout,omitempty"` // HELLO 周期数
+	HelloInterval float64 `json:"hello_ms,omitempty"`
+	HelloJitter   float64 `json:"hello_jitter_ms,omitempty"`
+	InboxSize     int     `json:"inbox_size,omitempty"`
+	RecvSize      int     `json:"recv_size,omitempty"`
+}
+
+// ProfileSpec 是 LinkProfile 的文件表示
+type ProfileSpec struct {
+	Dist       string  `json:"dist,omitempty"` // "fixed"（默认）、"uniform"、"normal"
+	Latency    float64 `json:"latency_ms,omitempty"`
+	Jitter     float64 `json:"jitter_ms,omitempty"`
+	PacketLoss float32 `json:"packet_loss,omitempty"`
+	Bandwidth  uint64  `json:"bandwidth_bps,omitempty"`
+}
+
+// SubnetSpec 描述一个子网的链路特性
+type SubnetSpec struct {
+	ID uint32 `json:"id"`
+	ProfileSpec
+}
+
+// LinkSpec 描述 src -> dst 链路的特性，Bidirectional 时同时设置反方向
+type LinkSpec struct {
+	Src           uint32 `json:"src"`
+	Dst           uint32 `json:"dst"`
+	Bidirectional bool   `json:"bidirectional,omitempty"`
+	ProfileSpec
+}
+
+var latencyDists = []string{"fixed", "uniform", "normal"}
+
+// Profile 将文件表示转换为 LinkProfile
+func (p ProfileSpec) Profile() (LinkProfile, error) {
+	dist := LATENCY_FIXED
+	if p.Dist != "" {
+		found := false
+		for i, name := range latencyDists {
+			if name == p.Dist {
+				dist, found = LatencyDist(i), true
+				break
+			}
+		}
+		if !found {
+			return LinkProfile{}, fmt.Errorf("network: unknown latency distribution %q", p.Dist)
+		}
+	}
+	if p.Latency < 0 || p.Jitter < 0 {
+		return LinkProfile{}, fmt.Errorf("network: negative latency or jitter")
+	}
+	if p.PacketLoss < 0 || p.PacketLoss > 1 {
+		return LinkProfile{}, fmt.Errorf("network: packet loss %v out of range [0, 1]", p.PacketLoss)
+	}
+	return LinkProfile{
+		Dist:       dist,
+		Latency:    millis(p.Latency),
+		Jitter:     millis(p.Jitter),
+		PacketLoss: p.PacketLoss,
+		Bandwidth:  p.Bandwidth,
+	}, nil
+}
+
+// NodeConfig 返回拓扑中节点共用的参数（不含时钟和随机种子，由 Build 按节点设置）
+func (t *Topology) NodeConfig() vrr.Config {
+	config := vrr.DefaultConfig()
+	if t.Bootstrap != "" {
+		config.Bootstrap, _ = vrr.ParseBootstrapMode(t.Bootstrap)
+	}
+	if c := t.Config; c != nil {
+		config.VsetSize = c.VsetSize
+		config.PsetSize = c.PsetSize
+		config.FailTimeout = c.FailTimeout
+		config.ActiveTimeout = c.ActiveTimeout
+		config.HelloInterval = millis(c.HelloInterval)
+		config.HelloJitter = millis(c.HelloJitter)
+		config.InboxSize = c.InboxSize
+		config.RecvSize = c.RecvSize
+	}
+	return config
+}
+
+// LoadTopology 从 JSON 文件读取拓扑
+func LoadTopology(path string) (*Topology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("network: read topology: %w", err)
+	}
+	return ParseTopology(data)
+}
+
+// ParseTopology 解析并校验 JSON 格式的拓扑
+func ParseTopology(data []byte) (*Topology, error) {
+	var topo Topology
+	if err := json.Unmarshal(data, &topo); err != nil {
+		return nil, fmt.Errorf("network: parse topology: %w", err)
+	}
+	if err := topo.Validate(); err != nil {
+		return nil, err
+	}
+	return &topo, nil
+}
+
+// Save 将拓扑写入 JSON 文件
+func (t *Topology) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Validate 检查节点ID唯一且非0、每个节点至少属于一个子网、链路引用的节点存在
+func (t *Topology) Validate() error {
+	if t.Latency < 0 || t.PacketLoss < 0 || t.PacketLoss > 1 {
+		return fmt.Errorf("network: invalid global latency %vms or packet loss %v", t.Latency, t.PacketLoss)
+	}
+	if t.Bootstrap != "" {
+		if _, err := vrr.ParseBootstrapMode(t.Bootstrap); err != nil {
+			return err
+		}
+	}
+	if err := t.NodeConfig().Validate(); err != nil {
+		return fmt.Errorf("network: node config: %w", err)
+	}
+	if len(t.Nodes) == 0 {
+		return fmt.Errorf("network: topology has no nodes")
+	}
+
+	ids := make(map[uint32]bool, len(t.Nodes))
+	for _, n := range t.Nodes {
+		if n.ID == 0 {
+			return fmt.Errorf("network: node ID 0 is reserved")
+		}
+		if ids[n.ID] {
+			return fmt.Errorf("network: duplicate node ID %d", n.ID)
+		}
+		if len(n.Subnets) == 0 {
+			return fmt.Errorf("network: node %d is not in any subnet", n.ID)
+		}
+		ids[n.ID] = true
+	}
+	for _, s := range t.Subnets {
+		if _, err := s.Profile(); err != nil {
+			return fmt.Errorf("network: subnet %d: %w", s.ID, err)
+		}
+	}
+	for _, l := range t.Links {
+		if !ids[l.Src] || !ids[l.Dst] {
+			return fmt.Errorf("network: link %d -> %d references an unknown node", l.Src, l.Dst)
+		}
+		if _, err := l.Profile(); err != nil {
+			return fmt.Errorf("network: link %d -> %d: %w", l.Src, l.Dst, err)
+		}
+	}
+	return nil
+}
+
+// Sim 是根据拓扑构建出的仿真实例：网络、时钟和按ID排序的节点
+type Sim struct {
+	Network *Network
+	Clock   vrr.Clock
+	Nodes   []*vrr.Node
+	byID    map[uint32]*vrr.Node
+	config  vrr.Config // 拓扑中的节点参数，AddNode 使用
+	seed    int64      // 拓扑的随机种子，0 表示不固定
+}
+
+// Build 创建网络和节点，按拓扑注册到子网并设置链路特性，节点尚未启动
+func (t *Topology) Build() (*Sim, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	network := NewNetwork(millis(t.Latency), t.PacketLoss)
+	var clock vrr.Clock = vrr.RealClock
+	if t.Virtual {
+		clock = vrr.NewVirtualClock(time.Unix(0, 0))
+	}
+	network.SetClock(clock)
+	if t.Seed != 0 {
+		network.SetSeed(t.Seed)
+	}
+	config := t.NodeConfig()
+	config.Clock = clock
+
+	for _, s := range t.Subnets {
+		profile, _ := s.Profile()
+		network.SetSubnetProfile(s.ID, profile)
+	}
+	for _, l := range t.Links {
+		profile, _ := l.Profile()
+		network.SetLinkProfile(l.Src, l.Dst, profile)
+		if l.Bidirectional {
+			network.SetLinkProfile(l.Dst, l.Src, profile)
+		}
+	}
+
+	sim := &Sim{Network: network, Clock: clock, byID: make(map[uint32]*vrr.Node, len(t.Nodes)), config: config, seed: t.Seed}
+	specs := append([]NodeSpec(nil), t.Nodes...)
+	sort.Slice(specs, func(i, j int) bool { return specs[i].ID < specs[j].ID })
+	for _, spec := range specs {
+		if t.Seed != 0 {
+			config.Seed = t.Seed + int64(spec.ID)
+		}
+		n, err := vrr.NewNodeWithConfig(spec.ID, network, config)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Active {
+			n.SetActive(true)
+		}
+		network.RegisterNode(n, spec.Subnets...)
+		sim.Nodes = append(sim.Nodes, n)
+		sim.byID[spec.ID] = n
+	}
+	return sim, nil
+}
+
+// Node 按ID查找节点
+func (s *Sim) Node(id uint32) *vrr.Node {
+	return s.byID[id]
+}
+
+// AddNode 用拓扑中的节点参数创建一个新节点并加入 subnets，节点尚未启动
+func (s *Sim) AddNode(id uint32, subnets ...uint32) (*vrr.Node, error) {
+	if id == 0 {
+		return nil, errors.New("network: node id must be non-zero")
+	}
+	if s.byID[id] != nil {
+		return nil, fmt.Errorf("network: duplicate node %d", id)
+	}
+	config := s.config
+	if s.seed != 0 {
+		config.Seed = s.seed + int64(id)
+	}
+	n, err := vrr.NewNodeWithConfig(id, s.Network, config)
+	if err != nil {
+		return nil, err
+	}
+	s.Network.RegisterNode(n, subnets...)
+	s.Nodes = append(s.Nodes, n)
+	sort.Slice(s.Nodes, func(i, j int) bool { return s.Nodes[i].ID < s.Nodes[j].ID })
+	s.byID[id] = n
+	return n, nil
+}
+
+// SetLogger 设置网络和所有节点的日志
+func (s *Sim) SetLogger(logger *vrr.Logger) {
+	s.Network.SetLogger(logger)
+	for _, n := range s.Nodes {
+		n.SetLogger(logger)
+	}
+}
+
+// SetTracer 设置网络和所有节点的追踪钩子，可以在节点运行时调用
+func (s *Sim) SetTracer(tracer vrr.Tracer) {
+	s.Network.SetTracer(tracer)
+	for _, n := range s.Nodes {
+		n.SetTracer(tracer)
+	}
+}
+
+// Start 启动所有节点
+func (s *Sim) Start() {
+	for _, n := range s.Nodes {
+		n.Start()
+	}
+}
+
+// Stop 停止所有节点
+func (s *Sim) Stop() {
+	for _, n := range s.Nodes {
+		n.Stop()
+	}
+}
+
+// Run 运行仿真 d：虚拟时钟下推进虚拟时间，否则等待真实时间
+func (s *Sim) Run(d time.Duration) {
+	if vc, ok := s.Clock.(*vrr.VirtualClock); ok {
+		vc.RunFor(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+// -----------------------拓扑生成器-----------------------
+// 生成的拓扑中节点ID从 baseID 开始连续编号，每条物理链路是一个只包含两个节点的子网，
+// 子网ID从1开始编号，孤立节点单独放在一个子网中；第一个节点被设置为初始活跃节点。
+
+// LineTopology 生成 n 个节点的链状拓扑
+func LineTopology(n int, baseID uint32) *Topology {
+	edges := make([][2]int, 0, n)
+	for i := 0; i+1 < n; i++ {
+		edges = append(edges, [2]int{i, i + 1})
+	}
+	return edgeTopology(n, baseID, edges)
+}
+
+// RingTopology 生成 n 个节点的环状拓扑
+func RingTopology(n int, baseID uint32) *Topology {
+	edges := make([][2]int, 0, n)
+	for i := 0; i+1 < n; i++ {
+		edges = append(edges, [2]int{i, i + 1})
+	}
+	if n > 2 {
+		edges = append(edges, [2]int{n - 1, 0})
+	}
+	return edgeTopology(n, baseID, edges)
+}
+
+// GridTopology 生成 width x height 的网格拓扑，节点按行编号
+func GridTopology(width, height int, baseID uint32) *Topology {
+	edges := make([][2]int, 0, 2*width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			if x+1 < width {
+				edges = append(edges, [2]int{i, i + 1})
+			}
+			if y+1 < height {
+				edges = append(edges, [2]int{i, i + width})
+			}
+		}
+	}
+	return edgeTopology(width*height, baseID, edges)
+}
+
+// RandomGeometricTopology 在单位正方形内随机放置 n 个节点，距离不超过 radius 的节点之间建立链路。
+// 相同的 seed 生成相同的拓扑，结果不保证连通。
+func RandomGeometricTopology(n int, radius float64, seed int64, baseID uint32) *Topology {
+	r := rand.New(rand.NewSource(seed))
+	xs, ys := make([]float64, n), make([]float64, n)
+	for i := 0; i < n; i++ {
+		xs[i], ys[i] = r.Float64(), r.Float64()
+	}
+
+	var edges [][2]int
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if math.Hypot(xs[i]-xs[j], ys[i]-ys[j]) <= radius {
+				edges = append(edges, [2]int{i, j})
+			}
+		}
+	}
+	topo := edgeTopology(n, baseID, edges)
+	topo.Seed = seed
+	return topo
+}
+
+// GenerateTopology 按 line:N、ring:N、grid:WxH 或 random:N:RADIUS 形式的描述生成拓扑，
+// random 使用种子 1；生成的拓扑没有设置延迟
+func GenerateTopology(spec string, baseID uint32) (*Topology, error) {
+	parts := strings.Split(spec, ":")
+	bad := fmt.Errorf("network: invalid topology %q", spec)
+	count := func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return 0, bad
+		}
+		return n, nil
+	}
+
+	var topo *Topology
+	switch {
+	case parts[0] == "line" && len(parts) == 2, parts[0] == "ring" && len(parts) == 2:
+		n, err := count(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if parts[0] == "line" {
+			topo = LineTopology(n, baseID)
+		} else {
+			topo = RingTopology(n, baseID)
+		}
+	case parts[0] == "grid" && len(parts) == 2:
+		w, h, ok := strings.Cut(parts[1], "x")
+		if !ok {
+			return nil, bad
+		}
+		width, err := count(w)
+		if err != nil {
+			return nil, err
+		}
+		height, err := count(h)
+		if err != nil {
+			return nil, err
+		}
+		topo = GridTopology(width, height, baseID)
+	case parts[0] == "random" && len(parts) == 3:
+		n, err := count(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		radius, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || radius <= 0 {
+			return nil, bad
+		}
+		topo = RandomGeometricTopology(n, radius, 1, baseID)
+	default:
+		return nil, bad
+	}
+	return topo, nil
+}
+
+// edgeTopology 根据边列表生成拓扑，每条边对应一个子网
+func edgeTopology(n int, baseID uint32, edges [][2]int) *Topology {
+	topo := &Topology{Nodes: make([]NodeSpec, n)}
+	for i := range topo.Nodes {
+		topo.Nodes[i] = NodeSpec{ID: baseID + uint32(i), Subnets: []uint32{}}
+	}
+	for k, e := range edges {
+		subnet := uint32(k + 1)
+		topo.Nodes[e[0]].Subnets = append(topo.Nodes[e[0]].Subnets, subnet)
+		topo.Nodes[e[1]].Subnets = append(topo.Nodes[e[1]].Subnets, subnet)
+	}
+	// 孤立节点放入只有自己的子网
+	next := uint32(len(edges) + 1)
+	for i := range topo.Nodes {
+		if len(topo.Nodes[i].Subnets) == 0 {
+			topo.Nodes[i].Subnets = append(topo.Nodes[i].Subnets, next)
+			next++
+		}
+	}
+	if n > 0 {
+		topo.Nodes[0].Active = true
+	}
+	return topo
+}
+
+// millis 将毫秒数转换为 time.Duration
+func millis(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
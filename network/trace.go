@@ -0,0 +1,179 @@
+package network
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 消息追踪：节点用 SendDataTraced 发出带有 TraceID 的数据包，网络记录每一跳的发送和丢弃，
+// 节点记录接收、丢弃和最终交付。TraceCollector 收集这些事件，按 TraceID 重建逐跳路径、
+// 每跳延迟和丢弃位置；Network.Stretch 将路径长度与物理拓扑上的最短路径比较。
+
+// Hop 是消息经过的一跳
+type Hop struct {
+	From, To uint32
+	Sent     time.Time     // 网络发出的时间
+	Received time.Time     // 下一跳节点收到的时间，未收到时为零值
+	Latency  time.Duration // Received - Sent，未收到时为 0
+}
+
+// Trace 是一条被追踪的消息的完整经过
+type Trace struct {
+	ID       uint64
+	Type     uint8
+	Src, Dst uint32
+	Hops     []Hop
+
+	Delivered   bool
+	DeliveredAt time.Time
+
+	Dropped    bool
+	DropNode   uint32 // 丢弃消息的节点；网络层的丢弃为本跳的发送节点
+	DropReason string // vrr.DROP_*
+
+	Events []vrr.TraceEvent // 按记录顺序排列的原始事件
+}
+
+// Path 返回消息经过的节点序列，从源节点开始
+func (t *Trace) Path() []uint32 {
+	path := []uint32{t.Src}
+	for _, hop := range t.Hops {
+		path = append(path, hop.To)
+	}
+	return path
+}
+
+// Latency 返回从第一跳发出到交付的时间，未交付时为 0
+func (t *Trace) Latency() time.Duration {
+	if !t.Delivered || len(t.Hops) == 0 {
+		return 0
+	}
+	return t.DeliveredAt.Sub(t.Hops[0].Sent)
+}
+
+// TraceCollector 实现 vrr.Tracer，按 TraceID 保存事件
+type TraceCollector struct {
+	lock   sync.Mutex
+	events map[uint64][]vrr.TraceEvent
+	order  []uint64 // 按首个事件的顺序记录的 TraceID
+}
+
+// NewTraceCollector 创建空的追踪收集器
+func NewTraceCollector() *TraceCollector {
+	return &TraceCollector{events: make(map[uint64][]vrr.TraceEvent)}
+}
+
+// TraceEvent 实现 vrr.Tracer
+func (c *TraceCollector) TraceEvent(ev vrr.TraceEvent) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.events[ev.TraceID]; !ok {
+		c.order = append(c.order, ev.TraceID)
+	}
+	c.events[ev.TraceID] = append(c.events[ev.TraceID], ev)
+}
+
+// IDs 返回已收集的 TraceID，按首次出现的顺序排列
+func (c *TraceCollector) IDs() []uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]uint64(nil), c.order...)
+}
+
+// Reset 清空已收集的事件
+func (c *TraceCollector) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.events = make(map[uint64][]vrr.TraceEvent)
+	c.order = nil
+}
+
+// Trace 根据事件重建 id 对应的消息经过，没有该ID的事件时 ok 为 false
+func (c *TraceCollector) Trace(id uint64) (*Trace, bool) {
+	c.lock.Lock()
+	events := append([]vrr.TraceEvent(nil), c.events[id]...)
+	c.lock.Unlock()
+	if len(events) == 0 {
+		return nil, false
+	}
+
+	t := &Trace{ID: id, Type: events[0].Type, Src: events[0].Src, Dst: events[0].Dst, Events: events}
+	for _, ev := range events {
+		switch ev.Kind {
+		case vrr.TRACE_SEND:
+			t.Hops = append(t.Hops, Hop{From: ev.Node, To: ev.Peer, Sent: ev.Time})
+		case vrr.TRACE_RECEIVE:
+			// 与最近一个尚未收到的、发往该节点的跳匹配
+			for i := len(t.Hops) - 1; i >= 0; i-- {
+				hop := &t.Hops[i]
+				if hop.To == ev.Node && hop.From == ev.Peer && hop.Received.IsZero() {
+					hop.Received = ev.Time
+					hop.Latency = ev.Time.Sub(hop.Sent)
+					break
+				}
+			}
+		case vrr.TRACE_DROP:
+			t.Dropped = true
+			t.DropNode = ev.Node
+			t.DropReason = ev.Reason
+		case vrr.TRACE_DELIVER:
+			t.Delivered = true
+			t.DeliveredAt = ev.Time
+		}
+	}
+	return t, true
+}
+
+// ShortestPath 用广度优先搜索返回 src 到 dst 在当前物理拓扑上的最短路径(包含两端)，
+// 不连通时返回 nil。相邻关系与 CheckRing 相同：同一子网中链路未被切断的两个节点相邻
+func (network *Network) ShortestPath(src, dst uint32) []uint32 {
+	nodes := network.nodeSnapshot()
+	if nodes[src] == nil || nodes[dst] == nil {
+		return nil
+	}
+
+	adj := network.adjacency(nodes)
+	prev := map[uint32]uint32{src: src}
+	for queue := []uint32{src}; len(queue) > 0; queue = queue[1:] {
+		cur := queue[0]
+		if cur == dst {
+			break
+		}
+		next := adj[cur]
+		sort.Slice(next, func(i, j int) bool { return next[i] < next[j] })
+		for _, id := range next {
+			if _, seen := prev[id]; !seen {
+				prev[id] = cur
+				queue = append(queue, id)
+			}
+		}
+	}
+	if _, ok := prev[dst]; !ok {
+		return nil
+	}
+
+	path := []uint32{dst}
+	for id := dst; id != src; id = prev[id] {
+		path = append(path, prev[id])
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// Stretch 返回已交付消息的路径伸展度：实际跳数与物理拓扑上最短路径跳数之比。
+// 消息未交付、源和目的相同或两者不连通时 ok 为 false
+func (network *Network) Stretch(t *Trace) (float64, bool) {
+	if !t.Delivered {
+		return 0, false
+	}
+	shortest := network.ShortestPath(t.Src, t.Dst)
+	if len(shortest) < 2 {
+		return 0, false
+	}
+	return float64(len(t.Hops)) / float64(len(shortest)-1), true
+}
@@ -0,0 +1,244 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// UDP 数据报的最大长度
+const udpMaxDatagram = 65507
+
+// udpPeer 描述一个远端节点的套接字地址和所在子网
+type udpPeer struct {
+	addr    *net.UDPAddr
+	subnets []uint32
+}
+
+// UDPNetwork 基于 UDP 套接字的 vrr.Networker 实现，每个实例服务一个本地节点。
+// 节点ID通过 AddPeer 映射到套接字地址；HELLO 等广播消息以单播方式发给
+// 与本地节点处于同一"子网"的所有对端，模拟链路层广播。
+type UDPNetwork struct {
+	conn    *net.UDPConn
+	node    *vrr.Node // 绑定的本地节点
+	subnets []uint32  // 本地节点所在的子网
+
+	peers    map[uint32]*udpPeer // key: 节点ID
+	peersMux sync.RWMutex
+
+	// 统计信息
+	TotalMessages   uint64 // 总发送消息数
+	DroppedMessages uint64 // 丢失消息数（编码/发送失败、无法识别的目标、inbox 满）
+	statsMux        sync.RWMutex
+	transmissions   *vrr.CounterVec // 按消息类型统计的单跳发送数
+	drops           *vrr.CounterVec // 按发送节点、消息类型和原因统计的丢弃数
+	registry        *vrr.Registry   // 传输本身和本地节点的指标
+
+	logger *vrr.Logger
+
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewUDPNetwork 在 listenAddr（例如 "127.0.0.1:0"）上创建 UDP 传输，本地节点属于 subnetIDs
+func NewUDPNetwork(listenAddr string, subnetIDs ...uint32) (*UDPNetwork, error) {
+	addr, err := net.ResolveUDPAddr("udp", listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("network: resolve %q: %w", listenAddr, err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("network: listen %q: %w", listenAddr, err)
+	}
+	u := &UDPNetwork{
+		conn:          conn,
+		subnets:       subnetIDs,
+		peers:         make(map[uint32]*udpPeer),
+		logger:        vrr.DefaultLogger(),
+		transmissions: vrr.NewCounterVec("type"),
+		drops:         vrr.NewCounterVec("node", "type", "reason"),
+		registry:      vrr.NewRegistry(),
+	}
+	u.registry.Register(u)
+	return u, nil
+}
+
+// SetLogger 设置传输的日志，默认为 vrr.DefaultLogger()
+func (u *UDPNetwork) SetLogger(logger *vrr.Logger) {
+	u.logger = logger
+}
+
+// Addr 返回实际监听的本地地址
+func (u *UDPNetwork) Addr() *net.UDPAddr {
+	return u.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// AddPeer 将远端节点ID映射到套接字地址，subnetIDs 为该节点所在的子网
+func (u *UDPNetwork) AddPeer(nodeID uint32, addr string, subnetIDs ...uint32) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return fmt.Errorf("network: resolve peer %d address %q: %w", nodeID, addr, err)
+	}
+	u.peersMux.Lock()
+	defer u.peersMux.Unlock()
+	u.peers[nodeID] = &udpPeer{addr: udpAddr, subnets: subnetIDs}
+	return nil
+}
+
+// RemovePeer 删除远端节点的映射
+func (u *UDPNetwork) RemovePeer(nodeID uint32) {
+	u.peersMux.Lock()
+	defer u.peersMux.Unlock()
+	delete(u.peers, nodeID)
+}
+
+// Attach 绑定本地节点并开始接收数据报，收到的消息投递到节点的 InboxChan
+func (u *UDPNetwork) Attach(node *vrr.Node) {
+	u.node = node
+	u.registry.Register(node)
+	u.wg.Add(1)
+	go u.receiveLoop()
+	u.logger.Info(vrr.LOG_NETWORK, "UDP node listening", "node", node.ID, "addr", u.Addr().String(), "subnets", u.subnets)
+}
+
+// Close 关闭套接字并等待接收 goroutine 退出
+func (u *UDPNetwork) Close() error {
+	var err error
+	u.closeOnce.Do(func() {
+		err = u.conn.Close()
+		u.wg.Wait()
+	})
+	return err
+}
+
+// Send 实现 vrr.Networker
+func (u *UDPNetwork) Send(msg vrr.Message) {
+	// --- 广播逻辑：发给同一子网中的所有对端 ---
+	if msg.NextHop == 0 {
+		u.peersMux.RLock()
+		targets := make([]uint32, 0, len(u.peers))
+		for id, peer := range u.peers {
+			if sharesSubnet(u.subnets, peer.subnets) {
+				targets = append(targets, id)
+			}
+		}
+		u.peersMux.RUnlock()
+
+		for _, id := range targets {
+			broadcastMsg := msg
+			broadcastMsg.NextHop = id
+			u.sendMessage(broadcastMsg)
+		}
+		return
+	}
+
+	// --- 单播逻辑 ---
+	u.sendMessage(msg)
+}
+
+// sendMessage 编码并发送单个消息
+func (u *UDPNetwork) sendMessage(msg vrr.Message) {
+	u.statsMux.Lock()
+	u.TotalMessages++
+	u.statsMux.Unlock()
+	u.transmissions.Inc(vrr.MessageTypeLabel(msg.Type))
+
+	u.peersMux.RLock()
+	peer, ok := u.peers[msg.NextHop]
+	u.peersMux.RUnlock()
+	if !ok {
+		u.logger.Warn(vrr.LOG_NETWORK, "target node has no UDP address, dropping message", "type", vrr.GetMessageTypeString(msg.Type), "next_hop", msg.NextHop)
+		u.countDrop(hopSender(msg), msg.Type, vrr.DROP_UNKNOWN_TARGET)
+		return
+	}
+
+	packet, err := msg.MarshalBinary()
+	if err != nil {
+		u.logger.Warn(vrr.LOG_NETWORK, "failed to encode message", "type", vrr.GetMessageTypeString(msg.Type), "err", err)
+		u.countDrop(hopSender(msg), msg.Type, vrr.DROP_SEND_ERROR)
+		return
+	}
+	if _, err := u.conn.WriteToUDP(packet, peer.addr); err != nil {
+		u.logger.Warn(vrr.LOG_NETWORK, "failed to send", "next_hop", msg.NextHop, "addr", peer.addr.String(), "err", err)
+		u.countDrop(hopSender(msg), msg.Type, vrr.DROP_SEND_ERROR)
+	}
+}
+
+// receiveLoop 读取数据报，解码后投递给本地节点
+func (u *UDPNetwork) receiveLoop() {
+	defer u.wg.Done()
+	buf := make([]byte, udpMaxDatagram)
+	for {
+		size, from, err := u.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			u.logger.Warn(vrr.LOG_NETWORK, "UDP read failed", "err", err)
+			continue
+		}
+
+		var msg vrr.Message
+		if err := msg.UnmarshalBinary(buf[:size]); err != nil {
+			u.logger.Warn(vrr.LOG_NETWORK, "malformed datagram", "from", from.String(), "err", err)
+			u.countDrop(u.node.ID, msg.Type, vrr.DROP_SEND_ERROR)
+			continue
+		}
+		if msg.NextHop != u.node.ID {
+			u.logger.Warn(vrr.LOG_NETWORK, "datagram for another node, dropping message", "next_hop", msg.NextHop, "node", u.node.ID)
+			u.countDrop(hopSender(msg), msg.Type, vrr.DROP_UNKNOWN_TARGET)
+			continue
+		}
+
+		select {
+		case u.node.InboxChan <- msg:
+		default:
+			u.logger.Warn(vrr.LOG_NETWORK, "inbox full, dropping message", "type", vrr.GetMessageTypeString(msg.Type), "next_hop", msg.NextHop)
+			u.countDrop(hopSender(msg), msg.Type, vrr.DROP_INBOX_FULL)
+		}
+	}
+}
+
+// countDrop 记录一条因 reason 被丢弃的消息；无法解码的数据报计入本地节点，其余计入本跳的发送节点
+func (u *UDPNetwork) countDrop(node uint32, msgType uint8, reason string) {
+	u.statsMux.Lock()
+	u.DroppedMessages++
+	u.statsMux.Unlock()
+	u.drops.Inc(strconv.FormatUint(uint64(node), 10), vrr.MessageTypeLabel(msgType), reason)
+}
+
+// Registry 返回传输的指标注册表，其中包含传输本身和 Attach 的本地节点
+func (u *UDPNetwork) Registry() *vrr.Registry {
+	return u.registry
+}
+
+// Collect 返回传输层的丢弃数和单跳发送数
+func (u *UDPNetwork) Collect() []vrr.MetricFamily {
+	return []vrr.MetricFamily{
+		{Name: "vrr_messages_dropped_total", Help: "Messages dropped, by message type and reason.", Type: vrr.METRIC_COUNTER, Samples: u.drops.Samples()},
+		{Name: "vrr_network_transmissions_total", Help: "Single-hop transmissions on the network, by message type.", Type: vrr.METRIC_COUNTER, Samples: u.transmissions.Samples()},
+	}
+}
+
+// GetMsgInfo 获取传输统计信息
+func (u *UDPNetwork) GetMsgInfo() (totalMsgs, droppedMsgs uint64) {
+	u.statsMux.RLock()
+	defer u.statsMux.RUnlock()
+	return u.TotalMessages, u.DroppedMessages
+}
+
+// sharesSubnet 判断两个子网列表是否有交集
+func sharesSubnet(a, b []uint32) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试管理接口：查询节点、拓扑、统计和虚拟环，通过 POST 发送数据、切断和恢复链路、启停节点，
+// 虚拟时钟在 AdminServer.Do 中推进
+func TestAdminServer(t *testing.T) {
+	log.Println("--- Running Test: AdminServer ---")
+
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		const size = 6
+		sim := convergedSim(t, network.RingTopology(size, 8081), seed, 30*time.Second)
+		// 环收敛时邻居的活跃状态可能还没有通过 HELLO 传播过来，再运行一个 HELLO 周期
+		config := sim.Node(8081).Config()
+		sim.Run(config.HelloInterval + config.HelloJitter)
+
+		admin := network.NewAdminServer(sim.Network)
+		srv := httptest.NewServer(admin)
+		defer srv.Close()
+
+		call := func(method, path, body string, status int, out any) string {
+			t.Helper()
+			req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			data, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != status {
+				t.Fatalf("%s %s: status %d, expected %d: %s", method, path, resp.StatusCode, status, data)
+			}
+			if out != nil {
+				if err := json.Unmarshal(data, out); err != nil {
+					t.Fatalf("%s %s: %v", method, path, err)
+				}
+			}
+			return string(data)
+		}
+		linkStatus := func(a, b uint32) (string, string) {
+			t.Helper()
+			var topology network.TopologyInfo
+			call("GET", "/topology", "", http.StatusOK, &topology)
+			for _, l := range topology.Links {
+				if l.A == a && l.B == b {
+					return l.StatusA, l.StatusB
+				}
+			}
+			t.Fatalf("link %d -- %d not found", a, b)
+			return "", ""
+		}
+
+		// 查询
+		var nodes []network.NodeSummary
+		call("GET", "/nodes", "", http.StatusOK, &nodes)
+		if len(nodes) != size {
+			t.Fatalf("%d nodes, expected %d", len(nodes), size)
+		}
+		for i, n := range nodes {
+			if n.ID != uint32(8081+i) || !n.Active || n.Stopped || n.VsetSize != vrr.VRR_VSET_SIZE || n.Pset != 2 {
+				t.Errorf("unexpected summary %+v", n)
+			}
+		}
+
+		var detail network.NodeDetail
+		call("GET", "/nodes/8081", "", http.StatusOK, &detail)
+		if want := sim.Node(8081).VsetManager.GetAll(); len(detail.Vset) != len(want) {
+			t.Errorf("vset %v, expected %v", detail.Vset, want)
+		}
+		for _, p := range detail.PsetNodes {
+			if p.Status != vrr.PsetStatusString(vrr.PSET_LINKED) || !p.Active {
+				t.Errorf("pset neighbor %+v not linked and active", p)
+			}
+		}
+		if len(detail.PsetState.LinkActive) != 2 || len(detail.RouteList) != detail.Routes || detail.Routes == 0 {
+			t.Errorf("pset state %+v, %d routes listed, %d counted", detail.PsetState, len(detail.RouteList), detail.Routes)
+		}
+		hellos := 0.0
+		for _, stat := range detail.Counters["vrr_messages_sent_total"] {
+			if stat.Labels["type"] == "hello" {
+				hellos = stat.Value
+			}
+		}
+		if hellos == 0 {
+			t.Errorf("no hello counted in %v", detail.Counters["vrr_messages_sent_total"])
+		}
+		var routes []network.RouteInfo
+		call("GET", "/nodes/8081/routes", "", http.StatusOK, &routes)
+		if len(routes) != detail.Routes {
+			t.Errorf("%d routes, expected %d", len(routes), detail.Routes)
+		}
+		call("GET", "/nodes/999", "", http.StatusNotFound, nil)
+		call("GET", "/nodes/abc/vset", "", http.StatusNotFound, nil)
+
+		var ring struct {
+			OK bool `json:"ok"`
+		}
+		call("GET", "/ring", "", http.StatusOK, &ring)
+		if !ring.OK {
+			t.Errorf("ring reported inconsistent")
+		}
+		if a, b := linkStatus(8081, 8082); a != "linked" || b != "linked" {
+			t.Errorf("link 8081 -- 8082 is %s/%s", a, b)
+		}
+		if metrics := call("GET", "/metrics", "", http.StatusOK, nil); !strings.Contains(metrics, "vrr_messages_sent_total{node=\"8081\"") {
+			t.Errorf("metrics missing node counters")
+		}
+		if dot := call("GET", "/dot?path=8081-8083", "", http.StatusOK, nil); !strings.Contains(dot, "penwidth=3") {
+			t.Errorf("dot has no overlaid path:\n%s", dot)
+		}
+		call("GET", "/dot?path=8081", "", http.StatusBadRequest, nil)
+
+		// 发送数据
+		var got []vrr.Delivery
+		sim.Node(8084).Handle(7, func(d vrr.Delivery) { got = append(got, d) })
+		var sent network.SendResult
+		call("POST", "/nodes/8081/send", `{"dst": 8084, "port": 7, "data": "hello"}`, http.StatusOK, &sent)
+		if !sent.Sent || sent.TraceID == 0 {
+			t.Errorf("send result %+v", sent)
+		}
+		admin.Do(func() { sim.Run(time.Second) })
+		if len(got) != 1 || got[0].Src != 8081 || string(got[0].Data) != "hello" {
+			t.Errorf("deliveries %+v", got)
+		}
+		call("POST", "/nodes/8081/send", `{"port": 7}`, http.StatusBadRequest, nil)
+		call("POST", "/nodes/8081/send", `not json`, http.StatusBadRequest, nil)
+
+		var stats network.StatsInfo
+		call("GET", "/stats", "", http.StatusOK, &stats)
+		if stats.Total == 0 || stats.Transmissions["hello"] == 0 || stats.Transmissions["data"] == 0 {
+			t.Errorf("stats %+v", stats)
+		}
+
+		// 切断并恢复链路：HELLO 间隔最长为 HelloInterval+HelloJitter，FailTimeout 个间隔没有 HELLO 后
+		// 邻居被标记为失败，最多等待两倍的时间。失败计数按消息的 Src 重置，8081 经由环的另一侧
+		// 转发给 8082 的消息会让 8082 一直认为链路可用，因此只要求至少一端发现链路失败
+		failWait := 2 * time.Duration(config.FailTimeout) * (config.HelloInterval + config.HelloJitter)
+		waitLink := func(done func(a, b string) bool) (string, string) {
+			t.Helper()
+			a, b := linkStatus(8081, 8082)
+			for waited := time.Duration(0); !done(a, b) && waited < failWait; waited += config.HelloInterval {
+				admin.Do(func() { sim.Run(config.HelloInterval) })
+				a, b = linkStatus(8081, 8082)
+			}
+			return a, b
+		}
+		call("POST", "/links/cut", `{"a": 8081, "b": 8082}`, http.StatusOK, nil)
+		if a, b := waitLink(func(a, b string) bool { return a != "linked" || b != "linked" }); a == "linked" && b == "linked" {
+			t.Errorf("cut link 8081 -- 8082 still %s/%s", a, b)
+		}
+		call("POST", "/links/restore", `{"a": 8081, "b": 8082}`, http.StatusOK, nil)
+		if a, b := waitLink(func(a, b string) bool { return a == "linked" && b == "linked" }); a != "linked" || b != "linked" {
+			t.Errorf("restored link 8081 -- 8082 is %s/%s", a, b)
+		}
+		call("POST", "/links/cut", `{"a": 8081, "b": 999}`, http.StatusNotFound, nil)
+
+		// 停止并重新启动节点
+		var summary network.NodeSummary
+		call("POST", "/nodes/8083/stop", "", http.StatusOK, &summary)
+		if !summary.Stopped {
+			t.Errorf("node 8083 not stopped: %+v", summary)
+		}
+		call("POST", "/nodes/8083/start", "", http.StatusOK, &summary)
+		if summary.Stopped {
+			t.Errorf("node 8083 not restarted: %+v", summary)
+		}
+		call("POST", "/nodes/8083/start", "", http.StatusConflict, nil)
+		admin.Do(func() { waitRing(t, sim.Network, 30*time.Second) })
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"log/slog"
 	"testing" // 导入 testing 包
 	"time"
 
@@ -48,7 +49,7 @@ func TestBootStrap(t *testing.T) {
 	// 等待足够长的时间让节点们自己完成握手
 	// 至少需要 2-3 个 HELLO 周期才能稳定到 LINKED 状态
 	log.Println("\n--- Waiting for autonomous BootStrap to complete... ---")
-	time.Sleep(10 * time.Second)
+	waitRing(t, network, 10*time.Second)
 
 	// 检查最终状态
 	log.Println("\n--- Final State after autonomous BootStrap ---")
@@ -62,44 +63,109 @@ func TestBootStrap(t *testing.T) {
 	log.Printf("Simulation completed: Total messages: %d, Dropped: %d", totalMsgs, droppedMsgs)
 }
 
-// 运行测试
-// 样例
-/* --- Final State after autonomous BootStrap ---
-2025/10/30 18:49:04 --- Current PSet States ---
-2025/10/30 18:49:04 Node 8086 -> PSetState: {LinkedActive: [], LinkedNotActive: [], Pending: []}
-2025/10/30 18:49:04 Node 8082 -> PSetState: {LinkedActive: [8084 8085 8083], LinkedNotActive: [], Pending: []}
-2025/10/30 18:49:04 Node 8083 -> PSetState: {LinkedActive: [8084 8082], LinkedNotActive: [], Pending: []}
-2025/10/30 18:49:04 Node 8085 -> PSetState: {LinkedActive: [8082], LinkedNotActive: [], Pending: []}
-2025/10/30 18:49:04 Node 8084 -> PSetState: {LinkedActive: [8082 8083], LinkedNotActive: [], Pending: []}
-2025/10/30 18:49:04 ---------------------------
-2025/10/30 18:49:04 --- Test BootStrap Finished ---
-2025/10/30 18:49:04 --- Current VSet ---
-2025/10/30 18:49:04 Node 8086 -> VSet: []
-2025/10/30 18:49:04 Node 8082 -> VSet: [8083 8084 8085]
-2025/10/30 18:49:04 Node 8083 -> VSet: [8082 8084 8085]
-2025/10/30 18:49:04 Node 8085 -> VSet: [8082 8083 8084]
-2025/10/30 18:49:04 Node 8084 -> VSet: [8082 8083 8085]
-2025/10/30 18:49:04 --------------------
-2025/10/30 18:49:04 --- Current Routing Tables ---
-2025/10/30 18:49:04 Node 8086 -> RoutingTable: {empty}
-2025/10/30 18:49:04 Node 8082 -> RoutingTable:
-        - PathID: 2232526375, Ea: 8084, Eb: 8085, Na: 8083, Nb: 8085
-        - PathID: 2403921202, Ea: 8083, Eb: 8085, Na: 8083, Nb: 8085
-        - PathID: 3421056553, Ea: 8083, Eb: 8082, Na: 8083, Nb: 0
-        - PathID: 3546283136, Ea: 8082, Eb: 8085, Na: 0, Nb: 8085
-        - PathID: 3977464567, Ea: 8084, Eb: 8082, Na: 8084, Nb: 0
-2025/10/30 18:49:04 Node 8083 -> RoutingTable:
-        - PathID: 2232526375, Ea: 8084, Eb: 8085, Na: 8084, Nb: 8082
-        - PathID: 2403921202, Ea: 8083, Eb: 8085, Na: 0, Nb: 8082
-        - PathID: 2824397968, Ea: 8084, Eb: 8083, Na: 8084, Nb: 0
-        - PathID: 3421056553, Ea: 8083, Eb: 8082, Na: 0, Nb: 8082
-2025/10/30 18:49:04 Node 8085 -> RoutingTable:
-        - PathID: 2232526375, Ea: 8084, Eb: 8085, Na: 8082, Nb: 0
-        - PathID: 2403921202, Ea: 8083, Eb: 8085, Na: 8082, Nb: 0
-        - PathID: 3546283136, Ea: 8082, Eb: 8085, Na: 8082, Nb: 0
-2025/10/30 18:49:04 Node 8084 -> RoutingTable:
-        - PathID: 2232526375, Ea: 8084, Eb: 8085, Na: 0, Nb: 8083
-        - PathID: 2824397968, Ea: 8084, Eb: 8083, Na: 0, Nb: 8083
-        - PathID: 3977464567, Ea: 8084, Eb: 8082, Na: 0, Nb: 8082
-2025/10/30 18:49:04 ------------------------------
-2025/10/30 18:49:04 Simulation completed: Total messages: 326, Dropped: 0*/
+// 测试基于节点ID的确定性选举自举：连通分量中只有ID最小的节点自举，最终只形成一个虚拟环
+func TestBootStrapElection(t *testing.T) {
+	log.Println("--- Running Test: BootStrapElection ---")
+
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		// --- 定义拓扑（与 TestBootStrap 相同），在虚拟时钟上运行 ---
+		// Subnet 1: Node 5, Node 2
+		// Subnet 2: Node 2, Node 3, Node 4
+		// Router: Node 2 (连接 Subnet 1 和 Subnet 2)
+		// 孤立节点: Node 6 (在 Subnet 3)
+		topo := &network.Topology{
+			Bootstrap: "elect-lowest",
+			Nodes: []network.NodeSpec{
+				{ID: 8085, Subnets: []uint32{1}},
+				{ID: 8082, Subnets: []uint32{1, 2}},
+				{ID: 8083, Subnets: []uint32{2}},
+				{ID: 8086, Subnets: []uint32{3}},
+				{ID: 8084, Subnets: []uint32{2}},
+			},
+		}
+
+		// 记录日志以检查哪些节点超时自举
+		out := &syncBuffer{}
+		logger := vrr.NewLogger(slog.New(slog.NewJSONHandler(out, nil)), vrr.NewLogLevels(slog.LevelInfo))
+
+		// 子网1和子网2连通，所有节点应处于同一个环中：每个节点的vset恰好是其他三个节点
+		log.Println("\n--- Waiting for election BootStrap to complete... ---")
+		sim := buildSim(t, topo, seed)
+		sim.SetLogger(logger)
+		sim.Start()
+		waitRing(t, sim.Network, 30*time.Second)
+
+		printAllPsetState(sim.Nodes)
+		printAllVsets(sim.Nodes)
+		printAllRoutes(sim.Nodes)
+
+		// 连通分量 8082~8085 中只有ID最小的 8082 自举，其他节点都通过代理加入它的环
+		for _, id := range []uint32{8082, 8083, 8084, 8085} {
+			if ring := sim.Node(id).GetRingID(); ring != 8082 {
+				t.Errorf("Node %d is in ring %d, expected 8082", id, ring)
+			}
+		}
+		// 孤立节点在自己的连通分量中当选并自举
+		if node6 := sim.Node(8086); !node6.IsActive() || node6.GetRingID() != 8086 {
+			t.Errorf("isolated Node %d did not bootstrap itself", node6.ID)
+		}
+		for _, r := range out.records(t) {
+			if r["msg"] != "activated after timeout" {
+				continue
+			}
+			if id := uint32(r["node"].(float64)); id != 8082 && id != 8086 {
+				t.Errorf("Node %d bootstrapped itself although 8082 was elected", id)
+			}
+		}
+
+		totalMsgs, droppedMsgs := sim.Network.GetMsgInfo()
+		log.Printf("Simulation completed: Total messages: %d, Dropped: %d", totalMsgs, droppedMsgs)
+	})
+}
+
+// 测试选举候选者过期：候选者的序号在 FailTimeout 个 HELLO 周期内没有增长时（例如候选者已离开，
+// 邻居之间只在互相转发旧的通告），不再认可它，而不必等到跳数增长到 VRR_ELECTION_MAX_HOPS
+func TestElectionCandidateExpiry(t *testing.T) {
+	log.Println("--- Running Test: ElectionCandidateExpiry ---")
+	node, _, clock := newCaptureNode(8083)
+	if err := node.SetBootstrapMode(vrr.BOOTSTRAP_ELECT_LOWEST); err != nil {
+		t.Fatal(err)
+	}
+	expiry := time.Duration(node.Config().FailTimeout) * node.Config().HelloInterval
+
+	hello := func(seq uint32) {
+		node.Receive(vrr.Message{Type: vrr.VRR_HELLO, Src: 8082, Sender: 8082, Payload: &vrr.HelloPayload{
+			Candidate: 8081, CandidateHops: 1, CandidateSeq: seq,
+		}})
+	}
+
+	// 序号持续增长时一直认可 8081
+	for seq := uint32(1); seq <= 20; seq++ {
+		hello(seq)
+		clock.RunFor(expiry / 4)
+	}
+	if candidate, hops, seq := node.Candidate(); candidate != 8081 || hops != 2 || seq != 20 {
+		t.Fatalf("expected candidate 8081 at 2 hops with seq 20, got %d at %d hops with seq %d", candidate, hops, seq)
+	}
+
+	// 8081 离开后邻居仍转发旧的序号：过期之前仍然认可，过期之后改为认可自己
+	for i := 0; i < 3; i++ {
+		hello(20)
+		clock.RunFor(expiry / 4)
+	}
+	if candidate, _, _ := node.Candidate(); candidate != 8081 {
+		t.Errorf("candidate 8081 expired early, got %d", candidate)
+	}
+	hello(20)
+	clock.RunFor(expiry / 2)
+	if candidate, hops, _ := node.Candidate(); candidate != 8083 || hops != 0 {
+		t.Errorf("stale candidate was not dropped: got %d at %d hops", candidate, hops)
+	}
+
+	// 邻居不再通告 8081 后，过期的记录被删除：之后 8081 以任意序号重新出现都被当作新的候选者
+	node.Receive(vrr.Message{Type: vrr.VRR_HELLO, Src: 8082, Sender: 8082, Payload: &vrr.HelloPayload{}})
+	hello(20)
+	if candidate, _, _ := node.Candidate(); candidate != 8081 {
+		t.Errorf("expired candidate record was not pruned: got %d", candidate)
+	}
+}
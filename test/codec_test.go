@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试所有消息类型的二进制编码往返一致
+func TestCodecRoundTrip(t *testing.T) {
+	cases := []vrr.Message{
+		{Type: vrr.VRR_HELLO, Src: 8085, Dst: 0, NextHop: 8082, Sender: 8085, Payload: &vrr.HelloPayload{
+			SenderActive:           true,
+			HelloInfoLinkActive:    []uint32{8082, 8083},
+			HelloInfoLinkNotActive: []uint32{8084},
+			RingID:                 8081,
+			Candidate:              8081,
+			CandidateHops:          3,
+			CandidateSeq:           0x01020304,
+		}},
+		{Type: vrr.VRR_HELLO, Src: 8086, NextHop: 8082, Sender: 8086, Payload: &vrr.HelloPayload{}},
+		{Type: vrr.VRR_SETUP_REQ, Src: 8084, Dst: 8084, NextHop: 8082, Sender: 8084, Payload: &vrr.SetupReqPayload{
+			Proxy: 8082,
+			Vset_: []uint32{8083, 8085},
+		}},
+		{Type: vrr.VRR_SETUP, Src: 8082, Dst: 8084, NextHop: 8083, Sender: 8082, Payload: &vrr.SetupPayload{
+			Pid:    0xdeadbeef,
+			Proxy:  8082,
+			Vset_:  []uint32{8083, 8085, 8086, 8087},
+			RingID: 8082,
+		}},
+		{Type: vrr.VRR_SETUP_FAIL, Src: 8085, Dst: 8084, NextHop: 8082, Sender: 8085, Payload: &vrr.SetupFailPayload{
+			Proxy: 8082,
+			Vset_: []uint32{8082},
+		}},
+		{Type: vrr.VRR_TEARDOWN, Src: 8083, NextHop: 8082, Sender: 8083, Payload: &vrr.TeardownPayload{
+			Pid:      42,
+			Endpoint: 8083,
+		}},
+		{Type: vrr.VRR_DATA, Src: 8085, Dst: 8083, NextHop: 8082, Sender: 8085, TraceID: 0x0123456789abcdef, Payload: &vrr.DataPayload{
+			Port: 7,
+			Hops: 1,
+			Data: []byte("Hello from Node 5 to Node 3!"),
+		}},
+		{Type: vrr.VRR_DATA, Src: 8085, Dst: 8083, NextHop: 8082, Sender: 8085, Payload: &vrr.DataPayload{}},
+		{Type: vrr.VRR_PATH_SYNC, Src: 8082, Dst: 8083, NextHop: 8083, Sender: 8082, Payload: &vrr.PathSyncPayload{
+			Paths: []vrr.PathRef{{Pid: 42, Endpoint: 8081}, {Pid: 7, Endpoint: 8084}},
+		}},
+		{Type: vrr.VRR_PATH_SYNC, Src: 8082, Dst: 8083, NextHop: 8083, Sender: 8082, Payload: &vrr.PathSyncPayload{}},
+		{Type: vrr.VRR_VSET_SHARE, Src: 8081, Dst: 8083, NextHop: 8082, Sender: 8081, Payload: &vrr.VsetSharePayload{
+			Vset_: []uint32{8082, 8084},
+		}},
+	}
+
+	for _, msg := range cases {
+		packet, err := msg.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: marshal failed: %v", vrr.GetMessageTypeString(msg.Type), err)
+		}
+		var decoded vrr.Message
+		if err := decoded.UnmarshalBinary(packet); err != nil {
+			t.Fatalf("%s: unmarshal failed: %v", vrr.GetMessageTypeString(msg.Type), err)
+		}
+		if !reflect.DeepEqual(msg, decoded) {
+			t.Errorf("%s: round trip mismatch:\n got  %+v %+v\n want %+v %+v",
+				vrr.GetMessageTypeString(msg.Type), decoded, decoded.Payload, msg, msg.Payload)
+		}
+	}
+}
+
+// 测试解码器对非法报文的严格校验
+func TestCodecValidation(t *testing.T) {
+	valid := vrr.Message{Type: vrr.VRR_SETUP_REQ, Src: 1, Dst: 2, NextHop: 3, Sender: 1,
+		Payload: &vrr.SetupReqPayload{Proxy: 3, Vset_: []uint32{4, 5}}}
+	packet, err := valid.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	badVersion := append([]byte(nil), packet...)
+	badVersion[0] = vrr.VRR_WIRE_VERSION + 1
+
+	badType := append([]byte(nil), packet...)
+	badType[1] = 0x7f
+
+	// 声明的列表长度超过 VRR_MAX_VSET_SIZE
+	longList := append([]byte(nil), packet[:vrr.VRR_HEADER_LEN+4]...)
+	longList = append(longList, 0, vrr.VRR_MAX_VSET_SIZE+1)
+	for i := 0; i < vrr.VRR_MAX_VSET_SIZE+1; i++ {
+		longList = append(longList, 0, 0, 0, byte(i+1))
+	}
+	bodyLen := len(longList) - vrr.VRR_HEADER_LEN
+	longList[2], longList[3] = byte(bodyLen>>8), byte(bodyLen)
+
+	// Length 与实际字节数一致，但 Payload 内部被截断
+	truncated := append([]byte(nil), packet[:len(packet)-2]...)
+	truncated[2], truncated[3] = 0, byte(len(truncated)-vrr.VRR_HEADER_LEN)
+
+	cases := []struct {
+		name   string
+		packet []byte
+		want   error
+	}{
+		{"short header", packet[:vrr.VRR_HEADER_LEN-1], vrr.ErrWireTruncated},
+		{"bad version", badVersion, vrr.ErrWireVersion},
+		{"unknown type", badType, vrr.ErrWireType},
+		{"length mismatch", packet[:len(packet)-1], vrr.ErrWireLength},
+		{"list too long", longList, vrr.ErrWireList},
+		{"truncated payload", truncated, vrr.ErrWireTruncated},
+	}
+	for _, c := range cases {
+		var msg vrr.Message
+		if err := msg.UnmarshalBinary(c.packet); !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+
+	// 编码时同样拒绝超长列表和类型不匹配的 Payload
+	tooLong := vrr.Message{Type: vrr.VRR_SETUP, Payload: &vrr.SetupPayload{Vset_: make([]uint32, vrr.VRR_MAX_VSET_SIZE+1)}}
+	if _, err := tooLong.MarshalBinary(); !errors.Is(err, vrr.ErrWireList) {
+		t.Errorf("marshal oversized vset: expected %v, got %v", vrr.ErrWireList, err)
+	}
+	mismatched := vrr.Message{Type: vrr.VRR_HELLO, Payload: &vrr.DataPayload{}}
+	if _, err := mismatched.MarshalBinary(); !errors.Is(err, vrr.ErrWireType) {
+		t.Errorf("marshal mismatched payload: expected %v, got %v", vrr.ErrWireType, err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试节点参数：默认值与原来的常量一致，非法参数在创建节点时被拒绝，
+// 同一进程中 vset 大小为 4 和 8 的网络各自收敛到对应的虚拟环
+func TestConfig(t *testing.T) {
+	log.Println("--- Running Test: Config ---")
+
+	def := vrr.NewNode(1, nil).Config()
+	if def.VsetSize != vrr.VRR_VSET_SIZE || def.PsetSize != vrr.VRR_PSET_SIZE || def.FailTimeout != vrr.VRR_FAIL_TIMEOUT ||
+		def.ActiveTimeout != vrr.VRR_ACTIVE_TIMEOUT || def.HelloInterval != 500*time.Millisecond || def.HelloJitter != 300*time.Millisecond {
+		t.Errorf("NewNode does not use the default config: %+v", def)
+	}
+	if n := vrr.NewNode(1, nil); cap(n.InboxChan) != vrr.VRR_INBOX_SIZE || cap(n.RecvChan) != vrr.VRR_RECV_SIZE {
+		t.Errorf("default inbox size %d and recv size %d, expected %d and %d", cap(n.InboxChan), cap(n.RecvChan), vrr.VRR_INBOX_SIZE, vrr.VRR_RECV_SIZE)
+	}
+
+	// 零值字段使用默认值
+	n, err := vrr.NewNodeWithConfig(1, nil, vrr.Config{VsetSize: 8, InboxSize: 16, RecvSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := n.Config(); c.VsetSize != 8 || c.FailTimeout != vrr.VRR_FAIL_TIMEOUT || c.Clock != vrr.RealClock || cap(n.InboxChan) != 16 || cap(n.RecvChan) != 4 {
+		t.Errorf("partial config not filled with defaults: %+v", c)
+	}
+
+	// 只缩短 HELLO 周期时，默认抖动随之缩放；负数抖动表示不加抖动
+	n, err = vrr.NewNodeWithConfig(1, nil, vrr.Config{HelloInterval: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("config with a 200ms hello interval was rejected: %v", err)
+	}
+	if c := n.Config(); c.HelloJitter != 120*time.Millisecond {
+		t.Errorf("default jitter for a 200ms hello interval is %v, expected 120ms", c.HelloJitter)
+	}
+	if _, err := vrr.NewNodeWithConfig(1, nil, vrr.Config{HelloInterval: 200 * time.Millisecond, HelloJitter: -1}); err != nil {
+		t.Errorf("config without hello jitter was rejected: %v", err)
+	}
+
+	invalid := []struct {
+		name   string
+		config vrr.Config
+		want   string
+	}{
+		{"odd vset size", vrr.Config{VsetSize: 5}, "even"},
+		{"negative vset size", vrr.Config{VsetSize: -2}, "even"},
+		{"vset size too large", vrr.Config{VsetSize: vrr.VRR_MAX_VSET_SIZE + 2}, "exceeds"},
+		{"pset size too large", vrr.Config{PsetSize: vrr.VRR_MAX_PSET_SIZE + 1}, "pset size"},
+		{"negative fail timeout", vrr.Config{FailTimeout: -1}, "timeout"},
+		{"negative hello interval", vrr.Config{HelloInterval: -time.Second}, "interval"},
+		{"jitter not below interval", vrr.Config{HelloInterval: 100 * time.Millisecond, HelloJitter: 100 * time.Millisecond}, "jitter"},
+		{"negative inbox size", vrr.Config{InboxSize: -1}, "inbox"},
+		{"negative recv size", vrr.Config{RecvSize: -1}, "recv"},
+		{"negative path sync period", vrr.Config{PathSync: -1}, "path sync"},
+		{"unknown bootstrap mode", vrr.Config{Bootstrap: 9}, "bootstrap"},
+	}
+	for _, c := range invalid {
+		if _, err := vrr.NewNodeWithConfig(1, nil, c.config); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: expected an error mentioning %q, got %v", c.name, c.want, err)
+		}
+	}
+
+	bad := network.RingTopology(4, 8081)
+	bad.Config = &network.NodeConfigSpec{VsetSize: 3}
+	if _, err := bad.Build(); err == nil {
+		t.Errorf("topology with an odd vset size was accepted")
+	}
+
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		// 12 个节点的环状拓扑，分别以 vset 大小 4 和 8 运行
+		for _, size := range []int{4, 8} {
+			topo := network.RingTopology(12, 8081)
+			topo.Config = &network.NodeConfigSpec{VsetSize: size}
+			sim := convergedSim(t, topo, seed, 60*time.Second)
+			for _, n := range sim.Nodes {
+				if got := len(n.VsetManager.GetAll()); got != size {
+					t.Errorf("vset size %d: Node %d has %d virtual neighbors", size, n.ID, got)
+				}
+			}
+			sim.Stop()
+		}
+	})
+}
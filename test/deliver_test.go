@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试数据包到达目的节点后交付给上层应用（端口处理函数与 Recv）
+func TestDeliverData(t *testing.T) {
+	log.Println("--- Running Test: DeliverData ---")
+	network := network.NewNetwork(50*time.Millisecond, 0.0)
+
+	// --- 定义拓扑 ---
+	// Subnet 1: Node 2, Node 5
+	// Subnet 2: Node 2, Node 3, Node 4
+	// Router: Node 2 (连接 Subnet 1 和 Subnet 2)
+
+	node5 := vrr.NewNode(8085, network)
+	node2 := vrr.NewNode(8082, network)
+	node3 := vrr.NewNode(8083, network)
+	node4 := vrr.NewNode(8084, network)
+
+	network.RegisterNode(node5, 1)
+	node5.SetActive(true)
+	network.RegisterNode(node2, 1, 2)
+	network.RegisterNode(node3, 2)
+	network.RegisterNode(node4, 2)
+
+	nodes := []*vrr.Node{node2, node3, node4, node5}
+
+	for _, n := range nodes {
+		n.Start()
+		defer n.Stop()
+	}
+
+	log.Println("\n--- Waiting for virtual network and vset-paths to complete... ---")
+	time.Sleep(3 * time.Second)
+	printAllRoutes(nodes)
+
+	// 端口 7 注册处理函数，其他端口走 Recv
+	handled := make(chan vrr.Delivery, 1)
+	node3.Handle(7, func(d vrr.Delivery) {
+		handled <- d
+	})
+
+	if !node5.SendDataPort(node3.ID, 7, []byte("to port 7")) {
+		t.Fatalf("Node %d: no route to Node %d", node5.ID, node3.ID)
+	}
+	select {
+	case d := <-handled:
+		if d.Src != node5.ID || d.Port != 7 || string(d.Data) != "to port 7" {
+			t.Fatalf("unexpected delivery on port 7: %+v", d)
+		}
+		if d.Hops != 2 {
+			t.Errorf("expected 2 hops from 8085 to 8083, got %d", d.Hops)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("data on port 7 was not delivered to handler")
+	}
+
+	if !node5.SendData(node3.ID, []byte("to default port")) {
+		t.Fatalf("Node %d: no route to Node %d", node5.ID, node3.ID)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	d, err := node3.Recv(ctx)
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if d.Src != node5.ID || d.Port != 0 || string(d.Data) != "to default port" {
+		t.Fatalf("unexpected delivery from Recv: %+v", d)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试 DOT 导出：收敛后的环状拓扑中每条物理链路为已链接的颜色，虚拟环按ID首尾相接，
+// vset 边都是互为邻居的实线；叠加的 vset-path 沿物理链路逐跳走到另一个端点；
+// 链路断开后对应的物理边不再是已链接的颜色
+func TestDOTExport(t *testing.T) {
+	log.Println("--- Running Test: DOTExport ---")
+
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		const size = 6
+		sim := convergedSim(t, network.RingTopology(size, 8081), seed, 30*time.Second)
+
+		var buf bytes.Buffer
+		if err := sim.Network.WriteDOT(&buf, network.DefaultDOTOptions()); err != nil {
+			t.Fatal(err)
+		}
+		dot := buf.String()
+		if !strings.HasPrefix(dot, "graph vrr {\n") || !strings.HasSuffix(dot, "}\n") {
+			t.Fatalf("not a DOT graph:\n%s", dot)
+		}
+		for i := 0; i < size; i++ {
+			a, b := uint32(8081+i), uint32(8081+(i+1)%size)
+			if b < a {
+				a, b = b, a
+			}
+			if !strings.Contains(dot, fmt.Sprintf("\t%d -- %d [color=darkgreen", a, b)) {
+				t.Errorf("physical link %d -- %d missing or not linked", a, b)
+			}
+			if !strings.Contains(dot, fmt.Sprintf("\t%d [style=filled", 8081+i)) {
+				t.Errorf("node %d not drawn as active", 8081+i)
+			}
+		}
+		if n := strings.Count(dot, "style=dotted"); n != size {
+			t.Errorf("%d ring edges, expected %d", n, size)
+		}
+		if !strings.Contains(dot, "\t8086 -- 8081 [style=dotted") {
+			t.Errorf("ring does not wrap from 8086 to 8081")
+		}
+		if solid, dashed := strings.Count(dot, "color=blue, style=solid"), strings.Count(dot, "color=blue, style=dashed"); solid != size*vrr.VRR_VSET_SIZE/2 || dashed != 0 {
+			t.Errorf("%d mutual and %d one-sided vset edges, expected %d and 0", solid, dashed, size*vrr.VRR_VSET_SIZE/2)
+		}
+
+		// 叠加 8081 与 8083 之间的 vset-path
+		paths := sim.Network.VsetPaths(8081, 8083)
+		if len(paths) == 0 {
+			t.Fatalf("no vset-path between 8081 and 8083")
+		}
+		for _, path := range paths {
+			if !path.Complete || len(path.Hops) == 0 || path.Hops[0].From != 8081 || path.Hops[len(path.Hops)-1].To != 8083 {
+				t.Errorf("path %d: %+v", path.PathId, path)
+			}
+			for i, hop := range path.Hops {
+				if i > 0 && hop.From != path.Hops[i-1].To {
+					t.Errorf("path %d: hop %d does not continue from the previous hop", path.PathId, i)
+				}
+				if sim.Node(hop.From).PsetManager.GetStatus(hop.To) != vrr.PSET_LINKED {
+					t.Errorf("path %d: hop %d -> %d is not a linked physical neighbor", path.PathId, hop.From, hop.To)
+				}
+			}
+		}
+		buf.Reset()
+		opts := network.DOTOptions{Paths: [][2]uint32{{8081, 8083}}}
+		if err := sim.Network.WriteDOT(&buf, opts); err != nil {
+			t.Fatal(err)
+		}
+		overlay := buf.String()
+		if strings.Contains(overlay, "style=dotted") || strings.Contains(overlay, "color=blue") || strings.Contains(overlay, "darkgreen") {
+			t.Errorf("disabled layers drawn:\n%s", overlay)
+		}
+		hops := 0
+		for _, path := range paths {
+			hops += len(path.Hops)
+		}
+		if n := strings.Count(overlay, "penwidth=3"); n != hops {
+			t.Errorf("%d overlay edges, expected %d", n, hops)
+		}
+
+		// 切断 8081 与 8082 之间的链路
+		lost := network.LinkProfile{Latency: 20 * time.Millisecond, PacketLoss: 1}
+		sim.Network.SetLinkProfile(8081, 8082, lost)
+		sim.Network.SetLinkProfile(8082, 8081, lost)
+		sim.Run(6 * time.Second)
+		buf.Reset()
+		if err := sim.Network.WriteDOT(&buf, network.DOTOptions{Physical: true}); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), "\t8081 -- 8082 [color=darkgreen") {
+			t.Errorf("broken link still drawn as linked:\n%s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试物理邻居失效后，经过它的 vset-paths 被拆除，端点重新建立路径
+func TestLinkFailure(t *testing.T) {
+	log.Println("--- Running Test: LinkFailure ---")
+	network := network.NewNetwork(50*time.Millisecond, 0.0)
+
+	// --- 定义拓扑 ---
+	// Subnet 1: Node 5, Node 2
+	// Subnet 2: Node 2, Node 3, Node 4
+	// Router: Node 2 (连接 Subnet 1 和 Subnet 2)，之后宕机
+
+	node5 := vrr.NewNode(8085, network)
+	node2 := vrr.NewNode(8082, network)
+	node3 := vrr.NewNode(8083, network)
+	node4 := vrr.NewNode(8084, network)
+
+	network.RegisterNode(node5, 1)
+	node5.SetActive(true)
+	network.RegisterNode(node2, 1, 2)
+	network.RegisterNode(node3, 2)
+	network.RegisterNode(node4, 2)
+
+	nodes := []*vrr.Node{node2, node3, node4, node5}
+	for _, n := range nodes {
+		n.Start()
+		defer n.Stop()
+	}
+
+	log.Println("\n--- Waiting for virtual network and vset-paths to complete... ---")
+	time.Sleep(3 * time.Second)
+	printAllRoutes(nodes)
+
+	// 路由器宕机：停止并从所有子网注销
+	log.Println("\n--- Crashing router Node 8082 ---")
+	node2.Stop()
+	network.UnregisterNode(node2.ID)
+
+	// 失败检测需要 VRR_FAIL_TIMEOUT 个 HELLO 周期
+	time.Sleep(8 * time.Second)
+
+	survivors := []*vrr.Node{node3, node4, node5}
+	printAllPsetState(survivors)
+	printAllVsets(survivors)
+	printAllRoutes(survivors)
+
+	for _, n := range survivors {
+		if n.RoutingTable.HasNextHop(node2.ID) {
+			t.Errorf("Node %d: still has routes through failed Node %d:\n%s", n.ID, node2.ID, n.RoutingTable.String())
+		}
+		if n.VsetManager.Contains(node2.ID) {
+			t.Errorf("Node %d: failed Node %d still in %s", n.ID, node2.ID, n.VsetManager.String())
+		}
+	}
+
+	// 子网2中剩余的节点之间仍然可以通信
+	delivered := make(chan vrr.Delivery, 1)
+	node4.Handle(1, func(d vrr.Delivery) {
+		delivered <- d
+	})
+	if !node3.SendDataPort(node4.ID, 1, []byte("after failure")) {
+		t.Fatalf("Node %d: no route to Node %d after failure", node3.ID, node4.ID)
+	}
+	select {
+	case <-delivered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("data was not delivered after failure repair")
+	}
+}
@@ -46,10 +46,10 @@ func TestHelloHandshake(t *testing.T) {
 	/* 	log.Println("\n--- Initial State ---")
 	   	printAllPsetState(nodes) */
 
-	// 等待足够长的时间让节点们自己完成握手
+	// 等待节点们自己完成握手并建立虚拟环
 	// 至少需要 2-3 个 HELLO 周期才能稳定到 LINKED 状态
 	log.Println("\n--- Waiting for autonomous HELLO handshake to complete... ---")
-	time.Sleep(3 * time.Second)
+	waitRing(t, network, 10*time.Second)
 
 	// 检查最终状态
 	log.Println("\n--- Final State after autonomous handshake ---")
@@ -62,45 +62,3 @@ func TestHelloHandshake(t *testing.T) {
 	totalMsgs, droppedMsgs := network.GetMsgInfo()
 	log.Printf("Simulation completed: Total messages: %d, Dropped: %d", totalMsgs, droppedMsgs)
 }
-
-// 运行测试
-// 样例
-/* --- Final State after autonomous handshake ---
-2025/10/29 21:24:35 --- Current PSet States ---
-2025/10/29 21:24:35 Node 8086 -> PSetState: {LinkedActive: [], LinkedNotActive: [], Pending: []}
-2025/10/29 21:24:35 Node 8082 -> PSetState: {LinkedActive: [8084 8085 8083], LinkedNotActive: [], Pending: []}
-2025/10/29 21:24:35 Node 8083 -> PSetState: {LinkedActive: [8082 8084], LinkedNotActive: [], Pending: []}
-2025/10/29 21:24:35 Node 8084 -> PSetState: {LinkedActive: [8083 8082], LinkedNotActive: [], Pending: []}
-2025/10/29 21:24:35 Node 8085 -> PSetState: {LinkedActive: [8082], LinkedNotActive: [], Pending: []}
-2025/10/29 21:24:35 ---------------------------
-2025/10/29 21:24:35 --- Test HelloHandshake Finished ---
-2025/10/29 21:24:35 --- Current VSet ---
-2025/10/29 21:24:35 Node 8086 -> VSet: []
-2025/10/29 21:24:35 Node 8082 -> VSet: [8083 8084 8085]
-2025/10/29 21:24:35 Node 8083 -> VSet: [8082 8084 8085]
-2025/10/29 21:24:35 Node 8084 -> VSet: [8082 8083 8085]
-2025/10/29 21:24:35 Node 8085 -> VSet: [8082 8083 8084]
-2025/10/29 21:24:35 --------------------
-2025/10/29 21:24:35 --- Current Routing Tables ---
-2025/10/29 21:24:35 Node 8086 -> RoutingTable: {empty}
-2025/10/29 21:24:35 Node 8082 -> RoutingTable:
-        - PathID: 420270169, Ea: 8084, Eb: 8085, Na: 8083, Nb: 8085
-        - PathID: 515004063, Ea: 8085, Eb: 8082, Na: 8085, Nb: 0
-        - PathID: 649850686, Ea: 8082, Eb: 8083, Na: 0, Nb: 8083
-        - PathID: 1298432611, Ea: 8082, Eb: 8084, Na: 0, Nb: 8084
-        - PathID: 1587357221, Ea: 8085, Eb: 8083, Na: 8085, Nb: 8083
-2025/10/29 21:24:35 Node 8083 -> RoutingTable:
-        - PathID: 420270169, Ea: 8084, Eb: 8085, Na: 8084, Nb: 8082
-        - PathID: 649850686, Ea: 8082, Eb: 8083, Na: 8082, Nb: 0
-        - PathID: 1587357221, Ea: 8085, Eb: 8083, Na: 8082, Nb: 0
-        - PathID: 3231993192, Ea: 8083, Eb: 8084, Na: 0, Nb: 8084
-2025/10/29 21:24:35 Node 8084 -> RoutingTable:
-        - PathID: 420270169, Ea: 8084, Eb: 8085, Na: 0, Nb: 8083
-        - PathID: 1298432611, Ea: 8082, Eb: 8084, Na: 8082, Nb: 0
-        - PathID: 3231993192, Ea: 8083, Eb: 8084, Na: 8083, Nb: 0
-2025/10/29 21:24:35 Node 8085 -> RoutingTable:
-        - PathID: 420270169, Ea: 8084, Eb: 8085, Na: 8082, Nb: 0
-        - PathID: 515004063, Ea: 8085, Eb: 8082, Na: 0, Nb: 8082
-        - PathID: 1587357221, Ea: 8085, Eb: 8083, Na: 0, Nb: 8082
-2025/10/29 21:24:35 ------------------------------
-2025/10/29 21:24:35 Simulation completed: Total messages: 99, Dropped: 0*/
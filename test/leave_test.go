@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试主动离开：物理邻居在一个链路延迟后就把离开的节点标记为失败，
+// 虚拟环重新收敛的时间短于同一节点崩溃的情况，收敛后没有节点的 vset 或路由表中还有离开的节点
+func TestLeave(t *testing.T) {
+	log.Println("--- Running Test: Leave ---")
+
+	// 超时自举和选举自举两种模式下分别运行：选举模式下离开的节点在最后一个 HELLO 中不能把自己通告为候选者
+	for _, mode := range []string{"timeout", "elect-lowest"} {
+		t.Run(mode, func(t *testing.T) {
+			testLeave(t, mode)
+		})
+	}
+}
+
+func testLeave(t *testing.T, mode string) {
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		const leaver = 8086
+		neighbors := []uint32{8082, 8085, 8087, 8090} // 4x3 网格中 8086 的物理邻居
+
+		// converge 构建收敛后的网格，对 8086 执行 remove，返回重新收敛所需的时间
+		converge := func(remove func(sim *network.Sim)) (*network.Sim, time.Duration) {
+			topo := network.GridTopology(4, 3, 8081)
+			topo.Bootstrap = mode
+			sim := convergedSim(t, topo, seed, 60*time.Second)
+			sim.Run(5 * time.Second)
+
+			remove(sim)
+			d, report := sim.Network.WaitConverged(30*time.Second, 50*time.Millisecond)
+			if !report.OK() {
+				t.Fatalf("ring did not converge after removing %d: %s", leaver, report)
+			}
+			return sim, d
+		}
+
+		crashSim, crashed := converge(func(sim *network.Sim) {
+			sim.Node(leaver).Stop()
+			sim.Network.UnregisterNode(leaver)
+		})
+		crashSim.Stop()
+
+		sim, left := converge(func(sim *network.Sim) {
+			sim.Node(leaver).Leave()
+			sim.Run(100 * time.Millisecond)
+			for _, id := range neighbors {
+				if status := sim.Node(id).PsetManager.GetStatus(leaver); status != vrr.PSET_FAILED {
+					t.Errorf("node %d sees %d as %s right after it left", id, leaver, vrr.PsetStatusString(status))
+				}
+				for _, p := range sim.Node(id).PsetManager.GetAll() {
+					if p.NodeId == leaver && p.Candidate != 0 {
+						t.Errorf("node %d saw %d advertise candidate %d while leaving", id, leaver, p.Candidate)
+					}
+				}
+			}
+		})
+		log.Printf("ring converged %v after leave, %v after crash", left, crashed)
+		if left >= crashed {
+			t.Errorf("ring converged in %v after leave, not faster than %v after crash", left, crashed)
+		}
+
+		n := sim.Node(leaver)
+		if !n.Stopped() || n.Active || len(n.VsetManager.GetAll()) != 0 || len(n.RoutingTable.Routes()) != 0 {
+			t.Errorf("node %d still has state after leaving", leaver)
+		}
+		if vrr.ContainsID(sim.Network.GetAllNodes(), leaver) {
+			t.Errorf("node %d still registered", leaver)
+		}
+		n.Leave() // 再次离开不做任何事
+
+		for _, other := range sim.Nodes {
+			if other.ID == leaver {
+				continue
+			}
+			if other.VsetManager.Contains(leaver) {
+				t.Errorf("node %d still has %d in its vset", other.ID, leaver)
+			}
+			for _, r := range other.RoutingTable.Routes() {
+				if r.Ea == leaver || r.Eb == leaver || r.Na == leaver || r.Nb == leaver {
+					t.Errorf("node %d still routes via %d: %+v", other.ID, leaver, r)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试链路模型：链路/子网/全局设置的优先级、单向丢包、固定延迟和带宽排队
+func TestLinkModel(t *testing.T) {
+	log.Println("--- Running Test: LinkModel ---")
+
+	// Subnet 1: Node 1, Node 2
+	// Subnet 2: Node 2, Node 3
+	// 节点不启动，直接从 InboxChan 读取网络投递的消息
+	net := network.NewNetwork(0, 0)
+	n1 := vrr.NewNode(1, net)
+	n2 := vrr.NewNode(2, net)
+	n3 := vrr.NewNode(3, net)
+	net.RegisterNode(n1, 1)
+	net.RegisterNode(n2, 1, 2)
+	net.RegisterNode(n3, 2)
+
+	data := func(from, to uint32, size int) vrr.Message {
+		return vrr.Message{Type: vrr.VRR_DATA, Src: from, Dst: to, NextHop: to, Sender: from,
+			Payload: &vrr.DataPayload{Data: make([]byte, size)}}
+	}
+	// recv 等待节点收到一条消息，返回从 start 起经过的时间
+	recv := func(n *vrr.Node, start time.Time, timeout time.Duration) (time.Duration, bool) {
+		select {
+		case <-n.InboxChan:
+			return time.Since(start), true
+		case <-time.After(timeout):
+			return 0, false
+		}
+	}
+
+	// --- 优先级 ---
+	net.SetSubnetProfile(2, network.LinkProfile{Latency: 100 * time.Millisecond})
+	net.SetLinkProfile(3, 2, network.LinkProfile{PacketLoss: 1})
+	if p := net.GetLinkProfile(1, 2); p.Latency != 0 || p.PacketLoss != 0 {
+		t.Errorf("link 1->2 should use global defaults, got %+v", p)
+	}
+	if p := net.GetLinkProfile(2, 3); p.Latency != 100*time.Millisecond {
+		t.Errorf("link 2->3 should use subnet 2 profile, got %+v", p)
+	}
+	if p := net.GetLinkProfile(3, 2); p.PacketLoss != 1 {
+		t.Errorf("link 3->2 should use its own profile, got %+v", p)
+	}
+
+	// --- 默认设置：立即投递 ---
+	net.Send(data(1, 2, 0))
+	if _, ok := recv(n2, time.Now(), 100*time.Millisecond); !ok {
+		t.Error("default link 1->2 did not deliver")
+	}
+
+	// --- 单向丢包 ---
+	net.Send(data(3, 2, 0))
+	if _, ok := recv(n2, time.Now(), 200*time.Millisecond); ok {
+		t.Error("link 3->2 with 100% loss delivered a message")
+	}
+	start := time.Now()
+	net.Send(data(2, 3, 0))
+	if elapsed, ok := recv(n3, start, time.Second); !ok {
+		t.Error("link 2->3 did not deliver")
+	} else if elapsed < 100*time.Millisecond {
+		t.Errorf("link 2->3 delivered after %v, expected at least 100ms", elapsed)
+	}
+
+	// --- 带宽排队：每个报文 125 字节，8000 bit/s 下发送时间为 125ms ---
+	net.SetLinkProfile(1, 2, network.LinkProfile{Bandwidth: 8000})
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		net.Send(data(1, 2, 125-vrr.VRR_HEADER_LEN-5))
+	}
+	var last time.Duration
+	for i := 0; i < 3; i++ {
+		elapsed, ok := recv(n2, start, time.Second)
+		if !ok {
+			t.Fatalf("bandwidth-limited link 1->2 delivered only %d of 3 messages", i)
+		}
+		last = elapsed
+	}
+	if last < 375*time.Millisecond {
+		t.Errorf("third message delivered after %v, expected queueing delay of at least 375ms", last)
+	}
+
+	// --- 运行时清除设置后恢复默认 ---
+	net.ClearLinkProfile(1, 2)
+	net.ClearSubnetProfile(2)
+	if p := net.GetLinkProfile(2, 3); p.Latency != 0 {
+		t.Errorf("link 2->3 should fall back to global defaults, got %+v", p)
+	}
+}
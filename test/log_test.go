@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// syncBuffer 是并发安全的日志缓冲区
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+// records 解析缓冲区中的 JSON 日志并清空缓冲区
+func (b *syncBuffer) records(t *testing.T) []map[string]any {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("malformed log record %q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	b.buf.Reset()
+	return records
+}
+
+// countSubsystem 统计指定子系统的日志条数
+func countSubsystem(records []map[string]any, subsystem string) int {
+	count := 0
+	for _, r := range records {
+		if r["subsystem"] == subsystem {
+			count++
+		}
+	}
+	return count
+}
+
+// 测试结构化日志：每条日志带有子系统和节点属性，setup 事件带有消息类型和路径ID，
+// HELLO 日志默认不输出，子系统的级别可以在运行时单独调整
+func TestStructuredLogging(t *testing.T) {
+	log.Println("--- Running Test: StructuredLogging ---")
+
+	levels, err := vrr.ParseLogLevels("info, hello=debug ,routing=warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels.Level(vrr.LOG_HELLO) != slog.LevelDebug || levels.Level(vrr.LOG_ROUTING) != slog.LevelWarn || levels.Level(vrr.LOG_PSET) != slog.LevelInfo {
+		t.Errorf("parsed levels: %s", levels)
+	}
+	if s := levels.String(); s != "info,hello=debug,routing=warn" {
+		t.Errorf("levels string %q", s)
+	}
+	for _, bad := range []string{"loud", "bogus=info", "pset=loud"} {
+		if _, err := vrr.ParseLogLevels(bad); err == nil {
+			t.Errorf("ParseLogLevels(%q) accepted an invalid spec", bad)
+		}
+	}
+
+	out := &syncBuffer{}
+	levels = vrr.NewLogLevels(slog.LevelInfo)
+	logger := vrr.NewLogger(slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug})), levels)
+
+	sim := buildSim(t, network.LineTopology(3, 8081), 1)
+	sim.SetLogger(logger)
+	sim.Start()
+	waitRing(t, sim.Network, 20*time.Second)
+
+	records := out.records(t)
+	if len(records) == 0 {
+		t.Fatal("no log records")
+	}
+	setups := 0
+	for _, r := range records {
+		if _, ok := r["subsystem"]; !ok {
+			t.Errorf("record without subsystem: %v", r)
+		}
+		if r["subsystem"] != vrr.LOG_NETWORK && r["node"] == nil {
+			t.Errorf("node record without node attribute: %v", r)
+		}
+		if r["type"] == "VRR_SETUP" && r["msg"] == "sending setup" {
+			setups++
+			if r["pid"] == nil {
+				t.Errorf("setup record without pid: %v", r)
+			}
+		}
+	}
+	if setups == 0 {
+		t.Errorf("no setup events logged")
+	}
+	if n := countSubsystem(records, vrr.LOG_HELLO); n != 0 {
+		t.Errorf("%d HELLO records logged at the default level", n)
+	}
+
+	// 打开 HELLO 日志，关闭 pset 日志
+	levels.Set(vrr.LOG_HELLO, slog.LevelDebug)
+	levels.Set(vrr.LOG_PSET, slog.LevelError)
+	sim.Run(3 * time.Second)
+	records = out.records(t)
+	if countSubsystem(records, vrr.LOG_HELLO) == 0 {
+		t.Errorf("no HELLO records after enabling debug level")
+	}
+	if n := countSubsystem(records, vrr.LOG_PSET); n != 0 {
+		t.Errorf("%d pset records logged with the subsystem silenced", n)
+	}
+
+	// 关闭 HELLO 日志后拆除一条路径，teardown 事件仍然输出
+	levels.Set(vrr.LOG_HELLO, slog.LevelInfo)
+	routes := sim.Node(8081).RoutingTable.RoutesTo(8082)
+	if len(routes) == 0 {
+		t.Fatalf("Node 8081 has no path to 8082")
+	}
+	sim.Node(8081).RoutingTable.TearDownPath(routes[0].PathId, routes[0].Ea, 0)
+	sim.Run(time.Second)
+	records = out.records(t)
+	teardowns := 0
+	for _, r := range records {
+		if r["type"] == "VRR_TEARDOWN" {
+			teardowns++
+		}
+	}
+	if teardowns == 0 {
+		t.Errorf("no teardown events logged")
+	}
+	if n := countSubsystem(records, vrr.LOG_HELLO); n != 0 {
+		t.Errorf("%d HELLO records logged after silencing", n)
+	}
+}
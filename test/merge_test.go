@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试两个独立自举的虚拟环在物理连通后合并为一个环
+func TestRingMerge(t *testing.T) {
+	log.Println("--- Running Test: RingMerge ---")
+	network := network.NewNetwork(50*time.Millisecond, 0.0)
+
+	// --- 定义拓扑 ---
+	// Subnet 1: Node 1, Node 5, Node 7 (Node 1 活跃，构成环 A)
+	// Subnet 2: Node 2, Node 4, Node 6 (Node 2 活跃，构成环 B)
+	// 之后加入 Router: Node 3 (连接 Subnet 1 和 Subnet 2)
+
+	node1 := vrr.NewNode(8081, network)
+	node5 := vrr.NewNode(8085, network)
+	node7 := vrr.NewNode(8087, network)
+	node2 := vrr.NewNode(8082, network)
+	node4 := vrr.NewNode(8084, network)
+	node6 := vrr.NewNode(8086, network)
+
+	network.RegisterNode(node1, 1)
+	node1.SetActive(true)
+	network.RegisterNode(node5, 1)
+	network.RegisterNode(node7, 1)
+	network.RegisterNode(node2, 2)
+	node2.SetActive(true)
+	network.RegisterNode(node4, 2)
+	network.RegisterNode(node6, 2)
+
+	nodes := []*vrr.Node{node1, node5, node7, node2, node4, node6}
+	for _, n := range nodes {
+		n.Start()
+		defer n.Stop()
+	}
+
+	log.Println("\n--- Waiting for two independent rings to form... ---")
+	time.Sleep(3 * time.Second)
+	printAllVsets(nodes)
+
+	// 两个子网各自形成一个环
+	for _, n := range []*vrr.Node{node1, node5, node7} {
+		if n.RingID != node1.ID {
+			t.Fatalf("Node %d: expected ring %d before merge, got %d", n.ID, node1.ID, n.RingID)
+		}
+	}
+	for _, n := range []*vrr.Node{node2, node4, node6} {
+		if n.RingID != node2.ID {
+			t.Fatalf("Node %d: expected ring %d before merge, got %d", n.ID, node2.ID, n.RingID)
+		}
+	}
+
+	// 路由器节点连接两个子网，两个环应当合并
+	log.Println("\n--- Registering router Node 8083 to join both subnets ---")
+	node3 := vrr.NewNode(8083, network)
+	network.RegisterNode(node3, 1, 2)
+	node3.Start()
+	defer node3.Stop()
+	nodes = append(nodes, node3)
+
+	time.Sleep(6 * time.Second)
+	printAllVsets(nodes)
+	printAllRoutes(nodes)
+
+	for _, n := range nodes {
+		if n.RingID != node1.ID {
+			t.Errorf("Node %d: expected merged ring %d, got %d", n.ID, node1.ID, n.RingID)
+		}
+	}
+	assertRing(t, network)
+}
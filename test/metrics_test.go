@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+func label(name, value string) vrr.Label {
+	return vrr.Label{Name: name, Value: value}
+}
+
+// 测试指标：环收敛后的 vset、物理邻居和激活状态与拓扑一致，节点发送的单播消息数
+// 等于网络的单跳发送数，丢包、未知目标和无路由分别按原因计数，注册表输出 Prometheus 文本格式
+func TestMetrics(t *testing.T) {
+	log.Println("--- Running Test: Metrics ---")
+
+	// 单独的节点没有路由，发送数据被计为 no_route 丢弃
+	lonely := vrr.NewNode(1, nil)
+	registry := vrr.NewRegistry()
+	registry.Register(lonely)
+	registry.Register(lonely) // 重复注册被忽略
+	if lonely.SendData(2, []byte("x")) {
+		t.Fatalf("SendData without routes succeeded")
+	}
+	if got := registry.Sum("vrr_messages_dropped_total", label("reason", vrr.DROP_NO_ROUTE), label("type", "data")); got != 1 {
+		t.Errorf("no_route drops = %v, expected 1", got)
+	}
+
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		const size = 8
+		sim := convergedSim(t, network.RingTopology(size, 8081), seed, 30*time.Second)
+		sim.Run(2 * time.Second)
+
+		reg := sim.Network.Registry()
+		gauges := []struct {
+			name   string
+			labels []vrr.Label
+			want   float64
+		}{
+			{"vrr_nodes", nil, size},
+			{"vrr_active", nil, size},
+			{"vrr_vset_size", nil, size * vrr.VRR_VSET_SIZE},
+			{"vrr_pset_neighbors", []vrr.Label{label("state", "linked")}, size * 2},
+			{"vrr_pset_neighbors", []vrr.Label{label("state", "failed")}, 0},
+			{"vrr_vset_size", []vrr.Label{label("node", "8081")}, vrr.VRR_VSET_SIZE},
+		}
+		for _, g := range gauges {
+			if got := reg.Sum(g.name, g.labels...); got != g.want {
+				t.Errorf("%s%v = %v, expected %v", g.name, g.labels, got, g.want)
+			}
+		}
+		if routes := reg.Sum("vrr_routes"); routes == 0 {
+			t.Errorf("no routes counted")
+		}
+
+		requests, success := reg.Sum("vrr_setup_requests_total"), reg.Sum("vrr_setup_success_total")
+		if requests == 0 || success == 0 {
+			t.Errorf("setup requests %v, successes %v", requests, success)
+		}
+		t.Logf("setup requests %v, successes %v, failures %v", requests, success, reg.Sum("vrr_setup_failures_total"))
+
+		// 单播消息每次发送对应一次单跳发送，广播的 HELLO 按接收者分别计数
+		for _, msgType := range []string{"setup_req", "setup", "setup_fail", "teardown"} {
+			typ := label("type", msgType)
+			sent, hops := reg.Sum("vrr_messages_sent_total", typ), reg.Sum("vrr_network_transmissions_total", typ)
+			if sent != hops {
+				t.Errorf("%s: nodes sent %v, network transmitted %v", msgType, sent, hops)
+			}
+			if forwarded := reg.Sum("vrr_messages_forwarded_total", typ); forwarded > sent {
+				t.Errorf("%s: forwarded %v exceeds sent %v", msgType, forwarded, sent)
+			}
+		}
+		hello := label("type", "hello")
+		if sent, hops := reg.Sum("vrr_messages_sent_total", hello), reg.Sum("vrr_network_transmissions_total", hello); hops != 2*sent {
+			t.Errorf("hello: %v broadcasts in a ring, expected %v transmissions, got %v", sent, 2*sent, hops)
+		}
+		if received := reg.Sum("vrr_messages_received_total", hello); received == 0 {
+			t.Errorf("no hello received")
+		}
+
+		// 8081 -> 8082 方向的链路全部丢包，以及发往不存在的节点
+		sim.Network.SetLinkProfile(8081, 8082, network.LinkProfile{Latency: 20 * time.Millisecond, PacketLoss: 1})
+		sim.Network.Send(vrr.Message{Type: vrr.VRR_DATA, Src: 8083, Dst: 999, Sender: 8083, NextHop: 999, Payload: &vrr.DataPayload{}})
+		sim.Run(time.Second)
+		if got := reg.Sum("vrr_messages_dropped_total", label("node", "8081"), hello, label("reason", vrr.DROP_LOSS)); got == 0 {
+			t.Errorf("no hello from 8081 counted as lost")
+		}
+		if got := reg.Sum("vrr_messages_dropped_total", label("node", "8083"), label("reason", vrr.DROP_UNKNOWN_TARGET)); got != 1 {
+			t.Errorf("unknown target drops from 8083 = %v, expected 1", got)
+		}
+		// GetMsgInfo 统计的丢弃数只包含网络层的原因
+		netDrops := 0.0
+		for _, reason := range []string{vrr.DROP_LOSS, vrr.DROP_UNKNOWN_TARGET, vrr.DROP_INBOX_FULL} {
+			netDrops += reg.Sum("vrr_messages_dropped_total", label("reason", reason))
+		}
+		if _, dropped := sim.Network.GetMsgInfo(); float64(dropped) != netDrops {
+			t.Errorf("GetMsgInfo reports %d dropped, metrics %v", dropped, netDrops)
+		}
+
+		// 注销的节点不再出现在指标中
+		sim.Network.UnregisterNode(8088)
+		if got := reg.Sum("vrr_active", label("node", "8088")); got != 0 || reg.Sum("vrr_nodes") != size-1 {
+			t.Errorf("unregistered node still collected")
+		}
+
+		// Prometheus 文本格式
+		server := httptest.NewServer(reg)
+		defer server.Close()
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+			t.Errorf("content type %q", ct)
+		}
+		text := string(body)
+		for _, want := range []string{
+			"# TYPE vrr_messages_sent_total counter\n",
+			"# TYPE vrr_vset_size gauge\n",
+			`vrr_vset_size{node="8081"} 4` + "\n",
+			`vrr_pset_neighbors{node="8082",state="linked"} 2` + "\n",
+			`vrr_messages_dropped_total{node="8083",type="data",reason="unknown_target"} 1` + "\n",
+			"vrr_nodes 7\n",
+		} {
+			if !strings.Contains(text, want) {
+				t.Errorf("metrics output lacks %q", want)
+			}
+		}
+		// 每个指标的 HELP 和 TYPE 只出现一次，节点按数值顺序排列
+		if n := strings.Count(text, "# TYPE vrr_messages_dropped_total "); n != 1 {
+			t.Errorf("dropped family declared %d times", n)
+		}
+		if strings.Index(text, `vrr_active{node="8081"}`) > strings.Index(text, `vrr_active{node="8087"}`) {
+			t.Errorf("samples not ordered by node")
+		}
+
+		var buf bytes.Buffer
+		escaped := []vrr.MetricFamily{{Name: "x", Help: "h", Type: vrr.METRIC_GAUGE, Samples: []vrr.Sample{{Labels: []vrr.Label{label("v", "a\"b\\c\nd")}, Value: 1.5}}}}
+		if err := vrr.WritePrometheus(&buf, escaped); err != nil || !strings.Contains(buf.String(), `x{v="a\"b\\c\nd"} 1.5`) {
+			t.Errorf("escaped output %q, err %v", buf.String(), err)
+		}
+	})
+}
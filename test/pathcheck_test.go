@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// newCaptureNode 创建一个运行在虚拟时钟上、发出的消息只被记录的节点
+func newCaptureNode(id uint32) (*vrr.Node, *captureNetwork, *vrr.VirtualClock) {
+	capture := &captureNetwork{}
+	clock := vrr.NewVirtualClock(time.Unix(0, 0))
+	node := vrr.NewNode(id, capture)
+	node.SetClock(clock)
+	node.SetSeed(int64(id))
+	return node, capture, clock
+}
+
+// countTo 统计消息列表中发往指定目的地的消息数
+func countTo(msgs []vrr.Message, dst uint32) int {
+	count := 0
+	for _, msg := range msgs {
+		if msg.Dst == dst {
+			count++
+		}
+	}
+	return count
+}
+
+// 测试周期性的 vset 与路由表一致性检查：拆除没有 vset 对应的自有路径，重新请求没有路径的 vset 成员
+func TestCheckVsetPaths(t *testing.T) {
+	log.Println("--- Running Test: CheckVsetPaths ---")
+	node, capture, clock := newCaptureNode(8081)
+	node.PsetManager.Add(8082, vrr.PSET_LINKED, true)
+	node.SetActive(true)
+
+	// 8083 不在 vset 中，本节点却仍是到它的路径的端点（例如 teardown 丢失）
+	node.RoutingTable.Add(8081, 8083, 0, 8082, 1)
+	// 8084 在 vset 中，但没有任何路径（例如 setup 丢失）
+	node.VsetManager.Add(8084)
+
+	node.Start()
+	defer node.Stop()
+	clock.RunFor(time.Second)
+
+	if node.RoutingTable.HasPathTo(8083) {
+		t.Errorf("path to 8083 is not backed by the vset but was kept:\n%s", node.RoutingTable.String())
+	}
+	torn := false
+	for _, msg := range capture.sent(vrr.VRR_TEARDOWN) {
+		if payload := msg.Payload.(*vrr.TeardownPayload); payload.Pid == 1 && msg.NextHop == 8082 {
+			torn = true
+		}
+	}
+	if !torn {
+		t.Errorf("no teardown for path 1 was sent to 8082")
+	}
+
+	if node.VsetManager.Contains(8084) {
+		t.Errorf("vset neighbor 8084 without a vset-path was kept: %v", node.VsetManager.GetAll())
+	}
+	if countTo(capture.sent(vrr.VRR_SETUP_REQ), 8084) == 0 {
+		t.Errorf("no setup_req was sent to repair the path to 8084")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试路由核对：节点周期性地把经过每个已链接邻居的路径发给该邻居，刚加入的条目等到下一次核对；
+// 收到核对的节点对自己没有、或者没有经过发送者的路径回复 teardown；
+// 节点快速重启（邻居来不及发现失败）后，遗留在邻居上的半条路径被拆除，虚拟环重新收敛
+func TestPathSync(t *testing.T) {
+	log.Println("--- Running Test: PathSync ---")
+
+	// --- 发送：每个 HELLO 周期核对一次 ---
+	capture := &captureNetwork{}
+	clock := vrr.NewVirtualClock(time.Unix(0, 0))
+	node, err := vrr.NewNodeWithConfig(8082, capture, vrr.Config{PathSync: 1, HelloJitter: -1, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.PsetManager.Add(8081, vrr.PSET_LINKED, true)
+	node.PsetManager.Add(8083, vrr.PSET_LINKED, false)
+	node.PsetManager.Add(8084, vrr.PSET_PENDING, false)
+	node.RoutingTable.Add(8081, 8083, 8081, 8083, 1)
+	node.RoutingTable.Add(8081, 8084, 8081, 8084, 2)
+
+	node.Start()
+	defer node.Stop()
+	// 第一个周期(500ms)只记下已有的条目，第二个周期(1s)发出核对；期间加入的条目不参与
+	clock.RunFor(700 * time.Millisecond)
+	if syncs := capture.sent(vrr.VRR_PATH_SYNC); len(syncs) != 0 {
+		t.Errorf("path_sync sent before the entries were one period old: %+v", syncs)
+	}
+	node.RoutingTable.Add(8083, 8081, 8083, 8081, 3)
+	clock.RunFor(500 * time.Millisecond)
+
+	synced := make(map[uint32][]vrr.PathRef)
+	for _, msg := range capture.sent(vrr.VRR_PATH_SYNC) {
+		synced[msg.NextHop] = append(synced[msg.NextHop], msg.Payload.(*vrr.PathSyncPayload).Paths...)
+	}
+	if got := synced[8081]; len(got) != 2 || got[0] != (vrr.PathRef{Pid: 1, Endpoint: 8081}) || got[1] != (vrr.PathRef{Pid: 2, Endpoint: 8081}) {
+		t.Errorf("path_sync to 8081 lists %v, expected paths 1 and 2", got)
+	}
+	if got := synced[8083]; len(got) != 1 || got[0] != (vrr.PathRef{Pid: 1, Endpoint: 8081}) {
+		t.Errorf("path_sync to 8083 lists %v, expected path 1", got)
+	}
+	if got := synced[8084]; len(got) != 0 {
+		t.Errorf("path_sync sent to pending neighbor 8084: %v", got)
+	}
+
+	// --- 接收：没有的路径和没有经过发送者的路径回复 teardown ---
+	peer, peerCapture, _ := newCaptureNode(8083)
+	peer.PsetManager.Add(8082, vrr.PSET_LINKED, true)
+	peer.PsetManager.Add(8085, vrr.PSET_LINKED, true)
+	peer.RoutingTable.Add(8081, 8083, 8082, 0, 1)
+	peer.RoutingTable.Add(8081, 8083, 8085, 0, 5)
+	peer.Receive(vrr.Message{Type: vrr.VRR_PATH_SYNC, Src: 8082, Dst: 8083, NextHop: 8083, Sender: 8082,
+		Payload: &vrr.PathSyncPayload{Paths: []vrr.PathRef{{Pid: 1, Endpoint: 8081}, {Pid: 2, Endpoint: 8081}, {Pid: 5, Endpoint: 8081}}}})
+
+	var torn []uint32
+	for _, msg := range peerCapture.sent(vrr.VRR_TEARDOWN) {
+		if msg.NextHop != 8082 {
+			t.Errorf("teardown sent to %d instead of the path_sync sender", msg.NextHop)
+		}
+		torn = append(torn, msg.Payload.(*vrr.TeardownPayload).Pid)
+	}
+	if len(torn) != 2 || torn[0] != 2 || torn[1] != 5 {
+		t.Errorf("expected teardowns for paths 2 and 5, got %v", torn)
+	}
+	if _, ok := peer.RoutingTable.Lookup(5, 8081); !ok {
+		t.Errorf("path 5 via 8085 was removed by a path_sync from 8082")
+	}
+
+	// --- 快速重启：邻居没有把 8085 标记为失败，经过它的路径由核对拆除并重建 ---
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		sim := convergedSim(t, network.GridTopology(3, 3, 8081), seed, 30*time.Second)
+		sim.Run(5 * time.Second)
+
+		restarted := sim.Node(8085)
+		restarted.Stop()
+		restarted.Reset()
+		restarted.Start()
+		sim.Run(20 * time.Second)
+		assertRing(t, sim.Network)
+		assertRoutes(t, sim.Network)
+	})
+}
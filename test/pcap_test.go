@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试抓包导出：收敛过程中的每次发送都有对应的投递或丢弃记录，时间戳为仿真时间且不递减，
+// 单播记录带有所在子网，丢弃记录带有原因；导出的文件可以被 ReadPcap 读回
+func TestPcapCapture(t *testing.T) {
+	log.Println("--- Running Test: PcapCapture ---")
+
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		sim := buildSim(t, network.LineTopology(4, 8081), seed)
+
+		var buf bytes.Buffer
+		capture, err := network.NewPcapWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sim.Network.SetCapture(capture)
+		start := sim.Clock.Now()
+
+		// 8083 -> 8084 方向丢包 30%，收敛后在该方向上再直接发送 20 条数据，保证有丢包记录；
+		// 另外发送一条目标不存在的数据
+		sim.Network.SetLinkProfile(8083, 8084, network.LinkProfile{Latency: 20 * time.Millisecond, PacketLoss: 0.3})
+		sim.Start()
+		waitRing(t, sim.Network, 30*time.Second)
+		for i := 0; i < 20; i++ {
+			sim.Network.Send(vrr.Message{Type: vrr.VRR_DATA, Src: 8083, Dst: 8084, Sender: 8083, NextHop: 8084, Payload: &vrr.DataPayload{Data: []byte("y")}})
+		}
+		sim.Network.Send(vrr.Message{Type: vrr.VRR_DATA, Src: 8082, Dst: 999, Sender: 8082, NextHop: 999, Payload: &vrr.DataPayload{Data: []byte("x")}})
+		sim.Run(time.Second)
+		sim.Network.SetCapture(nil)
+		end := sim.Clock.Now()
+
+		raw := buf.Bytes()
+		if len(raw) < 8 || binary.LittleEndian.Uint32(raw[0:4]) != 0x0A0D0D0A {
+			t.Fatalf("capture does not start with a pcapng section header")
+		}
+		if !bytes.Contains(raw, []byte("drop: loss")) {
+			t.Errorf("no drop comment in capture")
+		}
+
+		records, err := network.ReadPcap(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) == 0 {
+			t.Fatal("no records captured")
+		}
+
+		var sends, delivers, losses, unknown int
+		types := make(map[uint8]bool)
+		last := start
+		for _, rec := range records {
+			if rec.Time.Before(last) || rec.Time.After(end) {
+				t.Fatalf("record at %v outside [%v, %v] or out of order", rec.Time, last, end)
+			}
+			last = rec.Time
+			types[rec.Msg.Type] = true
+
+			switch rec.Event {
+			case network.CAPTURE_SEND:
+				sends++
+				if rec.Subnet == 0 && rec.Msg.NextHop != 999 {
+					t.Errorf("send %d -> %d without subnet", rec.Msg.Sender, rec.Msg.NextHop)
+				}
+			case network.CAPTURE_DELIVER:
+				delivers++
+			case network.CAPTURE_DROP:
+				switch rec.Reason {
+				case vrr.DROP_LOSS:
+					losses++
+					if rec.Msg.Sender != 8083 || rec.Msg.NextHop != 8084 {
+						t.Errorf("loss on link %d -> %d", rec.Msg.Sender, rec.Msg.NextHop)
+					}
+				case vrr.DROP_UNKNOWN_TARGET:
+					unknown++
+					if rec.Msg.NextHop != 999 || string(rec.Msg.Payload.(*vrr.DataPayload).Data) != "x" {
+						t.Errorf("unexpected unknown-target record %+v", rec.Msg)
+					}
+				default:
+					t.Errorf("unexpected drop reason %q", rec.Reason)
+				}
+			}
+		}
+		if losses == 0 || unknown != 1 {
+			t.Errorf("%d loss records, %d unknown-target records", losses, unknown)
+		}
+		// 每次发送最终被投递或丢弃，抓包停止时可能还有未到达的消息
+		if delivers+losses+unknown > sends || sends-delivers-losses-unknown > 8 {
+			t.Errorf("%d sends, %d delivers, %d losses, %d unknown", sends, delivers, losses, unknown)
+		}
+		for _, msgType := range []uint8{vrr.VRR_HELLO, vrr.VRR_SETUP_REQ, vrr.VRR_SETUP} {
+			if !types[msgType] {
+				t.Errorf("no %s captured", vrr.GetMessageTypeString(msgType))
+			}
+		}
+		total, _ := sim.Network.GetMsgInfo()
+		if uint64(sends) > total {
+			t.Errorf("%d sends captured, network counted %d", sends, total)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+const ringSize = uint64(1) << 32
+
+// refClockwise 用 64 位整数取模计算顺时针距离，作为环度量的参照
+func refClockwise(from, to uint32) uint64 {
+	return (uint64(to) + ringSize - uint64(from)) % ringSize
+}
+
+// refNeighbors 用参照距离计算 me 的理想虚拟邻居：按顺时针距离排序后取首尾各 size/2 个
+func refNeighbors(me uint32, ids []uint32, size int) []uint32 {
+	seen := map[uint32]bool{me: true, 0: true}
+	var ring []uint32
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			ring = append(ring, id)
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return refClockwise(me, ring[i]) < refClockwise(me, ring[j]) })
+	if len(ring) <= size {
+		return ring
+	}
+	return append(append([]uint32(nil), ring[:size/2]...), ring[len(ring)-size/2:]...)
+}
+
+// boundaryID 生成靠近 0 或 2^32-1 的ID，偶尔生成任意ID
+func boundaryID(rng *rand.Rand) uint32 {
+	switch rng.Intn(3) {
+	case 0:
+		return uint32(rng.Intn(32))
+	case 1:
+		return ^uint32(0) - uint32(rng.Intn(32))
+	}
+	return rng.Uint32()
+}
+
+func sameIDs(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[uint32]bool, len(a))
+	for _, id := range a {
+		seen[id] = true
+	}
+	for _, id := range b {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// 测试环度量：距离和排序在 0 与 2^32-1 附近回绕时与 64 位参照实现一致
+func TestRingMetric(t *testing.T) {
+	log.Println("--- Running Test: RingMetric ---")
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		a, b, c := boundaryID(rng), boundaryID(rng), boundaryID(rng)
+
+		cw, ccw := refClockwise(a, b), refClockwise(b, a)
+		if uint64(vrr.RingClockwise(a, b)) != cw || uint64(vrr.RingCounterClockwise(a, b)) != ccw {
+			t.Fatalf("clockwise/counter-clockwise distance %d -> %d: got %d/%d, expected %d/%d",
+				a, b, vrr.RingClockwise(a, b), vrr.RingCounterClockwise(a, b), cw, ccw)
+		}
+		want := min(cw, ccw)
+		if d := vrr.RingDistance(a, b); uint64(d) != want || d != vrr.RingDistance(b, a) || d > 1<<31 {
+			t.Fatalf("RingDistance(%d, %d) = %d, expected %d", a, b, d, want)
+		}
+
+		// 距离较小者更近，距离相同时取较小的ID，且关系是反对称的
+		db, dc := min(refClockwise(a, b), refClockwise(b, a)), min(refClockwise(a, c), refClockwise(c, a))
+		closer := db < dc || (db == dc && b < c)
+		if got := vrr.RingCloser(a, b, c); got != closer || (b != c && vrr.RingCloser(a, c, b) == closer) {
+			t.Fatalf("RingCloser(%d, %d, %d) = %v, expected %v", a, b, c, got, closer)
+		}
+	}
+
+	// 跨越 0 的邻居：0 附近的节点以 2^32-1 附近的节点为前驱
+	me := uint32(3)
+	ids := []uint32{^uint32(0) - 1, ^uint32(0), 1, 7, 1 << 31, ^uint32(0) - 9}
+	got := vrr.RingNeighbors(me, ids, 4)
+	want := []uint32{7, 1 << 31, ^uint32(0), 1}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("RingNeighbors(%d, %v) = %v, expected %v", me, ids, got, want)
+		}
+	}
+
+	for i := 0; i < 2000; i++ {
+		me := boundaryID(rng)
+		ids := make([]uint32, rng.Intn(12))
+		for j := range ids {
+			ids[j] = boundaryID(rng)
+		}
+		if got, want := vrr.RingNeighbors(me, ids, 4), refNeighbors(me, ids, 4); !sameIDs(got, want) {
+			t.Fatalf("RingNeighbors(%d, %v) = %v, expected %v", me, ids, got, want)
+		}
+	}
+}
+
+// 测试 VsetManager 与路由表使用同一环度量：逐个加入靠近 0 和 2^32-1 的节点后，
+// vset 等于全部节点中的理想虚拟邻居，ShouldAdd 与理想邻居的判断一致；
+// 路由时跨越 0 选择最近的端点
+func TestRingVset(t *testing.T) {
+	log.Println("--- Running Test: RingVset ---")
+
+	rng := rand.New(rand.NewSource(2))
+	for round := 0; round < 200; round++ {
+		me := boundaryID(rng)
+		if me == 0 {
+			me = 1
+		}
+		node := vrr.NewNode(me, nil)
+		var added []uint32
+		for j := 0; j < 10; j++ {
+			id := boundaryID(rng)
+			vset := node.VsetManager.GetAll()
+			should := id != me && id != 0 && !vrr.ContainsID(vset, id) && vrr.ContainsID(refNeighbors(me, append(vset, id), vrr.VRR_VSET_SIZE), id)
+			if got := node.VsetManager.ShouldAdd(id); got != should {
+				t.Fatalf("node %d with vset %v: ShouldAdd(%d) = %v, expected %v", me, vset, id, got, should)
+			}
+			if should {
+				node.VsetManager.Add(id)
+			}
+			added = append(added, id)
+		}
+		if got, want := node.VsetManager.GetAll(), refNeighbors(me, added, vrr.VRR_VSET_SIZE); !sameIDs(got, want) {
+			t.Fatalf("node %d after adding %v: vset %v, expected %v", me, added, got, want)
+		}
+	}
+
+	// 目标 1 离端点 2^32-3 的距离为 4，离端点 100 的距离为 99
+	node := vrr.NewNode(200, nil)
+	node.RoutingTable.Add(200, ^uint32(0)-2, 0, 11, 1)
+	node.RoutingTable.Add(200, 100, 0, 12, 2)
+	if next := node.RoutingTable.GetNext(1); next != 11 {
+		t.Errorf("GetNext(1) = %d, expected 11 towards endpoint %d", next, ^uint32(0)-2)
+	}
+	if next := node.RoutingTable.GetNextExclude(1, ^uint32(0)-2); next != 12 {
+		t.Errorf("GetNextExclude(1, %d) = %d, expected 12 towards endpoint 100", ^uint32(0)-2, next)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试路由表不变量检查：手工构造的路由表中每一类问题都能被发现，收敛后的网络没有问题
+func TestValidateRoutes(t *testing.T) {
+	log.Println("--- Running Test: ValidateRoutes ---")
+
+	// 链状物理拓扑 1-2-3-4，3 到 4 的链路已失败
+	nodes := make(map[uint32]*vrr.Node)
+	for id := uint32(1); id <= 4; id++ {
+		nodes[id] = vrr.NewNode(id, nil)
+	}
+	link := func(a, b, status uint32) {
+		nodes[a].PsetManager.Add(b, status, true)
+		nodes[b].PsetManager.Add(a, status, true)
+	}
+	link(1, 2, vrr.PSET_LINKED)
+	link(2, 3, vrr.PSET_LINKED)
+	link(3, 4, vrr.PSET_FAILED)
+
+	// route 在节点 at 上添加路径 pid 的条目
+	route := func(at, pid, ea, eb, na, nb uint32) {
+		nodes[at].RoutingTable.Add(ea, eb, na, nb, pid)
+	}
+	// 100: 完整的路径 1-2-3
+	route(1, 100, 1, 3, 0, 2)
+	route(2, 100, 1, 3, 1, 3)
+	route(3, 100, 1, 3, 2, 0)
+	// 200: 半条路径，3 上没有条目
+	route(1, 200, 1, 3, 0, 2)
+	route(2, 200, 1, 3, 1, 3)
+	// 300: 3 把朝向 4 的下一跳指回 2，形成环
+	route(1, 300, 1, 4, 0, 2)
+	route(2, 300, 1, 4, 1, 3)
+	route(3, 300, 1, 4, 2, 2)
+	// 400: 经过已失败的链路 3-4
+	route(3, 400, 3, 4, 0, 4)
+	route(4, 400, 3, 4, 3, 0)
+	// 500: 同一个 <pid, ea> 被 1-2 和 1-3 两条路径使用
+	route(1, 500, 1, 2, 0, 2)
+	route(2, 500, 1, 2, 1, 0)
+	route(3, 500, 1, 3, 2, 0)
+	// 600: 两个端点都已拆除，只剩中间节点的条目
+	route(2, 600, 1, 3, 1, 3)
+	// 700: 相邻两跳互不指向对方
+	route(1, 700, 1, 3, 0, 2)
+	route(2, 700, 1, 3, 3, 3)
+	route(3, 700, 1, 3, 2, 0)
+	// 800: 不同端点随机生成了相同的 PathId，<pid, ea> 不同，两条路径都是完整的
+	route(1, 800, 1, 2, 0, 2)
+	route(2, 800, 1, 2, 1, 0)
+	route(2, 800, 3, 2, 3, 0)
+	route(3, 800, 3, 2, 0, 2)
+
+	list := []*vrr.Node{nodes[1], nodes[2], nodes[3], nodes[4]}
+	diags := network.ValidateRoutes(list)
+	for _, d := range diags {
+		log.Printf("Diagnostic: %s", d)
+	}
+
+	want := []network.RouteDiagnostic{
+		{Kind: network.ROUTE_DANGLING, PathId: 200, Ea: 1, Eb: 3, Node: 2, Peer: 3},
+		{Kind: network.ROUTE_LOOP, PathId: 300, Ea: 1, Eb: 4, Node: 3, Peer: 2},
+		{Kind: network.ROUTE_UNLINKED, PathId: 400, Ea: 3, Eb: 4, Node: 3, Peer: 4},
+		{Kind: network.ROUTE_DUPLICATE, PathId: 500, Ea: 1, Eb: 2, Node: 1},
+		// 节点 2 上 <500, 1> 的条目属于 1-2，1-3 这条路径从 3 出发在 2 上断开
+		{Kind: network.ROUTE_DANGLING, PathId: 500, Ea: 1, Eb: 3, Node: 3, Peer: 2},
+		{Kind: network.ROUTE_DUPLICATE, PathId: 500, Ea: 1, Eb: 3, Node: 3},
+		{Kind: network.ROUTE_ORPHAN, PathId: 600, Ea: 1, Eb: 3, Node: 2},
+		{Kind: network.ROUTE_MISMATCH, PathId: 700, Ea: 1, Eb: 3, Node: 2, Peer: 1},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %d: %v", len(want), len(diags), diags)
+	}
+	for i, w := range want {
+		d := diags[i]
+		if d.Kind != w.Kind || d.PathId != w.PathId || d.Ea != w.Ea || d.Eb != w.Eb || d.Node != w.Node || d.Peer != w.Peer {
+			t.Errorf("diagnostic %d: expected %+v, got %+v", i, w, d)
+		}
+	}
+
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		// 虚拟时间下收敛的 3x3 网格中，环收敛时仍在途的 teardown 处理完之后没有任何问题
+		sim := convergedSim(t, network.GridTopology(3, 3, 8081), seed, 30*time.Second)
+		sim.Run(5 * time.Second)
+		if diags := sim.Network.ValidateRoutes(); len(diags) != 0 {
+			t.Errorf("converged grid has routing problems: %v", diags)
+		}
+	})
+}
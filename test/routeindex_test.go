@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"testing"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// linearNext 是端点索引之前的线性实现：遍历所有条目找出离 dest 最近的端点(跳过 exclude，
+// 距离相同时取较小ID)，再取以该端点为端点、PathId 最大(相同时 Ea 较小)的条目上朝向它的下一跳
+func linearNext(me uint32, routes []vrr.RoutingTableEntry, dest, exclude uint32) uint32 {
+	var closest uint32
+	minDistance := ^uint32(0)
+	for _, route := range routes {
+		for _, ep := range []uint32{route.Ea, route.Eb} {
+			if ep == 0 || ep == exclude {
+				continue
+			}
+			distance := ringDiff(dest, ep)
+			if closest == 0 || distance < minDistance || (distance == minDistance && ep < closest) {
+				closest, minDistance = ep, distance
+			}
+		}
+	}
+	if closest == 0 || closest == me {
+		return 0
+	}
+
+	var best *vrr.RoutingTableEntry
+	for i := range routes {
+		route := &routes[i]
+		if route.Ea != closest && route.Eb != closest {
+			continue
+		}
+		if best == nil || route.PathId > best.PathId || (route.PathId == best.PathId && route.Ea < best.Ea) {
+			best = route
+		}
+	}
+	if best.Ea == closest {
+		return best.Na
+	}
+	return best.Nb
+}
+
+// ringDiff 返回两个ID在环上的距离
+func ringDiff(a, b uint32) uint32 {
+	if a-b < b-a {
+		return a - b
+	}
+	return b - a
+}
+
+// randomID 生成随机ID，一部分集中在 0 和 2^32-1 附近以覆盖回绕
+func randomID(rng *rand.Rand, space uint32) uint32 {
+	switch rng.Intn(4) {
+	case 0:
+		return 1 + uint32(rng.Intn(int(space)))
+	case 1:
+		return ^uint32(0) - uint32(rng.Intn(int(space)))
+	}
+	return 1 + rng.Uint32()%(^uint32(0)-1)
+}
+
+// fillRoutes 向节点的路由表随机添加 n 条路由，一半以本节点为端点，端点取自有限的ID集合以产生共享端点和相同距离
+func fillRoutes(rng *rand.Rand, node *vrr.Node, n int, ids []uint32) {
+	for added := 0; added < n; {
+		ea, eb := ids[rng.Intn(len(ids))], ids[rng.Intn(len(ids))]
+		if rng.Intn(2) == 0 {
+			ea = node.ID
+		}
+		if ea == eb {
+			continue
+		}
+		na, nb := uint32(rng.Intn(8)+1), uint32(rng.Intn(8)+1)
+		// PathId 取值范围较小，使同一端点上出现 PathId 相同的条目
+		if node.RoutingTable.Add(ea, eb, na, nb, uint32(rng.Intn(4*n)+1)) {
+			added++
+		}
+	}
+}
+
+// 测试端点索引：随机添加和移除路由后，GetNext/GetNextExclude 的结果与线性实现一致
+func TestRouteIndex(t *testing.T) {
+	log.Println("--- Running Test: RouteIndex ---")
+
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 50; round++ {
+		node := vrr.NewNode(randomID(rng, 64), nil)
+		ids := make([]uint32, 40)
+		for i := range ids {
+			ids[i] = randomID(rng, 64)
+		}
+		fillRoutes(rng, node, 100, ids)
+
+		// 移除一部分路由，检查端点从索引中删除和最佳路由的重新计算
+		for i, route := range node.RoutingTable.Routes() {
+			if i%3 == 0 {
+				node.RoutingTable.RemoveRoute(route.PathId, route.Ea)
+			}
+		}
+
+		routes := node.RoutingTable.Routes()
+		for q := 0; q < 200; q++ {
+			dest := randomID(rng, 64)
+			if q%4 == 0 {
+				dest = ids[rng.Intn(len(ids))]
+			}
+			exclude := ids[rng.Intn(len(ids))]
+
+			if got, want := node.RoutingTable.GetNext(dest), linearNext(node.ID, routes, dest, 0); got != want {
+				t.Fatalf("round %d: GetNext(%d) = %d, linear lookup gives %d\n%s", round, dest, got, want, node.RoutingTable)
+			}
+			if got, want := node.RoutingTable.GetNextExclude(dest, exclude), linearNext(node.ID, routes, dest, exclude); got != want {
+				t.Fatalf("round %d: GetNextExclude(%d, %d) = %d, linear lookup gives %d\n%s", round, dest, exclude, got, want, node.RoutingTable)
+			}
+		}
+	}
+
+	// 全部移除后没有任何下一跳
+	node := vrr.NewNode(10, nil)
+	node.RoutingTable.Add(10, 20, 0, 15, 1)
+	node.RoutingTable.RemoveRoute(1, 10)
+	if next := node.RoutingTable.GetNext(20); next != 0 {
+		t.Errorf("GetNext on an empty table = %d, expected 0", next)
+	}
+}
+
+// benchRoutes 构造一个有 n 条路由的节点以及一组查询目标
+func benchRoutes(n int) (*vrr.Node, []vrr.RoutingTableEntry, []uint32) {
+	rng := rand.New(rand.NewSource(1))
+	node := vrr.NewNode(randomID(rng, 1<<16), nil)
+	ids := make([]uint32, n)
+	for i := range ids {
+		ids[i] = randomID(rng, 1<<16)
+	}
+	fillRoutes(rng, node, n, ids)
+	dests := make([]uint32, 1024)
+	for i := range dests {
+		dests[i] = randomID(rng, 1<<16)
+	}
+	return node, node.RoutingTable.Routes(), dests
+}
+
+// 基于端点索引的下一跳查找
+func BenchmarkGetNextIndexed(b *testing.B) {
+	for _, n := range []int{16, 256, 4096} {
+		b.Run(fmt.Sprintf("routes=%d", n), func(b *testing.B) {
+			node, _, dests := benchRoutes(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				node.RoutingTable.GetNextExclude(dests[i%len(dests)], dests[(i+1)%len(dests)])
+			}
+		})
+	}
+}
+
+// 遍历所有条目的线性下一跳查找，作为对照
+func BenchmarkGetNextLinear(b *testing.B) {
+	for _, n := range []int{16, 256, 4096} {
+		b.Run(fmt.Sprintf("routes=%d", n), func(b *testing.B) {
+			node, routes, dests := benchRoutes(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				linearNext(node.ID, routes, dests[i%len(dests)], dests[(i+1)%len(dests)])
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+)
+
+// 测试故障注入场景：节点崩溃与重启、链路切断与恢复、子网离开与加入、丢包突增，
+// 每个阶段结束时检查虚拟环是否重新收敛，重启后和全部事件恢复后路由表中没有遗留的半条路径，并检查时间线
+func TestScenario(t *testing.T) {
+	log.Println("--- Running Test: Scenario ---")
+
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		// 3x3 网格，中心节点为 8085
+		sim := buildSim(t, network.GridTopology(3, 3, 8081), seed)
+		corner := sim.Network.GetSubnets(8089)[0]
+
+		sc, err := network.ParseScenario([]byte(fmt.Sprintf(`{"events": [
+			{"at_ms": 10000, "kind": "crash",        "node": 8085},
+			{"at_ms": 30000, "kind": "restart",      "node": 8085},
+			{"at_ms": 50000, "kind": "link-cut",     "node": 8081, "peer": 8082},
+			{"at_ms": 55000, "kind": "loss-spike",   "loss": 0.3, "duration_ms": 5000},
+			{"at_ms": 60000, "kind": "subnet-leave", "node": 8089, "subnets": [%d]},
+			{"at_ms": 70000, "kind": "subnet-join",  "node": 8089, "subnets": [%d]},
+			{"at_ms": 75000, "kind": "link-heal",    "node": 8081, "peer": 8082}
+		]}`, corner, corner)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		timeline, err := sim.Schedule(sc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sim.Start()
+
+		// --- 崩溃后：其余节点重新形成不含 8085 的环（8085 已从网络中注销，不参与检查） ---
+		sim.Run(28 * time.Second)
+		timeline.Record("check", "ring without 8085")
+		printAllVsets(sim.Nodes)
+		assertRing(t, sim.Network)
+
+		// --- 重启后：8085 以空状态重新加入 ---
+		sim.Run(20 * time.Second)
+		timeline.Record("check", "ring with restarted 8085")
+		printAllVsets(sim.Nodes)
+		assertRing(t, sim.Network)
+		assertRoutes(t, sim.Network)
+
+		// --- 链路、子网和丢包事件全部恢复后 ---
+		sim.Run(52 * time.Second)
+		timeline.Record("check", "ring after link and subnet events")
+		printAllVsets(sim.Nodes)
+		assertRing(t, sim.Network)
+		assertRoutes(t, sim.Network)
+		if p := sim.Network.GetLinkProfile(8081, 8082); p.PacketLoss != 0 {
+			t.Errorf("link 8081->8082 not healed: %+v", p)
+		}
+
+		log.Printf("Timeline:\n%s", timeline)
+		want := []struct {
+			at   time.Duration
+			kind string
+		}{
+			{10 * time.Second, network.EVENT_CRASH},
+			{28 * time.Second, "check"},
+			{30 * time.Second, network.EVENT_RESTART},
+			{48 * time.Second, "check"},
+			{50 * time.Second, network.EVENT_LINK_CUT},
+			{55 * time.Second, network.EVENT_LOSS_SPIKE},
+			// 同一时刻先执行场景中的事件，再执行丢包恢复（调度得更晚）
+			{60 * time.Second, network.EVENT_SUBNET_LEAVE},
+			{60 * time.Second, network.EVENT_LOSS_SPIKE},
+			{70 * time.Second, network.EVENT_SUBNET_JOIN},
+			{75 * time.Second, network.EVENT_LINK_HEAL},
+			{100 * time.Second, "check"},
+		}
+		entries := timeline.Entries()
+		if len(entries) != len(want) {
+			t.Fatalf("expected %d timeline entries, got %d", len(want), len(entries))
+		}
+		for i, w := range want {
+			if entries[i].At != w.at || entries[i].Kind != w.kind {
+				t.Errorf("timeline entry %d: expected %s at %v, got %s at %v", i, w.kind, w.at, entries[i].Kind, entries[i].At)
+			}
+		}
+	})
+}
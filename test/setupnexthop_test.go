@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"testing"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试 setup 的下一跳选择：不经由失败的链路转发，到代理不可达时不建立没有下一跳的路径
+func TestSetupNextHop(t *testing.T) {
+	log.Println("--- Running Test: SetupNextHop ---")
+	node, capture, _ := newCaptureNode(8082)
+	node.PsetManager.Add(8081, vrr.PSET_LINKED, true)
+	node.PsetManager.Add(8084, vrr.PSET_LINKED, true)
+	node.PsetManager.Add(8083, vrr.PSET_FAILED, true)
+
+	// 转发：dst 8083 的链路已失败，setup 应经由代理 8084 转发
+	node.Receive(vrr.Message{
+		Type: vrr.VRR_SETUP, Src: 8081, Dst: 8083, Sender: 8081, NextHop: 8082,
+		Payload: &vrr.SetupPayload{Pid: 1, Proxy: 8084},
+	})
+	// 发起：同样不直接发给失败的 8083
+	node.LocalRcvSetup(8083, 2, 8084, nil)
+
+	for _, msg := range capture.sent(vrr.VRR_SETUP) {
+		if msg.NextHop != 8084 {
+			t.Errorf("setup %d->%d was sent to %d instead of proxy 8084", msg.Src, msg.Dst, msg.NextHop)
+		}
+	}
+	if setups := len(capture.sent(vrr.VRR_SETUP)); setups != 2 {
+		t.Errorf("expected 2 setups via proxy 8084, got %d", setups)
+	}
+
+	// 路由表为空，到不是邻居的代理 8086 不可达：不建立路径，并撤销 vset 中对 8085 的添加
+	lone, loneCapture, _ := newCaptureNode(8082)
+	lone.VsetManager.Add(8085)
+	lone.LocalRcvSetup(8085, 3, 8086, nil)
+
+	if setups := loneCapture.sent(vrr.VRR_SETUP); len(setups) != 0 {
+		t.Errorf("setup to 8085 was sent without a next hop: %+v", setups)
+	}
+	if lone.RoutingTable.HasPathTo(8085) {
+		t.Errorf("path to 8085 without a next hop was added:\n%s", lone.RoutingTable.String())
+	}
+	if lone.VsetManager.Contains(8085) {
+		t.Errorf("8085 stayed in the vset without a vset-path: %v", lone.VsetManager.GetAll())
+	}
+}
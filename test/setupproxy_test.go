@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// captureNetwork 只记录节点发出的消息而不投递，用于单独检查一个节点的协议处理结果
+type captureNetwork struct {
+	lock sync.Mutex
+	msgs []vrr.Message
+}
+
+func (c *captureNetwork) Send(msg vrr.Message) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.msgs = append(c.msgs, msg)
+}
+
+// sent 返回已发出的指定类型的消息
+func (c *captureNetwork) sent(msgType uint8) []vrr.Message {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var out []vrr.Message
+	for _, msg := range c.msgs {
+		if msg.Type == msgType {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// waitSent 轮询直到节点发出指定类型的消息，超时返回 nil
+func (c *captureNetwork) waitSent(msgType uint8, timeout time.Duration) []vrr.Message {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if msgs := c.sent(msgType); len(msgs) > 0 {
+			return msgs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+// 测试刚加入的节点在路由表为空时，也能把 setup 直接发给已链接的代理邻居
+func TestSetupViaLinkedProxy(t *testing.T) {
+	log.Println("--- Running Test: SetupViaLinkedProxy ---")
+	capture := &captureNetwork{}
+	node := vrr.NewNode(8081, capture)
+	node.PsetManager.Add(8082, vrr.PSET_LINKED, true)
+
+	// 目标 8083 不是物理邻居，路由表中也没有到代理 8082 的路径
+	node.LocalRcvSetup(8083, 1, 8082, nil)
+
+	setups := capture.sent(vrr.VRR_SETUP)
+	if len(setups) != 1 || setups[0].NextHop != 8082 {
+		t.Fatalf("expected one setup to proxy 8082, got %+v", setups)
+	}
+}
+
+// 测试 setup_fail 的处理顺序：目的地是自己时先在本地处理，目的地是已链接但尚未活跃的邻居时直接转发
+func TestSetupFailDelivery(t *testing.T) {
+	log.Println("--- Running Test: SetupFailDelivery ---")
+	capture := &captureNetwork{}
+	node := vrr.NewNode(8081, capture)
+	node.PsetManager.Add(8082, vrr.PSET_LINKED, true)
+	node.PsetManager.Add(8084, vrr.PSET_LINKED, false)
+	// 经由 8082 到代理 8083 的路径，旧的实现会沿它把发给自己的 setup_fail 转发出去
+	node.RoutingTable.Add(8081, 8083, 0, 8082, 1)
+
+	node.Start()
+	defer node.Stop()
+
+	node.InboxChan <- vrr.Message{
+		Type: vrr.VRR_SETUP_FAIL, Src: 8085, Dst: 8081, Sender: 8082, NextHop: 8081,
+		Payload: &vrr.SetupFailPayload{Proxy: 8083},
+	}
+	node.InboxChan <- vrr.Message{
+		Type: vrr.VRR_SETUP_FAIL, Src: 8085, Dst: 8084, Sender: 8082, NextHop: 8081,
+		Payload: &vrr.SetupFailPayload{Proxy: 8083},
+	}
+
+	fails := capture.waitSent(vrr.VRR_SETUP_FAIL, time.Second)
+	if len(fails) != 1 || fails[0].Dst != 8084 || fails[0].NextHop != 8084 {
+		t.Fatalf("expected only the setup_fail for 8084 to be forwarded to it, got %+v", fails)
+	}
+	// 本地处理 setup_fail 后，节点会向 src 发起 setup_req 把它加入虚拟邻居集
+	var reqTo []uint32
+	for _, msg := range capture.sent(vrr.VRR_SETUP_REQ) {
+		reqTo = append(reqTo, msg.Dst)
+	}
+	if !vrr.ContainsID(reqTo, 8085) {
+		t.Errorf("setup_fail addressed to Node 8081 was not processed: setup_req sent to %v", reqTo)
+	}
+}
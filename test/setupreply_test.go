@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log"
+	"testing"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试对 setup_req 的 setup 回复携带加入请求者之后的 vset
+func TestSetupReplyVset(t *testing.T) {
+	log.Println("--- Running Test: SetupReplyVset ---")
+	node, capture, _ := newCaptureNode(8081)
+	node.PsetManager.Add(8082, vrr.PSET_LINKED, true)
+	node.SetActive(true)
+
+	node.Receive(vrr.Message{
+		Type: vrr.VRR_SETUP_REQ, Src: 8083, Dst: 8081, Sender: 8082, NextHop: 8081,
+		Payload: &vrr.SetupReqPayload{Proxy: 8082},
+	})
+
+	setups := capture.sent(vrr.VRR_SETUP)
+	if len(setups) != 1 {
+		t.Fatalf("expected one setup in reply to the setup_req, got %+v", setups)
+	}
+	vset_ := setups[0].Payload.(*vrr.SetupPayload).Vset_
+	if !vrr.ContainsID(vset_, 8083) {
+		t.Errorf("setup reply carries vset %v from before 8083 was added", vset_)
+	}
+}
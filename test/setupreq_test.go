@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试同一目标的 setup_req 限速：短时间内重复触发只发送一次，之后由周期任务按间隔重发，
+// 发送 VRR_SETUP_TRIES 次之后重发间隔逐次加倍，加倍 VRR_SETUP_BACKOFF 次后放弃
+func TestSetupReqRateLimit(t *testing.T) {
+	log.Println("--- Running Test: SetupReqRateLimit ---")
+	capture := &captureNetwork{}
+	clock := vrr.NewVirtualClock(time.Unix(0, 0))
+	// 固定 100ms 的 HELLO 周期，重发时刻与 SetupRetry 的倍数对齐；不检测邻居失败，代理一直可用
+	node, err := vrr.NewNodeWithConfig(8081, capture, vrr.Config{HelloInterval: 100 * time.Millisecond, HelloJitter: -1, FailTimeout: 1 << 20, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.PsetManager.Add(8082, vrr.PSET_LINKED, true)
+	node.SetActive(true)
+
+	// 模拟 setup_fail 风暴：同一个 vset' 在短时间内多次触发 Add
+	for i := 0; i < 5; i++ {
+		node.Add(node.VsetManager.GetAll(), 0, []uint32{8085})
+	}
+	if reqs := countTo(capture.sent(vrr.VRR_SETUP_REQ), 8085); reqs != 1 {
+		t.Fatalf("expected 1 setup_req to 8085 within the retry interval, got %d", reqs)
+	}
+
+	node.Start()
+	defer node.Stop()
+
+	// 发送时刻(以 SetupRetry 为单位)：0 1 2 4 8 16 32 64 128，之后放弃
+	retry := vrr.VRR_SETUP_RETRY
+	for _, c := range []struct {
+		until time.Duration
+		reqs  int
+	}{
+		{retry * 3 / 2, 2},
+		{retry * 5 / 2, vrr.VRR_SETUP_TRIES},
+		{retry * 5, 4},
+		{retry * 20, 6},
+		{retry * 200, vrr.VRR_SETUP_TRIES + vrr.VRR_SETUP_BACKOFF},
+		{retry * 1000, vrr.VRR_SETUP_TRIES + vrr.VRR_SETUP_BACKOFF},
+	} {
+		clock.RunFor(c.until - clock.Now().Sub(time.Unix(0, 0)))
+		if reqs := countTo(capture.sent(vrr.VRR_SETUP_REQ), 8085); reqs != c.reqs {
+			t.Errorf("expected %d setup_req to 8085 after %v, got %d", c.reqs, c.until, reqs)
+		}
+	}
+}
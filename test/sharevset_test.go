@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"testing"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试经由 setup 加入 vset 的新邻居会得知它 vset' 中缺少的本地 vset 成员
+func TestShareVset(t *testing.T) {
+	log.Println("--- Running Test: ShareVset ---")
+	node, capture, _ := newCaptureNode(8081)
+	node.PsetManager.Add(8082, vrr.PSET_LINKED, true)
+	node.SetActive(true)
+	node.VsetManager.Add(8084)
+
+	// 8083 发起的 setup 经 8082 到达，它的 vset' 中没有 8084
+	node.Receive(vrr.Message{
+		Type: vrr.VRR_SETUP, Src: 8083, Dst: 8081, Sender: 8082, NextHop: 8081,
+		Payload: &vrr.SetupPayload{Pid: 1, Proxy: 8082, Vset_: []uint32{8082}},
+	})
+
+	if !node.VsetManager.Contains(8083) {
+		t.Fatalf("8083 was not added to the vset: %v", node.VsetManager.GetAll())
+	}
+	shared := false
+	for _, msg := range capture.sent(vrr.VRR_VSET_SHARE) {
+		vset := msg.Payload.(*vrr.VsetSharePayload).Vset_
+		if msg.Dst == 8083 && msg.NextHop == 8082 && vrr.ContainsID(vset, 8084) {
+			shared = true
+		}
+	}
+	if !shared {
+		t.Errorf("vset member 8084 was not shared with the new neighbor 8083")
+	}
+}
+
+// 测试 vset_share 消息沿路径转发，目标向其中缺少的节点发送 setup_req
+func TestReceiveVsetShare(t *testing.T) {
+	log.Println("--- Running Test: ReceiveVsetShare ---")
+	share := vrr.Message{
+		Type: vrr.VRR_VSET_SHARE, Src: 8081, Dst: 8083, Sender: 8081, NextHop: 8082,
+		Payload: &vrr.VsetSharePayload{Vset_: []uint32{8081, 8084}},
+	}
+
+	// 中间节点 8082 直接转发给已链接的目标
+	relay, relayCapture, _ := newCaptureNode(8082)
+	relay.PsetManager.Add(8081, vrr.PSET_LINKED, true)
+	relay.PsetManager.Add(8083, vrr.PSET_LINKED, true)
+	relay.SetActive(true)
+	relay.Receive(share)
+	if sent := relayCapture.sent(vrr.VRR_VSET_SHARE); len(sent) != 1 || sent[0].NextHop != 8083 || sent[0].Sender != 8082 {
+		t.Errorf("vset_share not forwarded to 8083: %+v", sent)
+	}
+
+	// 目标 8083 向 vset 中还没有的 8084 发起 setup_req
+	node, capture, _ := newCaptureNode(8083)
+	node.PsetManager.Add(8082, vrr.PSET_LINKED, true)
+	node.SetActive(true)
+	node.VsetManager.Add(8081)
+	share.Sender, share.NextHop = 8082, 8083
+	node.Receive(share)
+	if reqs := countTo(capture.sent(vrr.VRR_SETUP_REQ), 8084); reqs != 1 {
+		t.Errorf("expected 1 setup_req to 8084, got %d", reqs)
+	}
+}
@@ -1 +1,93 @@
 package main
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试路由表以 <pid, ea> 标识路径：两条路径的 PathId 相同但 Ea 不同时可以同时存在，
+// 拆除其中一条不会影响另一条
+func TestTeardownPidCollision(t *testing.T) {
+	log.Println("--- Running Test: TeardownPidCollision ---")
+
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		sim := convergedSim(t, network.LineTopology(3, 8081), seed, 20*time.Second)
+
+		// 找一条经过 8082 的 8081-8083 路径
+		var path vrr.RoutingTableEntry
+		found := false
+		for _, e := range sim.Node(8081).RoutingTable.RoutesTo(8083) {
+			if e.Na == 8082 || e.Nb == 8082 {
+				path, found = e, true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("no path between 8081 and 8083 through 8082: %s", sim.Node(8081).RoutingTable)
+		}
+		log.Printf("Path under test: %+v", path)
+
+		// 由另一个端点建立一条 PathId 相同、方向相反的路径
+		ea, eb, pid := path.Eb, path.Ea, path.PathId
+		for _, hop := range []struct {
+			at, na, nb uint32
+		}{{ea, 0, 8082}, {8082, ea, eb}, {eb, 8082, 0}} {
+			if !sim.Node(hop.at).RoutingTable.Add(ea, eb, hop.na, hop.nb, pid) {
+				t.Fatalf("Node %d: route <%d, %d> rejected although its ea differs", hop.at, pid, ea)
+			}
+		}
+		if sim.Node(8082).RoutingTable.Add(path.Ea, path.Eb, 0, 0, pid) {
+			t.Fatalf("Node 8082: duplicate route <%d, %d> accepted", pid, path.Ea)
+		}
+
+		// 从端点拆除原来的路径
+		sim.Node(path.Ea).RoutingTable.TearDownPath(pid, path.Ea, 0)
+		sim.Run(time.Second)
+
+		for _, id := range []uint32{8081, 8082, 8083} {
+			rt := sim.Node(id).RoutingTable
+			if _, ok := rt.Lookup(pid, path.Ea); ok {
+				t.Errorf("Node %d: route <%d, %d> survived the teardown", id, pid, path.Ea)
+			}
+			if _, ok := rt.Lookup(pid, ea); !ok {
+				t.Errorf("Node %d: colliding route <%d, %d> was removed by the teardown", id, pid, ea)
+			}
+		}
+		if diags := sim.Network.ValidateRoutes(); len(diags) != 0 {
+			t.Errorf("routing problems after teardown: %v", diags)
+		}
+	})
+}
+
+// 测试本节点发起 setup 时 pid 与自己已有的路径 <pid, me> 重复：已有的路径保持不变，
+// 新路径换一个 pid 建立，不发送 teardown
+func TestLocalSetupPidCollision(t *testing.T) {
+	log.Println("--- Running Test: LocalSetupPidCollision ---")
+	node, capture, _ := newCaptureNode(8082)
+	node.PsetManager.Add(8081, vrr.PSET_LINKED, true)
+	node.PsetManager.Add(8083, vrr.PSET_LINKED, true)
+	if !node.RoutingTable.Add(8082, 8081, 0, 8081, 7) {
+		t.Fatal("couldn't add the existing route <7, 8082>")
+	}
+
+	node.LocalRcvSetup(8083, 7, 8083, nil)
+
+	if route, ok := node.RoutingTable.Lookup(7, 8082); !ok || route.Eb != 8081 || route.Nb != 8081 {
+		t.Errorf("existing route <7, 8082> was changed: %+v (found %v)", route, ok)
+	}
+	if teardowns := capture.sent(vrr.VRR_TEARDOWN); len(teardowns) != 0 {
+		t.Errorf("expected no teardown, got %+v", teardowns)
+	}
+	routes := node.RoutingTable.RoutesTo(8083)
+	if len(routes) != 1 || routes[0].PathId == 7 {
+		t.Fatalf("expected one route to 8083 with a fresh path ID, got %+v", routes)
+	}
+	setups := capture.sent(vrr.VRR_SETUP)
+	if len(setups) != 1 || setups[0].Payload.(*vrr.SetupPayload).Pid != routes[0].PathId {
+		t.Errorf("expected one setup carrying path ID %d, got %+v", routes[0].PathId, setups)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log"
+	"testing"
+
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试 vset 变化触发的 TearDownPathTo 只拆除本节点作为端点的路径，经过本节点转发的路径保持不变
+func TestTearDownPathToScope(t *testing.T) {
+	log.Println("--- Running Test: TearDownPathToScope ---")
+	node, capture, _ := newCaptureNode(8082)
+	node.PsetManager.Add(8081, vrr.PSET_LINKED, true)
+	node.PsetManager.Add(8083, vrr.PSET_LINKED, true)
+
+	// 8081 与 8083 之间经过本节点的路径，以及本节点自己到 8083 的路径
+	node.RoutingTable.Add(8081, 8083, 8081, 8083, 1)
+	node.RoutingTable.Add(8082, 8083, 0, 8083, 2)
+
+	node.RoutingTable.TearDownPathTo(8083)
+
+	if node.RoutingTable.HasPathTo(8083) {
+		t.Errorf("own path to 8083 was not torn down:\n%s", node.RoutingTable.String())
+	}
+	if !node.RoutingTable.HasNextHop(8081) {
+		t.Errorf("transit path 8081-8083 was torn down:\n%s", node.RoutingTable.String())
+	}
+	teardowns := capture.sent(vrr.VRR_TEARDOWN)
+	if len(teardowns) != 1 || teardowns[0].Payload.(*vrr.TeardownPayload).Pid != 2 {
+		t.Errorf("expected a single teardown for path 2, got %+v", teardowns)
+	}
+}
+
+// 测试 TearDownPath 把 teardown 发给已链接但尚未激活的下一跳：该邻居可能刚通过 setup 建立了路径，
+// 漏发会在它那里留下悬空的路由
+func TestTearDownPathLinkedNotActive(t *testing.T) {
+	log.Println("--- Running Test: TearDownPathLinkedNotActive ---")
+	node, capture, _ := newCaptureNode(8082)
+	node.PsetManager.Add(8081, vrr.PSET_LINKED, true)
+	node.PsetManager.Add(8083, vrr.PSET_LINKED, false)
+	node.PsetManager.Add(8084, vrr.PSET_PENDING, false)
+
+	node.RoutingTable.Add(8081, 8083, 8081, 8083, 1)
+	node.RoutingTable.Add(8081, 8084, 8081, 8084, 2)
+
+	node.RoutingTable.TearDownPath(1, 8081, 0)
+	node.RoutingTable.TearDownPath(2, 8081, 0)
+
+	sentTo := make(map[uint32]int)
+	for _, msg := range capture.sent(vrr.VRR_TEARDOWN) {
+		sentTo[msg.NextHop]++
+	}
+	if got := sentTo[8083]; got != 1 {
+		t.Errorf("expected one teardown to linked but inactive 8083, got %d", got)
+	}
+	if got := sentTo[8084]; got != 0 {
+		t.Errorf("expected no teardown to pending 8084, got %d", got)
+	}
+	if got := sentTo[8081]; got != 2 {
+		t.Errorf("expected two teardowns to 8081, got %d", got)
+	}
+}
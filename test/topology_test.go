@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+)
+
+// 测试从拓扑文件构建仿真：与 TestBootStrap 相同的拓扑，在虚拟时钟上运行
+func TestTopologyFile(t *testing.T) {
+	log.Println("--- Running Test: TopologyFile ---")
+
+	topo, err := network.LoadTopology(filepath.Join("testdata", "bootstrap.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, err := topo.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := sim.Network.GetLinkProfile(8085, 8082); p.Latency != 80*time.Millisecond || p.Bandwidth != 1000000 {
+		t.Errorf("link 8085->8082 profile not loaded: %+v", p)
+	}
+
+	sim.Start()
+	defer sim.Stop()
+	sim.Run(20 * time.Second)
+	printAllVsets(sim.Nodes)
+
+	// 8082-8085 形成一个环，孤立的 8086 单独成环
+	assertRing(t, sim.Network)
+	if n := sim.Node(8086); !n.Active || len(n.VsetManager.GetAll()) != 0 {
+		t.Errorf("isolated Node 8086 should be active with an empty vset, got active=%v vset=%v", n.Active, n.VsetManager.GetAll())
+	}
+}
+
+// 测试拓扑生成器的形状、保存/读取往返，以及网格拓扑上的收敛
+func TestTopologyGenerators(t *testing.T) {
+	log.Println("--- Running Test: TopologyGenerators ---")
+
+	// subnetCount 统计拓扑中的子网（链路）数
+	subnetCount := func(topo *network.Topology) int {
+		subnets := make(map[uint32]bool)
+		for _, n := range topo.Nodes {
+			for _, s := range n.Subnets {
+				subnets[s] = true
+			}
+		}
+		return len(subnets)
+	}
+
+	cases := []struct {
+		name    string
+		topo    *network.Topology
+		nodes   int
+		subnets int
+	}{
+		{"line", network.LineTopology(5, 1), 5, 4},
+		{"ring", network.RingTopology(5, 1), 5, 5},
+		{"grid", network.GridTopology(3, 3, 1), 9, 12},
+	}
+	for _, c := range cases {
+		if len(c.topo.Nodes) != c.nodes || subnetCount(c.topo) != c.subnets {
+			t.Errorf("%s: expected %d nodes and %d subnets, got %d and %d",
+				c.name, c.nodes, c.subnets, len(c.topo.Nodes), subnetCount(c.topo))
+		}
+		if err := c.topo.Validate(); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+
+	// 相同种子生成相同的随机几何图，并且可以保存后读回
+	rgg := network.RandomGeometricTopology(20, 0.3, 99, 100)
+	if !reflect.DeepEqual(rgg, network.RandomGeometricTopology(20, 0.3, 99, 100)) {
+		t.Error("random geometric topologies with the same seed differ")
+	}
+	path := filepath.Join(t.TempDir(), "rgg.json")
+	if err := rgg.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := network.LoadTopology(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rgg, loaded) {
+		t.Error("topology changed after Save/LoadTopology round trip")
+	}
+
+	// 校验错误
+	bad := []struct {
+		json string
+		want string
+	}{
+		{`{"nodes": [{"id": 1, "subnets": [1]}, {"id": 1, "subnets": [1]}]}`, "duplicate"},
+		{`{"nodes": [{"id": 1, "subnets": []}]}`, "not in any subnet"},
+		{`{"nodes": [{"id": 1, "subnets": [1]}], "subnets": [{"id": 1, "dist": "pareto"}]}`, "distribution"},
+		{`{"nodes": [{"id": 1, "subnets": [1]}], "links": [{"src": 1, "dst": 2}]}`, "unknown node"},
+	}
+	for _, b := range bad {
+		if _, err := network.ParseTopology([]byte(b.json)); err == nil || !strings.Contains(err.Error(), b.want) {
+			t.Errorf("ParseTopology(%s): expected error containing %q, got %v", b.json, b.want, err)
+		}
+	}
+
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		// 4x4 网格上运行虚拟时间仿真，所有节点最终形成一个虚拟环
+		sim := convergedSim(t, network.GridTopology(4, 4, 8081), seed, 60*time.Second)
+		printAllVsets(sim.Nodes)
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试消息追踪：网格拓扑中被追踪的数据包可以重建出首尾相接的逐跳路径和每跳延迟，
+// 路径伸展度不小于 1；切断第一跳后可以查到丢弃位置和原因，未追踪的数据包不产生事件
+func TestTrace(t *testing.T) {
+	log.Println("--- Running Test: Trace ---")
+
+	forEachSeed(t, func(t *testing.T, seed int64) {
+		sim := convergedSim(t, network.GridTopology(4, 4, 8081), seed, 60*time.Second)
+
+		collector := network.NewTraceCollector()
+		sim.SetTracer(collector)
+
+		// 未追踪的数据包不产生事件
+		if !sim.Node(8081).SendData(8096, []byte("plain")) {
+			t.Fatalf("SendData 8081 -> 8096 failed")
+		}
+		sim.Run(time.Second)
+		if ids := collector.IDs(); len(ids) != 0 {
+			t.Errorf("untraced data produced traces %v", ids)
+		}
+
+		pairs := [][2]uint32{{8081, 8096}, {8084, 8093}, {8086, 8091}, {8096, 8082}}
+		for _, pair := range pairs {
+			src, dst := pair[0], pair[1]
+			id, ok := sim.Node(src).SendDataTraced(dst, 0, []byte("traced"))
+			if !ok || id == 0 {
+				t.Fatalf("SendDataTraced %d -> %d failed", src, dst)
+			}
+			sim.Run(time.Second)
+
+			trace, ok := collector.Trace(id)
+			if !ok {
+				t.Fatalf("no events for trace %x", id)
+			}
+			if !trace.Delivered || trace.Dropped {
+				t.Fatalf("trace %d -> %d not delivered: %+v", src, dst, trace)
+			}
+			path := trace.Path()
+			if path[0] != src || path[len(path)-1] != dst {
+				t.Errorf("trace %d -> %d has path %v", src, dst, path)
+			}
+			for i, hop := range trace.Hops {
+				if i > 0 && hop.From != trace.Hops[i-1].To {
+					t.Errorf("trace %d -> %d: hop %d starts at %d, previous hop ended at %d", src, dst, i, hop.From, trace.Hops[i-1].To)
+				}
+				if hop.Latency != 20*time.Millisecond {
+					t.Errorf("trace %d -> %d: hop %d-%d latency %v, expected 20ms", src, dst, hop.From, hop.To, hop.Latency)
+				}
+			}
+			if want := time.Duration(len(trace.Hops)) * 20 * time.Millisecond; trace.Latency() != want {
+				t.Errorf("trace %d -> %d: end-to-end latency %v, expected %v", src, dst, trace.Latency(), want)
+			}
+
+			stretch, ok := sim.Network.Stretch(trace)
+			shortest := sim.Network.ShortestPath(src, dst)
+			if !ok || stretch < 1 {
+				t.Errorf("trace %d -> %d: stretch %v (path %v, shortest %v)", src, dst, stretch, path, shortest)
+			}
+			t.Logf("%d -> %d: path %v, shortest %v, stretch %.2f", src, dst, path, shortest, stretch)
+		}
+
+		// 网格中 8081 到 8096 的最短路径为 6 跳
+		if shortest := sim.Network.ShortestPath(8081, 8096); len(shortest) != 7 {
+			t.Errorf("shortest path 8081 -> 8096: %v", shortest)
+		}
+
+		// 切断第一跳，数据包在源节点的链路上丢失
+		src := sim.Node(8081)
+		first := src.RoutingTable.GetNext(8096)
+		sim.Network.SetLinkProfile(8081, first, network.LinkProfile{Latency: 20 * time.Millisecond, PacketLoss: 1})
+		id, _ := src.SendDataTraced(8096, 0, []byte("lost"))
+		sim.Run(time.Second)
+		trace, ok := collector.Trace(id)
+		if !ok || trace.Delivered || !trace.Dropped || trace.DropNode != 8081 || trace.DropReason != vrr.DROP_LOSS {
+			t.Errorf("lost trace: %+v", trace)
+		}
+		if _, ok := sim.Network.Stretch(trace); ok {
+			t.Errorf("stretch computed for an undelivered trace")
+		}
+
+		// 没有路由时在源节点以 no_route 丢弃
+		lonely := vrr.NewNode(1, nil)
+		lonely.SetTracer(collector)
+		id, sent := lonely.SendDataTraced(2, 0, nil)
+		if trace, ok := collector.Trace(id); sent || !ok || trace.DropNode != 1 || trace.DropReason != vrr.DROP_NO_ROUTE {
+			t.Errorf("no-route trace: %+v", trace)
+		}
+	})
+}
+
+// 测试在实时时钟下节点运行时替换追踪钩子和日志：go test -race 下不应报告数据竞争，
+// 设置钩子之后发出的数据包可以查到完整的路径
+func TestSetTracerWhileRunning(t *testing.T) {
+	log.Println("--- Running Test: SetTracerWhileRunning ---")
+	sim, err := network.LineTopology(3, 8081).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.Start()
+	defer sim.Stop()
+	waitRing(t, sim.Network, 20*time.Second)
+
+	collector := network.NewTraceCollector()
+	src := sim.Node(8081)
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			sim.SetTracer(collector)
+		} else {
+			sim.SetTracer(nil)
+		}
+		for _, n := range sim.Nodes {
+			n.SetLogger(vrr.DefaultLogger())
+		}
+		src.SendDataTraced(8083, 0, []byte("traced"))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sim.SetTracer(collector)
+	id, ok := src.SendDataTraced(8083, 0, []byte("traced"))
+	if !ok {
+		t.Fatal("SendDataTraced 8081 -> 8083 failed")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if trace, ok := collector.Trace(id); ok && trace.Delivered {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("trace %d was not delivered after setting the tracer", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试节点通过回环地址上的 UDP 传输构建虚拟网络并收发数据
+func TestUDPNetwork(t *testing.T) {
+	log.Println("--- Running Test: UDPNetwork ---")
+
+	// --- 定义拓扑 ---
+	// Subnet 1: Node 5, Node 2
+	// Subnet 2: Node 2, Node 3, Node 4
+	// Router: Node 2 (连接 Subnet 1 和 Subnet 2)
+	subnets := map[uint32][]uint32{
+		8085: {1},
+		8082: {1, 2},
+		8083: {2},
+		8084: {2},
+	}
+
+	transports := make(map[uint32]*network.UDPNetwork)
+	nodes := make(map[uint32]*vrr.Node)
+	for id, s := range subnets {
+		udp, err := network.NewUDPNetwork("127.0.0.1:0", s...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer udp.Close()
+		transports[id] = udp
+		nodes[id] = vrr.NewNode(id, udp)
+	}
+
+	// 每个传输都知道所有对端的地址
+	for id, udp := range transports {
+		for peerID, peer := range transports {
+			if peerID == id {
+				continue
+			}
+			if err := udp.AddPeer(peerID, peer.Addr().String(), subnets[peerID]...); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	nodes[8085].SetActive(true)
+	all := make([]*vrr.Node, 0, len(nodes))
+	for id, n := range nodes {
+		transports[id].Attach(n)
+		n.Start()
+		defer n.Stop()
+		all = append(all, n)
+	}
+
+	// 轮询直到所有节点都活跃且 vset 中是其他全部节点、都有 vset-path，超过期限仍未完成时报告
+	log.Println("\n--- Waiting for virtual network over UDP to complete... ---")
+	converged := func() bool {
+		for _, n := range all {
+			vset := n.VsetManager.GetAll()
+			if !n.IsActive() || len(vset) != len(all)-1 {
+				return false
+			}
+			for _, id := range vset {
+				if !n.RoutingTable.HasPathTo(id) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	deadline := time.Now().Add(20 * time.Second)
+	for !converged() && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	printAllVsets(all)
+	printAllRoutes(all)
+
+	for _, n := range all {
+		if !n.IsActive() {
+			t.Errorf("Node %d is not active", n.ID)
+		}
+	}
+	if !converged() {
+		t.Fatal("virtual network over UDP did not converge within 20s")
+	}
+
+	delivered := make(chan vrr.Delivery, 1)
+	nodes[8083].Handle(9, func(d vrr.Delivery) {
+		delivered <- d
+	})
+	if !nodes[8085].SendDataPort(8083, 9, []byte("over udp")) {
+		t.Fatal("Node 8085: no route to Node 8083")
+	}
+	select {
+	case d := <-delivered:
+		if d.Src != 8085 || string(d.Data) != "over udp" {
+			t.Fatalf("unexpected delivery: %+v", d)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("data over UDP was not delivered")
+	}
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
 
+	"github.com/tangwan16/vrr-go/network"
 	"github.com/tangwan16/vrr-go/vrr"
 )
 
@@ -45,3 +51,81 @@ func printAllVsets(nodes []*vrr.Node) {
 	}
 	log.Println("--------------------")
 }
+
+// assertRing 检查网络中的虚拟环与理想状态一致，不一致时报告所有问题
+func assertRing(t *testing.T, nw *network.Network) {
+	t.Helper()
+	if report := nw.CheckRing(); !report.OK() {
+		t.Errorf("virtual ring is inconsistent: %s", report)
+	}
+}
+
+// assertRoutes 检查网络中各节点的路由表没有半条路径等问题，有问题时报告所有诊断
+func assertRoutes(t *testing.T, nw *network.Network) {
+	t.Helper()
+	if diags := nw.ValidateRoutes(); len(diags) != 0 {
+		t.Errorf("routing tables have problems: %v", diags)
+	}
+}
+
+// waitRing 等待网络中的虚拟环收敛，超过 timeout 仍未收敛时报告不一致之处
+func waitRing(t *testing.T, nw *network.Network, timeout time.Duration) {
+	t.Helper()
+	elapsed, report := nw.WaitConverged(timeout, 100*time.Millisecond)
+	if !report.OK() {
+		t.Errorf("virtual ring did not converge within %v: %s", elapsed, report)
+		return
+	}
+	log.Printf("Virtual ring converged after %v: %s", elapsed, report)
+}
+
+// testSeeds 是对收敛敏感的仿真测试依次使用的随机种子
+var testSeeds = []int64{1, 2, 3, 4, 5}
+
+// forEachSeed 以 testSeeds 中的每个种子运行一个子测试
+func forEachSeed(t *testing.T, f func(t *testing.T, seed int64)) {
+	t.Helper()
+	for _, seed := range testSeeds {
+		t.Run(fmt.Sprintf("seed=%d", seed), func(t *testing.T) {
+			f(t, seed)
+		})
+	}
+}
+
+// buildSim 以 seed 在虚拟时钟上构建拓扑，链路延迟 20ms，测试结束时停止仿真。
+// 需要在启动前设置日志、抓包或故障场景时使用，之后由调用者 Start
+func buildSim(t *testing.T, topo *network.Topology, seed int64) *network.Sim {
+	t.Helper()
+	topo.Latency = 20
+	topo.Virtual = true
+	topo.Seed = seed
+	sim, err := topo.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sim.Stop)
+	return sim
+}
+
+// convergedSim 以 seed 构建并启动拓扑，等待虚拟环在 timeout 内收敛
+func convergedSim(t *testing.T, topo *network.Topology, seed int64, timeout time.Duration) *network.Sim {
+	t.Helper()
+	sim := buildSim(t, topo, seed)
+	sim.Start()
+	waitRing(t, sim.Network, timeout)
+	return sim
+}
+
+// buildCommand 把 cmd/name 编译到 dir 中并返回可执行文件路径，没有 go 命令时跳过测试
+func buildCommand(t *testing.T, dir, name string) string {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not available")
+	}
+	bin := filepath.Join(dir, name)
+	if out, err := exec.Command(goBin, "build", "-o", bin, "../cmd/"+name).CombinedOutput(); err != nil {
+		t.Fatalf("build %s: %v\n%s", name, err, out)
+	}
+	return bin
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tangwan16/vrr-go/network"
+	"github.com/tangwan16/vrr-go/vrr"
+)
+
+// 测试虚拟时钟按 (触发时间, 调度顺序) 执行事件，且可以取消
+func TestVirtualClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := vrr.NewVirtualClock(start)
+
+	var order []string
+	clock.AfterFunc(2*time.Second, func() { order = append(order, "b") })
+	clock.AfterFunc(time.Second, func() {
+		order = append(order, "a")
+		// 事件中调度的同一时刻事件排在已有事件之后
+		clock.AfterFunc(time.Second, func() { order = append(order, "c") })
+	})
+	cancelled := clock.AfterFunc(1500*time.Millisecond, func() { order = append(order, "x") })
+	if !cancelled.Stop() {
+		t.Error("Stop on a pending event returned false")
+	}
+
+	clock.RunFor(time.Second)
+	if got := strings.Join(order, ""); got != "a" {
+		t.Errorf("after 1s expected events \"a\", got %q", got)
+	}
+	clock.RunFor(5 * time.Second)
+	if got := strings.Join(order, ""); got != "abc" {
+		t.Errorf("expected events \"abc\", got %q", got)
+	}
+	if now := clock.Now(); !now.Equal(start.Add(6 * time.Second)) {
+		t.Errorf("expected virtual time %v, got %v", start.Add(6*time.Second), now)
+	}
+	if cancelled.Stop() {
+		t.Error("Stop on a cancelled event returned true")
+	}
+}
+
+// runVirtualRing 在虚拟时间下运行一次带丢包和抖动的仿真，返回所有节点的 vset 和路由表快照
+func runVirtualRing(t *testing.T, seed int64) (string, []*vrr.Node) {
+	clock := vrr.NewVirtualClock(time.Unix(0, 0))
+	net := network.NewNetwork(0, 0)
+	net.SetClock(clock)
+	net.SetSeed(seed)
+	net.SetSubnetProfile(1, network.LinkProfile{Dist: network.LATENCY_UNIFORM, Latency: 50 * time.Millisecond, Jitter: 20 * time.Millisecond, PacketLoss: 0.05})
+	net.SetSubnetProfile(2, network.LinkProfile{Dist: network.LATENCY_NORMAL, Latency: 30 * time.Millisecond, Jitter: 10 * time.Millisecond, PacketLoss: 0.05})
+
+	// Subnet 1: Node 1, Node 5, Node 7, Node 2
+	// Subnet 2: Node 2, Node 3, Node 4, Node 6
+	// Router: Node 2
+	subnets := map[uint32][]uint32{
+		8081: {1}, 8085: {1}, 8087: {1},
+		8082: {1, 2},
+		8083: {2}, 8084: {2}, 8086: {2},
+	}
+	ids := []uint32{8081, 8082, 8083, 8084, 8085, 8086, 8087}
+	nodes := make([]*vrr.Node, 0, len(ids))
+	for _, id := range ids {
+		n := vrr.NewNode(id, net)
+		n.SetClock(clock)
+		n.SetSeed(seed + int64(id))
+		net.RegisterNode(n, subnets[id]...)
+		nodes = append(nodes, n)
+	}
+	nodes[0].SetActive(true)
+	for _, n := range nodes {
+		n.Start()
+		t.Cleanup(n.Stop)
+	}
+
+	clock.RunFor(30 * time.Second)
+
+	var b strings.Builder
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "Node %d -> %s\n%s\n", n.ID, n.VsetManager.String(), n.RoutingTable.String())
+	}
+	total, dropped := net.GetMsgInfo()
+	fmt.Fprintf(&b, "messages: %d, dropped: %d\n", total, dropped)
+	return b.String(), nodes
+}
+
+// 测试相同种子下虚拟时间仿真的结果完全一致，且运行速度远快于真实时间
+func TestVirtualDeterminism(t *testing.T) {
+	log.Println("--- Running Test: VirtualDeterminism ---")
+
+	begin := time.Now()
+	first, nodes := runVirtualRing(t, 42)
+	second, _ := runVirtualRing(t, 42)
+	elapsed := time.Since(begin)
+
+	printAllVsets(nodes)
+	printAllRoutes(nodes)
+
+	if first != second {
+		t.Fatalf("runs with the same seed diverged:\n--- first ---\n%s--- second ---\n%s", first, second)
+	}
+	if elapsed > 10*time.Second {
+		t.Errorf("two 30s virtual runs took %v of wall time", elapsed)
+	}
+
+	ids := []uint32{8081, 8082, 8083, 8084, 8085, 8086, 8087}
+	for _, n := range nodes {
+		if !n.Active {
+			t.Errorf("Node %d is not active", n.ID)
+			continue
+		}
+		vset := n.VsetManager.GetAll()
+		for _, want := range network.IdealVset(ids, n.ID, vrr.VRR_VSET_SIZE) {
+			if !vrr.ContainsID(vset, want) {
+				t.Errorf("Node %d: vset %v is missing %d", n.ID, vset, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"log"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// 测试 cmd/vrrsh：从管道读取命令，在虚拟时钟上推进仿真，发送并追踪数据，
+// kill 节点后虚拟环重新收敛，join 创建新节点，cut/heal 切断和恢复链路，leave 主动离开，错误命令不会中断控制台
+func TestVrrsh(t *testing.T) {
+	log.Println("--- Running Test: Vrrsh ---")
+
+	bin := buildCommand(t, t.TempDir(), "vrrsh")
+	shell := func(script string) string {
+		t.Helper()
+		cmd := exec.Command(bin, "-gen", "ring:8", "-virtual", "-seed", "1", "-prompt", "")
+		cmd.Stdin = strings.NewReader(script)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("vrrsh: %v\n%s", err, out)
+		}
+		return string(out)
+	}
+
+	out := shell(`
+# 收敛后发送一条追踪数据
+step 10
+ring
+trace on
+send 8085 8083 hello world
+step 1
+vset 8082
+`)
+	for _, want := range []string{
+		"t=10s",
+		"rings [[8081 8082 8083 8084 8085 8086 8087 8088]]: consistent",
+		"8083 received from 8085 (2 hops): hello world",
+		"VSet: [8081 8083 8084 8088]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	m := regexp.MustCompile(`trace ([0-9a-f]{16})`).FindStringSubmatch(out)
+	if m == nil {
+		t.Fatalf("no trace id in output:\n%s", out)
+	}
+
+	out = shell(`
+step 10
+trace on
+send 8085 8083 hello
+step 1
+trace ` + m[1] + `
+kill 8082
+step 8
+ring
+join 9000 1 2
+step 15
+nodes
+cut 8083 8084
+step 6
+pset 8083
+heal 8083 8084
+step 10
+ring
+send 8081 8082 lost
+leave 8085
+step 2
+ring
+bogus
+vset 1
+time
+quit
+step 1
+`)
+	for _, want := range []string{
+		"delivered in 2 hops",
+		"path [8085 8084 8083]",
+		"node 8082 killed (subnets [1 2])",
+		"rings [[8081 8083 8084 8085 8086 8087 8088]]: consistent",
+		"node 9000 created in subnets [1 2]",
+		"Neighbor 8084: failed",
+		"rings [[8081 8083 8084 8085 8086 8087 8088 9000]]: consistent",
+		"node 8085 left the network",
+		"rings [[8081 8083 8084 8086 8087 8088 9000]]: consistent",
+		`error: unknown command "bogus"`,
+		"error: unknown node 1",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	if !regexp.MustCompile(`(?m)^8082\s+killed`).MatchString(out) || !regexp.MustCompile(`(?m)^9000\s+running\s+true`).MatchString(out) {
+		t.Errorf("nodes table does not show 8082 killed and 9000 running:\n%s", out)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(out), "52s") {
+		t.Errorf("commands after quit were executed:\n%s", out)
+	}
+}
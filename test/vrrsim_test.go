@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 测试 cmd/vrrsim：收敛时退出状态为 0 并输出 JSON 结果(包括注入流量和场景时间线)，
+// 运行时间不足以收敛时为 1，参数错误时为 2
+func TestVrrsim(t *testing.T) {
+	log.Println("--- Running Test: Vrrsim ---")
+
+	dir := t.TempDir()
+	bin := buildCommand(t, dir, "vrrsim")
+
+	run := func(args ...string) (string, int) {
+		t.Helper()
+		out, err := exec.Command(bin, args...).Output()
+		var exit *exec.ExitError
+		switch {
+		case errors.As(err, &exit):
+			return string(out), exit.ExitCode()
+		case err != nil:
+			t.Fatal(err)
+		}
+		return string(out), 0
+	}
+
+	scenario := filepath.Join(dir, "scenario.json")
+	if err := os.WriteFile(scenario, []byte(`{"events": [
+		{"at_ms": 15000, "kind": "link-cut", "node": 8081, "peer": 8082},
+		{"at_ms": 20000, "kind": "link-heal", "node": 8081, "peer": 8082}
+	]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, code := run("-gen", "ring:6", "-virtual", "-seed", "1", "-duration", "40s",
+		"-traffic", "5", "-scenario", scenario, "-format", "json")
+	if code != 0 {
+		t.Fatalf("exit status %d, expected 0:\n%s", code, out)
+	}
+	var result struct {
+		Converged bool `json:"converged"`
+		Traffic   struct {
+			Sent      int `json:"sent"`
+			Delivered int `json:"delivered"`
+		} `json:"traffic"`
+		Timeline []struct {
+			Kind string `json:"kind"`
+		} `json:"timeline"`
+		Snapshot struct {
+			Nodes []struct {
+				ID   uint32   `json:"id"`
+				Vset []uint32 `json:"vset"`
+			} `json:"nodes"`
+			Stats struct {
+				Total uint64 `json:"total"`
+			} `json:"stats"`
+		} `json:"snapshot"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if !result.Converged || len(result.Snapshot.Nodes) != 6 || result.Snapshot.Stats.Total == 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.Traffic.Sent == 0 || result.Traffic.Delivered == 0 {
+		t.Errorf("traffic %+v", result.Traffic)
+	}
+	if len(result.Timeline) != 2 || result.Timeline[0].Kind != "link-cut" || result.Timeline[1].Kind != "link-heal" {
+		t.Errorf("timeline %+v", result.Timeline)
+	}
+
+	out, code = run("-gen", "line:4", "-virtual", "-seed", "1", "-duration", "40s")
+	if code != 0 || !strings.Contains(out, "ring: consistent") || !strings.Contains(out, "Node 8084") {
+		t.Errorf("exit status %d, text output:\n%s", code, out)
+	}
+	if _, code = run("-gen", "ring:6", "-virtual", "-seed", "1", "-duration", "200ms"); code != 1 {
+		t.Errorf("exit status %d before convergence, expected 1", code)
+	}
+	if _, code = run("-gen", "ring"); code != 2 {
+		t.Errorf("exit status %d for invalid topology, expected 2", code)
+	}
+}
@@ -0,0 +1,168 @@
+-- Wireshark 解析脚本：解析 network.PcapWriter 导出的 VRR 抓包文件(LINKTYPE_USER0)。
+-- 使用方法：wireshark -X lua_script:tools/wireshark/vrr.lua capture.pcapng
+-- 或复制到 Wireshark 的个人插件目录。格式见 network/pcap.go 和 vrr/vrr_codec.go。
+
+local vrr = Proto("vrr", "Virtual Ring Routing")
+
+local events = { [0] = "send", [1] = "deliver", [2] = "drop" }
+local reasons = {
+    [0] = "", [1] = "loss", [2] = "inbox_full", [3] = "unknown_target",
+    [4] = "send_error", [5] = "no_route", [6] = "invalid", [7] = "recv_full",
+}
+local types = {
+    [1] = "HELLO", [2] = "SETUP_REQ", [3] = "SETUP",
+    [4] = "SETUP_FAIL", [5] = "TEARDOWN", [6] = "DATA",
+    [7] = "PATH_SYNC", [8] = "VSET_SHARE",
+}
+
+local f = {
+    -- 抓包头
+    cap_version = ProtoField.uint8("vrr.capture.version", "Capture version"),
+    cap_event   = ProtoField.uint8("vrr.capture.event", "Event", base.DEC, events),
+    cap_reason  = ProtoField.uint8("vrr.capture.reason", "Drop reason", base.DEC, reasons),
+    cap_subnet  = ProtoField.uint32("vrr.capture.subnet", "Subnet"),
+    -- VRR 报头
+    version  = ProtoField.uint8("vrr.version", "Version"),
+    type     = ProtoField.uint8("vrr.type", "Type", base.DEC, types),
+    length   = ProtoField.uint16("vrr.length", "Payload length"),
+    src      = ProtoField.uint32("vrr.src", "Src"),
+    dst      = ProtoField.uint32("vrr.dst", "Dst"),
+    next_hop = ProtoField.uint32("vrr.next_hop", "Next hop"),
+    sender   = ProtoField.uint32("vrr.sender", "Sender"),
+    trace_id = ProtoField.uint64("vrr.trace_id", "Trace ID", base.HEX),
+    -- Payload
+    active         = ProtoField.bool("vrr.hello.active", "Sender active", 8, nil, 0x1),
+    ring_id        = ProtoField.uint32("vrr.ring_id", "Ring ID"),
+    candidate      = ProtoField.uint32("vrr.hello.candidate", "Candidate"),
+    candidate_hops = ProtoField.uint8("vrr.hello.candidate_hops", "Candidate hops"),
+    candidate_seq  = ProtoField.uint32("vrr.hello.candidate_seq", "Candidate sequence"),
+    proxy          = ProtoField.uint32("vrr.proxy", "Proxy"),
+    pid            = ProtoField.uint32("vrr.pid", "Path ID"),
+    endpoint       = ProtoField.uint32("vrr.endpoint", "Endpoint"),
+    port           = ProtoField.uint16("vrr.data.port", "Port"),
+    hops           = ProtoField.uint8("vrr.data.hops", "Hops"),
+    data_len       = ProtoField.uint16("vrr.data.length", "Data length"),
+    data           = ProtoField.bytes("vrr.data.data", "Data"),
+    list_count     = ProtoField.uint16("vrr.list.count", "Count"),
+    id             = ProtoField.uint32("vrr.id", "Node ID"),
+}
+vrr.fields = {
+    f.cap_version, f.cap_event, f.cap_reason, f.cap_subnet,
+    f.version, f.type, f.length, f.src, f.dst, f.next_hop, f.sender, f.trace_id,
+    f.active, f.ring_id, f.candidate, f.candidate_hops, f.candidate_seq, f.proxy, f.pid, f.endpoint,
+    f.port, f.hops, f.data_len, f.data, f.list_count, f.id,
+}
+
+-- id_list 解析 uint16 个数 + 若干 uint32 的ID列表，返回下一个字段的偏移
+local function id_list(buf, tree, offset, name)
+    local count = buf(offset, 2):uint()
+    local size = 2 + 4 * count
+    local sub = tree:add(buf(offset, size), string.format("%s (%d)", name, count))
+    sub:add(f.list_count, buf(offset, 2))
+    for i = 0, count - 1 do
+        sub:add(f.id, buf(offset + 2 + 4 * i, 4))
+    end
+    return offset + size
+end
+
+local payloads = {
+    [1] = function(buf, tree, o) -- HELLO
+        tree:add(f.active, buf(o, 1))
+        tree:add(f.ring_id, buf(o + 1, 4))
+        tree:add(f.candidate, buf(o + 5, 4))
+        tree:add(f.candidate_hops, buf(o + 9, 1))
+        tree:add(f.candidate_seq, buf(o + 10, 4))
+        o = id_list(buf, tree, o + 14, "Link active")
+        o = id_list(buf, tree, o, "Link not active")
+        id_list(buf, tree, o, "Pending")
+    end,
+    [2] = function(buf, tree, o) -- SETUP_REQ
+        tree:add(f.proxy, buf(o, 4))
+        id_list(buf, tree, o + 4, "Vset'")
+    end,
+    [3] = function(buf, tree, o) -- SETUP
+        tree:add(f.pid, buf(o, 4))
+        tree:add(f.proxy, buf(o + 4, 4))
+        tree:add(f.ring_id, buf(o + 8, 4))
+        id_list(buf, tree, o + 12, "Vset'")
+    end,
+    [4] = function(buf, tree, o) -- SETUP_FAIL
+        tree:add(f.proxy, buf(o, 4))
+        id_list(buf, tree, o + 4, "Vset'")
+    end,
+    [5] = function(buf, tree, o) -- TEARDOWN
+        tree:add(f.pid, buf(o, 4))
+        tree:add(f.endpoint, buf(o + 4, 4))
+        id_list(buf, tree, o + 8, "Vset'")
+    end,
+    [6] = function(buf, tree, o) -- DATA
+        tree:add(f.port, buf(o, 2))
+        tree:add(f.hops, buf(o + 2, 1))
+        tree:add(f.data_len, buf(o + 3, 2))
+        local size = buf(o + 3, 2):uint()
+        if size > 0 then
+            tree:add(f.data, buf(o + 5, size))
+        end
+    end,
+    [7] = function(buf, tree, o) -- PATH_SYNC
+        local count = buf(o, 2):uint()
+        local sub = tree:add(buf(o, 2 + 8 * count), string.format("Paths (%d)", count))
+        sub:add(f.list_count, buf(o, 2))
+        for i = 0, count - 1 do
+            sub:add(f.pid, buf(o + 2 + 8 * i, 4))
+            sub:add(f.endpoint, buf(o + 6 + 8 * i, 4))
+        end
+    end,
+    [8] = function(buf, tree, o) -- VSET_SHARE
+        id_list(buf, tree, o, "Vset'")
+    end,
+}
+
+function vrr.dissector(buf, pinfo, root)
+    if buf:len() < 8 + 28 then
+        return 0
+    end
+    pinfo.cols.protocol = "VRR"
+
+    local tree = root:add(vrr, buf())
+    local cap = tree:add(buf(0, 8), "Capture")
+    cap:add(f.cap_version, buf(0, 1))
+    cap:add(f.cap_event, buf(1, 1))
+    cap:add(f.cap_reason, buf(2, 1))
+    cap:add(f.cap_subnet, buf(4, 4))
+
+    local h = 8
+    local msg_type = buf(h + 1, 1):uint()
+    local hdr = tree:add(buf(h, 28), "Header")
+    hdr:add(f.version, buf(h, 1))
+    hdr:add(f.type, buf(h + 1, 1))
+    hdr:add(f.length, buf(h + 2, 2))
+    hdr:add(f.src, buf(h + 4, 4))
+    hdr:add(f.dst, buf(h + 8, 4))
+    hdr:add(f.next_hop, buf(h + 12, 4))
+    hdr:add(f.sender, buf(h + 16, 4))
+    hdr:add(f.trace_id, buf(h + 20, 8))
+
+    local length = buf(h + 2, 2):uint()
+    local payload = payloads[msg_type]
+    if payload and length > 0 then
+        local body = tree:add(buf(h + 28, length), "Payload")
+        payload(buf, body, h + 28)
+    end
+
+    -- 源/目的列显示本跳的发送者和下一跳
+    pinfo.cols.src = tostring(buf(h + 16, 4):uint())
+    pinfo.cols.dst = tostring(buf(h + 12, 4):uint())
+    local event = events[buf(1, 1):uint()] or "?"
+    local info = string.format("%s %s %d -> %d (src %d, dst %d)",
+        event, types[msg_type] or "UNKNOWN",
+        buf(h + 16, 4):uint(), buf(h + 12, 4):uint(),
+        buf(h + 4, 4):uint(), buf(h + 8, 4):uint())
+    if event == "drop" then
+        info = info .. " [" .. (reasons[buf(2, 1):uint()] or "?") .. "]"
+    end
+    pinfo.cols.info = info
+    return buf:len()
+end
+
+DissectorTable.get("wtap_encap"):add(wtap.USER0, vrr)
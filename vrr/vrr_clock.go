@@ -0,0 +1,167 @@
+package vrr
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Clock 抽象节点和网络使用的时间源：真实时间或离散事件虚拟时间
+type Clock interface {
+	Now() time.Time
+	// AfterFunc 在 d 之后调用 f，返回可用于取消的 Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer 是 Clock.AfterFunc 返回的定时器
+type Timer interface {
+	// Stop 取消尚未触发的定时器，返回是否成功取消
+	Stop() bool
+}
+
+// RealClock 基于 time 包的真实时钟，是节点和网络的默认时钟
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+// IsVirtual 判断时钟是否为离散事件虚拟时钟
+func IsVirtual(c Clock) bool {
+	_, ok := c.(*VirtualClock)
+	return ok
+}
+
+// VirtualClock 离散事件调度器。事件按 (触发时间, 调度顺序) 排序，
+// 由调用 Step/RunFor/RunUntil 的 goroutine 依次执行，因此在相同的输入和随机种子下，
+// 整个仿真的执行顺序完全确定，并且不依赖真实时间的流逝。
+type VirtualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64
+	events eventHeap
+}
+
+// NewVirtualClock 创建从 start 开始的虚拟时钟
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+// Now 返回当前虚拟时间
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc 在虚拟时间 d 之后调度 f；d <= 0 时在当前时刻的已有事件之后执行
+func (c *VirtualClock) AfterFunc(d time.Duration, f func()) Timer {
+	if d < 0 {
+		d = 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	ev := &event{at: c.now.Add(d), seq: c.seq, f: f, clock: c}
+	heap.Push(&c.events, ev)
+	return ev
+}
+
+// Pending 返回尚未执行的事件数
+func (c *VirtualClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.events)
+}
+
+// Step 将时间推进到下一个事件并执行它，没有事件时返回 false
+func (c *VirtualClock) Step() bool {
+	c.mu.Lock()
+	if len(c.events) == 0 {
+		c.mu.Unlock()
+		return false
+	}
+	ev := heap.Pop(&c.events).(*event)
+	c.now = ev.at
+	c.mu.Unlock()
+
+	// 执行事件时不持有锁，事件可以继续调度新事件
+	ev.f()
+	return true
+}
+
+// RunUntil 依次执行触发时间不晚于 t 的所有事件，然后将时间推进到 t
+func (c *VirtualClock) RunUntil(t time.Time) {
+	for {
+		c.mu.Lock()
+		if len(c.events) == 0 || c.events[0].at.After(t) {
+			if c.now.Before(t) {
+				c.now = t
+			}
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+		c.Step()
+	}
+}
+
+// RunFor 从当前虚拟时间起运行 d
+func (c *VirtualClock) RunFor(d time.Duration) {
+	c.RunUntil(c.Now().Add(d))
+}
+
+// event 是 VirtualClock 中的一个待执行事件，同时实现 Timer
+type event struct {
+	at    time.Time
+	seq   uint64
+	f     func()
+	index int // 在堆中的位置，-1 表示已出堆
+	clock *VirtualClock
+}
+
+func (ev *event) Stop() bool {
+	c := ev.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ev.index < 0 {
+		return false
+	}
+	heap.Remove(&c.events, ev.index)
+	return true
+}
+
+// eventHeap 按 (at, seq) 排序的最小堆
+type eventHeap []*event
+
+func (h eventHeap) Len() int { return len(h) }
+
+func (h eventHeap) Less(i, j int) bool {
+	if !h[i].at.Equal(h[j].at) {
+		return h[i].at.Before(h[j].at)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h eventHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *eventHeap) Push(x any) {
+	ev := x.(*event)
+	ev.index = len(*h)
+	*h = append(*h, ev)
+}
+
+func (h *eventHeap) Pop() any {
+	old := *h
+	ev := old[len(old)-1]
+	old[len(old)-1] = nil
+	ev.index = -1
+	*h = old[:len(old)-1]
+	return ev
+}
@@ -0,0 +1,358 @@
+package vrr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+/*
+VRR 报文的二进制格式（网络字节序）：
+
+	0       1       2               4
+	+-------+-------+---------------+
+	|Version| Type  |    Length     |   Length: Payload 的字节数
+	+-------+-------+---------------+
+	|              Src              |
+	+-------------------------------+
+	|              Dst              |
+	+-------------------------------+
+	|            NextHop            |
+	+-------------------------------+
+	|            Sender             |
+	+-------------------------------+
+	|        TraceID (8 bytes)      |   0 表示不追踪
+	+-------------------------------+
+	|        Payload (Length)       |
+	+-------------------------------+
+
+Payload 按消息类型编码，ID 列表编码为 uint16 个数 + 若干 uint32：
+
+	HELLO:      Flags(1, bit0=SenderActive) RingID(4) Candidate(4) CandidateHops(1) CandidateSeq(4)
+	            LinkActive(list) LinkNotActive(list) Pending(list)
+	SETUP_REQ:  Proxy(4) Vset'(list)
+	SETUP:      Pid(4) Proxy(4) RingID(4) Vset'(list)
+	SETUP_FAIL: Proxy(4) Vset'(list)
+	TEARDOWN:   Pid(4) Endpoint(4) Vset'(list)
+	DATA:       Port(2) Hops(1) DataLen(2) Data(DataLen)
+	PATH_SYNC:  Count(2) 若干 Pid(4) Endpoint(4)
+	VSET_SHARE: Vset'(list)
+*/
+
+const (
+	VRR_WIRE_VERSION = 1
+	VRR_HEADER_LEN   = 28
+)
+
+var (
+	ErrWireVersion   = errors.New("vrr: unsupported wire version")
+	ErrWireTruncated = errors.New("vrr: truncated packet")
+	ErrWireType      = errors.New("vrr: unknown message type")
+	ErrWireLength    = errors.New("vrr: payload length mismatch")
+	ErrWireList      = errors.New("vrr: id list too long")
+	ErrWireTooLarge  = errors.New("vrr: packet too large")
+)
+
+// MarshalBinary 实现 encoding.BinaryMarshaler，将消息编码为带版本和长度前缀的二进制报文
+func (m Message) MarshalBinary() ([]byte, error) {
+	body, err := marshalPayload(m.Type, m.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > 0xFFFF {
+		return nil, fmt.Errorf("%w: %d byte payload", ErrWireTooLarge, len(body))
+	}
+
+	buf := make([]byte, 0, VRR_HEADER_LEN+len(body))
+	buf = append(buf, VRR_WIRE_VERSION, m.Type)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(body)))
+	buf = binary.BigEndian.AppendUint32(buf, m.Src)
+	buf = binary.BigEndian.AppendUint32(buf, m.Dst)
+	buf = binary.BigEndian.AppendUint32(buf, m.NextHop)
+	buf = binary.BigEndian.AppendUint32(buf, m.Sender)
+	buf = binary.BigEndian.AppendUint64(buf, m.TraceID)
+	return append(buf, body...), nil
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler，严格校验版本、长度和列表大小(VRR_MAX_PSET_SIZE/VRR_MAX_VSET_SIZE)
+func (m *Message) UnmarshalBinary(data []byte) error {
+	if len(data) < VRR_HEADER_LEN {
+		return ErrWireTruncated
+	}
+	if data[0] != VRR_WIRE_VERSION {
+		return fmt.Errorf("%w: %d", ErrWireVersion, data[0])
+	}
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if len(data)-VRR_HEADER_LEN != length {
+		return fmt.Errorf("%w: header says %d, got %d", ErrWireLength, length, len(data)-VRR_HEADER_LEN)
+	}
+
+	payload, err := unmarshalPayload(data[1], data[VRR_HEADER_LEN:])
+	if err != nil {
+		return err
+	}
+
+	m.Type = data[1]
+	m.Src = binary.BigEndian.Uint32(data[4:8])
+	m.Dst = binary.BigEndian.Uint32(data[8:12])
+	m.NextHop = binary.BigEndian.Uint32(data[12:16])
+	m.Sender = binary.BigEndian.Uint32(data[16:20])
+	m.TraceID = binary.BigEndian.Uint64(data[20:28])
+	m.Payload = payload
+	return nil
+}
+
+// marshalPayload 按消息类型编码 Payload，类型与 Payload 不一致时返回错误
+func marshalPayload(msgType uint8, payload Payload) ([]byte, error) {
+	var buf []byte
+	var err error
+
+	switch p := payload.(type) {
+	case *HelloPayload:
+		if msgType != VRR_HELLO {
+			break
+		}
+		var flags uint8
+		if p.SenderActive {
+			flags |= 0x1
+		}
+		buf = append(buf, flags)
+		buf = binary.BigEndian.AppendUint32(buf, p.RingID)
+		buf = binary.BigEndian.AppendUint32(buf, p.Candidate)
+		buf = append(buf, p.CandidateHops)
+		buf = binary.BigEndian.AppendUint32(buf, p.CandidateSeq)
+		for _, ids := range [][]uint32{p.HelloInfoLinkActive, p.HelloInfoLinkNotActive, p.HelloInfoPending} {
+			if buf, err = appendIDList(buf, ids, VRR_MAX_PSET_SIZE); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case *SetupReqPayload:
+		if msgType != VRR_SETUP_REQ {
+			break
+		}
+		buf = binary.BigEndian.AppendUint32(buf, p.Proxy)
+		return appendIDList(buf, p.Vset_, VRR_MAX_VSET_SIZE)
+	case *SetupPayload:
+		if msgType != VRR_SETUP {
+			break
+		}
+		buf = binary.BigEndian.AppendUint32(buf, p.Pid)
+		buf = binary.BigEndian.AppendUint32(buf, p.Proxy)
+		buf = binary.BigEndian.AppendUint32(buf, p.RingID)
+		return appendIDList(buf, p.Vset_, VRR_MAX_VSET_SIZE)
+	case *SetupFailPayload:
+		if msgType != VRR_SETUP_FAIL {
+			break
+		}
+		buf = binary.BigEndian.AppendUint32(buf, p.Proxy)
+		return appendIDList(buf, p.Vset_, VRR_MAX_VSET_SIZE)
+	case *TeardownPayload:
+		if msgType != VRR_TEARDOWN {
+			break
+		}
+		buf = binary.BigEndian.AppendUint32(buf, p.Pid)
+		buf = binary.BigEndian.AppendUint32(buf, p.Endpoint)
+		return appendIDList(buf, p.Vset_, VRR_MAX_VSET_SIZE)
+	case *DataPayload:
+		if msgType != VRR_DATA {
+			break
+		}
+		if len(p.Data) > 0xFFFF {
+			return nil, fmt.Errorf("%w: %d byte data", ErrWireTooLarge, len(p.Data))
+		}
+		buf = binary.BigEndian.AppendUint16(buf, p.Port)
+		buf = append(buf, p.Hops)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(p.Data)))
+		return append(buf, p.Data...), nil
+	case *PathSyncPayload:
+		if msgType != VRR_PATH_SYNC {
+			break
+		}
+		if len(p.Paths) > VRR_MAX_SYNC_PATHS {
+			return nil, fmt.Errorf("%w: %d > %d", ErrWireList, len(p.Paths), VRR_MAX_SYNC_PATHS)
+		}
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(p.Paths)))
+		for _, path := range p.Paths {
+			buf = binary.BigEndian.AppendUint32(buf, path.Pid)
+			buf = binary.BigEndian.AppendUint32(buf, path.Endpoint)
+		}
+		return buf, nil
+	case *VsetSharePayload:
+		if msgType != VRR_VSET_SHARE {
+			break
+		}
+		return appendIDList(buf, p.Vset_, VRR_MAX_VSET_SIZE)
+	}
+	return nil, fmt.Errorf("%w: %s with payload %T", ErrWireType, GetMessageTypeString(msgType), payload)
+}
+
+// unmarshalPayload 按消息类型解码 Payload，要求恰好消耗全部字节
+func unmarshalPayload(msgType uint8, data []byte) (Payload, error) {
+	r := wireReader{buf: data}
+	var payload Payload
+
+	switch msgType {
+	case VRR_HELLO:
+		p := &HelloPayload{}
+		p.SenderActive = r.uint8()&0x1 != 0
+		p.RingID = r.uint32()
+		p.Candidate = r.uint32()
+		p.CandidateHops = r.uint8()
+		p.CandidateSeq = r.uint32()
+		p.HelloInfoLinkActive = r.idList(VRR_MAX_PSET_SIZE)
+		p.HelloInfoLinkNotActive = r.idList(VRR_MAX_PSET_SIZE)
+		p.HelloInfoPending = r.idList(VRR_MAX_PSET_SIZE)
+		payload = p
+	case VRR_SETUP_REQ:
+		p := &SetupReqPayload{}
+		p.Proxy = r.uint32()
+		p.Vset_ = r.idList(VRR_MAX_VSET_SIZE)
+		payload = p
+	case VRR_SETUP:
+		p := &SetupPayload{}
+		p.Pid = r.uint32()
+		p.Proxy = r.uint32()
+		p.RingID = r.uint32()
+		p.Vset_ = r.idList(VRR_MAX_VSET_SIZE)
+		payload = p
+	case VRR_SETUP_FAIL:
+		p := &SetupFailPayload{}
+		p.Proxy = r.uint32()
+		p.Vset_ = r.idList(VRR_MAX_VSET_SIZE)
+		payload = p
+	case VRR_TEARDOWN:
+		p := &TeardownPayload{}
+		p.Pid = r.uint32()
+		p.Endpoint = r.uint32()
+		p.Vset_ = r.idList(VRR_MAX_VSET_SIZE)
+		payload = p
+	case VRR_DATA:
+		p := &DataPayload{}
+		p.Port = r.uint16()
+		p.Hops = r.uint8()
+		p.Data = r.bytes(int(r.uint16()))
+		payload = p
+	case VRR_PATH_SYNC:
+		p := &PathSyncPayload{}
+		p.Paths = r.pathList(VRR_MAX_SYNC_PATHS)
+		payload = p
+	case VRR_VSET_SHARE:
+		p := &VsetSharePayload{}
+		p.Vset_ = r.idList(VRR_MAX_VSET_SIZE)
+		payload = p
+	default:
+		return nil, fmt.Errorf("%w: 0x%x", ErrWireType, msgType)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.buf) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing byte(s) in %s", ErrWireLength, len(r.buf), GetMessageTypeString(msgType))
+	}
+	return payload, nil
+}
+
+// appendIDList 编码一个ID列表：uint16 个数 + uint32 * 个数
+func appendIDList(buf []byte, ids []uint32, limit int) ([]byte, error) {
+	if len(ids) > limit {
+		return nil, fmt.Errorf("%w: %d > %d", ErrWireList, len(ids), limit)
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(ids)))
+	for _, id := range ids {
+		buf = binary.BigEndian.AppendUint32(buf, id)
+	}
+	return buf, nil
+}
+
+// wireReader 顺序读取报文字段，出现第一个错误后后续读取均返回零值
+type wireReader struct {
+	buf []byte
+	err error
+}
+
+func (r *wireReader) take(size int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.buf) < size {
+		r.err = ErrWireTruncated
+		return nil
+	}
+	b := r.buf[:size]
+	r.buf = r.buf[size:]
+	return b
+}
+
+func (r *wireReader) uint8() uint8 {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *wireReader) uint16() uint16 {
+	if b := r.take(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *wireReader) uint32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *wireReader) bytes(size int) []byte {
+	if b := r.take(size); b != nil {
+		return append([]byte(nil), b...)
+	}
+	return nil
+}
+
+func (r *wireReader) idList(limit int) []uint32 {
+	count := int(r.uint16())
+	if r.err != nil {
+		return nil
+	}
+	if count > limit {
+		r.err = fmt.Errorf("%w: %d > %d", ErrWireList, count, limit)
+		return nil
+	}
+	if count == 0 {
+		return nil
+	}
+	ids := make([]uint32, 0, count)
+	for i := 0; i < count; i++ {
+		ids = append(ids, r.uint32())
+	}
+	if r.err != nil {
+		return nil
+	}
+	return ids
+}
+
+func (r *wireReader) pathList(limit int) []PathRef {
+	count := int(r.uint16())
+	if r.err != nil {
+		return nil
+	}
+	if count > limit {
+		r.err = fmt.Errorf("%w: %d > %d", ErrWireList, count, limit)
+		return nil
+	}
+	if count == 0 {
+		return nil
+	}
+	paths := make([]PathRef, 0, count)
+	for i := 0; i < count; i++ {
+		paths = append(paths, PathRef{Pid: r.uint32(), Endpoint: r.uint32()})
+	}
+	if r.err != nil {
+		return nil
+	}
+	return paths
+}
@@ -0,0 +1,185 @@
+package vrr
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	// 编解码时 ID 列表的长度上限，Config 中的 VsetSize/PsetSize 不能超过它们
+	VRR_MAX_VSET_SIZE = 64
+	VRR_MAX_PSET_SIZE = 256
+
+	// HELLO 周期的默认值：500ms ± 300ms
+	VRR_HELLO_INTERVAL = 500 * time.Millisecond
+	VRR_HELLO_JITTER   = 300 * time.Millisecond
+
+	// 消息接收通道的默认容量
+	VRR_INBOX_SIZE = 256
+	// 交付给 Recv 的数据包通道的默认容量
+	VRR_RECV_SIZE = 256
+)
+
+// Config 是单个节点的协议参数，零值字段使用 DefaultConfig 中的默认值。
+// 同一进程中的节点可以使用不同的参数，例如比较 vset 大小为 4、8、16 时的收敛情况
+type Config struct {
+	VsetSize      int           // 虚拟邻居个数，顺时针和逆时针方向各一半，必须为偶数
+	PsetSize      int           // HELLO 中每个物理邻居列表的最大长度
+	FailTimeout   int           // 连续多少个 HELLO 周期没有收到邻居的消息后将其标记为失败，两倍时删除
+	ActiveTimeout int           // 没有虚拟邻居时经过多少个 HELLO 周期后自我激活
+	HelloInterval time.Duration // HELLO 周期
+	HelloJitter   time.Duration // HELLO 周期的随机抖动范围，必须小于 HelloInterval；零值按默认比例随 HelloInterval 缩放，负数表示不加抖动
+	InboxSize     int           // 消息接收通道的容量
+	RecvSize      int           // 交付给 Recv 的数据包通道的容量，应用来不及读取时多出的数据包被丢弃
+	SetupRetry    time.Duration // 同一目标的 setup_req 的最小重发间隔
+	SetupTries    int           // 同一目标的 setup_req 按 SetupRetry 间隔发送的次数，之后重发间隔逐次加倍，加倍 VRR_SETUP_BACKOFF 次后放弃
+	PathSync      int           // 每隔多少个 HELLO 周期与物理邻居核对一次经过它的路由条目
+
+	Bootstrap uint8   // 自举模式，BOOTSTRAP_*
+	Clock     Clock   // 时钟，nil 表示 RealClock
+	Seed      int64   // 随机种子，0 表示不固定
+	Logger    *Logger // 日志，nil 表示 DefaultLogger()
+	Tracer    Tracer  // 消息追踪钩子，nil 表示不追踪
+}
+
+// DefaultConfig 返回默认的节点参数
+func DefaultConfig() Config {
+	return Config{
+		VsetSize:      VRR_VSET_SIZE,
+		PsetSize:      VRR_PSET_SIZE,
+		FailTimeout:   VRR_FAIL_TIMEOUT,
+		ActiveTimeout: VRR_ACTIVE_TIMEOUT,
+		HelloInterval: VRR_HELLO_INTERVAL,
+		HelloJitter:   VRR_HELLO_JITTER,
+		InboxSize:     VRR_INBOX_SIZE,
+		RecvSize:      VRR_RECV_SIZE,
+		SetupRetry:    VRR_SETUP_RETRY,
+		SetupTries:    VRR_SETUP_TRIES,
+		PathSync:      VRR_PATH_SYNC_PERIOD,
+		Bootstrap:     BOOTSTRAP_TIMEOUT,
+		Clock:         RealClock,
+		Logger:        defaultLogger,
+	}
+}
+
+// withDefaults 返回用默认值填充零值字段后的参数
+func (c Config) withDefaults() Config {
+	def := DefaultConfig()
+	if c.VsetSize == 0 {
+		c.VsetSize = def.VsetSize
+	}
+	if c.PsetSize == 0 {
+		c.PsetSize = def.PsetSize
+	}
+	if c.FailTimeout == 0 {
+		c.FailTimeout = def.FailTimeout
+	}
+	if c.ActiveTimeout == 0 {
+		c.ActiveTimeout = def.ActiveTimeout
+	}
+	if c.HelloInterval == 0 {
+		c.HelloInterval = def.HelloInterval
+	}
+	if c.HelloJitter == 0 {
+		// 默认抖动按 HelloInterval 等比例缩放，缩短 HELLO 周期时抖动仍小于周期
+		c.HelloJitter = time.Duration(float64(c.HelloInterval) * float64(def.HelloJitter) / float64(def.HelloInterval))
+	}
+	if c.InboxSize == 0 {
+		c.InboxSize = def.InboxSize
+	}
+	if c.RecvSize == 0 {
+		c.RecvSize = def.RecvSize
+	}
+	if c.SetupRetry == 0 {
+		c.SetupRetry = def.SetupRetry
+	}
+	if c.SetupTries == 0 {
+		c.SetupTries = def.SetupTries
+	}
+	if c.PathSync == 0 {
+		c.PathSync = def.PathSync
+	}
+	if c.Clock == nil {
+		c.Clock = def.Clock
+	}
+	if c.Logger == nil {
+		c.Logger = def.Logger
+	}
+	return c
+}
+
+// Validate 检查参数是否合法，零值字段视为默认值
+func (c Config) Validate() error {
+	c = c.withDefaults()
+	switch {
+	case c.VsetSize < 2 || c.VsetSize%2 != 0:
+		return fmt.Errorf("vrr: vset size %d must be a positive even number", c.VsetSize)
+	case c.VsetSize > VRR_MAX_VSET_SIZE:
+		return fmt.Errorf("vrr: vset size %d exceeds %d", c.VsetSize, VRR_MAX_VSET_SIZE)
+	case c.PsetSize < 1 || c.PsetSize > VRR_MAX_PSET_SIZE:
+		return fmt.Errorf("vrr: pset size %d out of range [1, %d]", c.PsetSize, VRR_MAX_PSET_SIZE)
+	case c.FailTimeout < 1 || c.ActiveTimeout < 1:
+		return fmt.Errorf("vrr: fail timeout %d and active timeout %d must be positive", c.FailTimeout, c.ActiveTimeout)
+	case c.HelloInterval <= 0:
+		return fmt.Errorf("vrr: hello interval %v must be positive", c.HelloInterval)
+	case c.HelloJitter >= c.HelloInterval:
+		return fmt.Errorf("vrr: hello jitter %v must be less than the hello interval %v", c.HelloJitter, c.HelloInterval)
+	case c.InboxSize < 1:
+		return fmt.Errorf("vrr: inbox size %d must be positive", c.InboxSize)
+	case c.RecvSize < 1:
+		return fmt.Errorf("vrr: recv size %d must be positive", c.RecvSize)
+	case c.SetupRetry < 0 || c.SetupTries < 1:
+		return fmt.Errorf("vrr: invalid setup retry %v or tries %d", c.SetupRetry, c.SetupTries)
+	case c.PathSync < 1:
+		return fmt.Errorf("vrr: path sync period %d must be positive", c.PathSync)
+	case int(c.Bootstrap) >= len(bootstrapModes):
+		return fmt.Errorf("vrr: unknown bootstrap mode %d", c.Bootstrap)
+	}
+	return nil
+}
+
+// NewNodeWithConfig 按给定参数创建节点，参数不合法时返回错误
+func NewNodeWithConfig(id uint32, Network Networker, config Config) (*Node, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config = config.withDefaults()
+
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano() + int64(id)
+	}
+	n := &Node{
+		ID:        id,
+		InboxChan: make(chan Message, config.InboxSize),
+		StopChan:  make(chan struct{}),
+		Network:   Network,
+		Active:    false,
+		Bootstrap: config.Bootstrap,
+		config:    config,
+		handlers:  make(map[uint16]DataHandler),
+		RecvChan:  make(chan Delivery, config.RecvSize),
+		setupSent: make(map[uint32]*setupAttempt),
+		clock:     config.Clock,
+		logger:    config.Logger.With("node", id),
+		metrics:   newNodeMetrics(),
+		tracer:    config.Tracer,
+		rng:       rand.New(rand.NewSource(seed)),
+	}
+
+	// 为这个新节点创建一套独立的管理器
+	n.PsetManager = NewPsetManager(n)
+	n.VsetManager = NewVsetManager(n)
+	n.RoutingTable = NewRoutingTableManager(n)
+	n.PsetStateManager = NewPsetStateManager(n)
+
+	return n, nil
+}
+
+// Config 返回节点的协议参数
+func (n *Node) Config() Config {
+	n.hookLock.RLock()
+	defer n.hookLock.RUnlock()
+	return n.config
+}
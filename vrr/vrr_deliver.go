@@ -0,0 +1,76 @@
+package vrr
+
+import (
+	"context"
+)
+
+// Delivery 描述一个已到达目的节点、需要递交给上层应用的 VRR_DATA 数据包
+type Delivery struct {
+	Src    uint32 // 数据包的逻辑发起者ID
+	Sender uint32 // 最后一跳的物理邻居ID
+	Port   uint16 // 目的端口
+	Hops   uint8  // 数据包经过的物理链路数
+	Data   []byte // 应用数据
+}
+
+// DataHandler 是上层应用注册的数据处理函数。
+// 它在节点的消息处理 goroutine 中被调用，不应长时间阻塞。
+type DataHandler func(d Delivery)
+
+// Handle 为指定端口注册数据处理函数，重复注册会覆盖之前的处理函数
+func (n *Node) Handle(port uint16, handler DataHandler) {
+	n.handlerLock.Lock()
+	defer n.handlerLock.Unlock()
+	if n.handlers == nil {
+		n.handlers = make(map[uint16]DataHandler)
+	}
+	n.handlers[port] = handler
+}
+
+// Unhandle 注销指定端口的数据处理函数，之后该端口的数据包改为投递到 RecvChan
+func (n *Node) Unhandle(port uint16) {
+	n.handlerLock.Lock()
+	defer n.handlerLock.Unlock()
+	delete(n.handlers, port)
+}
+
+// Recv 阻塞等待下一个未被处理函数消费的数据包，直到 ctx 被取消
+func (n *Node) Recv(ctx context.Context) (Delivery, error) {
+	select {
+	case d := <-n.RecvChan:
+		return d, nil
+	case <-ctx.Done():
+		return Delivery{}, ctx.Err()
+	}
+}
+
+// deliver 将到达目的地的数据包交给上层应用：
+// 优先交给端口对应的处理函数，否则放入 RecvChan
+func (n *Node) deliver(msg Message, payload *DataPayload) {
+	d := Delivery{
+		Src:    msg.Src,
+		Sender: msg.Sender,
+		Port:   payload.Port,
+		Hops:   payload.Hops + 1,
+		Data:   payload.Data,
+	}
+
+	n.trace(TRACE_DELIVER, msg, 0, "")
+
+	n.handlerLock.RLock()
+	handler, ok := n.handlers[payload.Port]
+	n.handlerLock.RUnlock()
+
+	if ok {
+		handler(d)
+		return
+	}
+
+	// 非阻塞投递，应用来不及读取时丢弃，防止阻塞消息处理循环
+	select {
+	case n.RecvChan <- d:
+	default:
+		n.Logger().Warn(LOG_ROUTING, "recv queue full, discarding data", "src", msg.Src, "port", payload.Port)
+		n.countDrop(msg, DROP_RECV_FULL)
+	}
+}
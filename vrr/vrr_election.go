@@ -0,0 +1,109 @@
+package vrr
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 自举模式
+	BOOTSTRAP_TIMEOUT       = 0 // 随机抖动 + 超时后立即自我激活（默认）
+	BOOTSTRAP_ELECT_LOWEST  = 1 // 连通分量中ID最小的非活跃节点自举
+	BOOTSTRAP_ELECT_HIGHEST = 2 // 连通分量中ID最大的非活跃节点自举
+
+	// 候选者通过 HELLO 逐跳传播的最大跳数。离开的候选者由序号过期淘汰(见 Candidate)，
+	// 跳数上限只是额外的保护
+	VRR_ELECTION_MAX_HOPS = 16
+)
+
+var bootstrapModes = []string{"timeout", "elect-lowest", "elect-highest"}
+
+// SetBootstrapMode 设置节点的自举模式，应在 Start 之前调用
+func (n *Node) SetBootstrapMode(mode uint8) error {
+	if int(mode) >= len(bootstrapModes) {
+		return fmt.Errorf("vrr: unknown bootstrap mode %d", mode)
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.Bootstrap = mode
+	n.config.Bootstrap = mode
+	return nil
+}
+
+// ParseBootstrapMode 将自举模式名称（"timeout"、"elect-lowest"、"elect-highest"）解析为模式常量
+func ParseBootstrapMode(name string) (uint8, error) {
+	for i, m := range bootstrapModes {
+		if m == name {
+			return uint8(i), nil
+		}
+	}
+	return 0, fmt.Errorf("vrr: unknown bootstrap mode %q", name)
+}
+
+// betterCandidate 判断在当前自举模式下候选者 a 是否优于 b
+func (n *Node) betterCandidate(a, b uint32) bool {
+	if n.Bootstrap == BOOTSTRAP_ELECT_HIGHEST {
+		return a > b
+	}
+	return a < b
+}
+
+// Candidate 计算本节点当前认可的自举候选者、到它的跳数和它的序号。
+// 候选者在自身与非失败物理邻居通告的候选者中选出，超过最大跳数的通告被忽略。
+// 候选者每个 HELLO 周期把序号加一，离开后邻居之间只能互相转发旧的序号：
+// 序号在 FailTimeout 个 HELLO 周期内没有增长的候选者被视为已经离开，
+// 因此离开的候选者最多 FailTimeout 个 HELLO 周期后不再被认可，与网络直径无关。
+func (n *Node) Candidate() (uint32, uint8, uint32) {
+	best, bestHops, bestSeq := n.ID, uint8(0), atomic.LoadUint32(&n.electionSeq)
+	now := n.clock.Now()
+	expiry := n.candidateExpiry()
+
+	n.PsetManager.lock.RLock()
+	defer n.PsetManager.lock.RUnlock()
+
+	for e := n.PsetManager.psetList.Front(); e != nil; e = e.Next() {
+		pNode := e.Value.(*PsetNode)
+		if pNode.Status == PSET_FAILED || pNode.Candidate == 0 || pNode.Candidate == n.ID {
+			continue
+		}
+		if pNode.CandidateHops+1 > VRR_ELECTION_MAX_HOPS {
+			continue
+		}
+		if seen, ok := n.PsetManager.candidates[pNode.Candidate]; !ok || now.Sub(seen.at) > expiry {
+			continue
+		}
+		hops := pNode.CandidateHops + 1
+		if n.betterCandidate(pNode.Candidate, best) ||
+			(pNode.Candidate == best && hops < bestHops) {
+			best, bestHops, bestSeq = pNode.Candidate, hops, pNode.CandidateSeq
+		}
+	}
+	return best, bestHops, bestSeq
+}
+
+// candidateExpiry 返回候选者序号没有增长多久后被视为已经离开
+func (n *Node) candidateExpiry() time.Duration {
+	return time.Duration(n.config.FailTimeout) * n.config.HelloInterval
+}
+
+// electedToBootstrap 判断选举模式下本节点是否应当自举：
+// 自己是连通分量中的最优候选者，且没有可以作为代理加入的活跃邻居
+func (n *Node) electedToBootstrap() bool {
+	n.PsetManager.lock.RLock()
+	for e := n.PsetManager.psetList.Front(); e != nil; e = e.Next() {
+		pNode := e.Value.(*PsetNode)
+		if pNode.Status == PSET_LINKED && pNode.Active {
+			n.PsetManager.lock.RUnlock()
+			return false
+		}
+	}
+	n.PsetManager.lock.RUnlock()
+
+	candidate, _, _ := n.Candidate()
+	if candidate != n.ID {
+		n.Logger().Debug(LOG_NODE, "waiting for elected candidate to bootstrap", "candidate", candidate)
+		return false
+	}
+	return true
+}
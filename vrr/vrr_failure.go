@@ -0,0 +1,101 @@
+package vrr
+
+/*
+物理链路失效处理：
+    for each (<ea, eb, na, nb, pid> ∈ rt with na = failed ∨ nb = failed)
+        TearDownPath(<pid, ea>, failed)
+    teardown 携带空的 vset'，路径两端的端点收到后将对端移出 vset，
+    并经由代理重新发起 setup_req（见 endpointLost）
+*/
+// LinkFailed 在物理邻居被标记为失败时拆除所有经过它的 vset-paths
+func (n *Node) LinkFailed(neighbor uint32) {
+	me := n.ID
+	paths := n.RoutingTable.getPathsByNextHop(neighbor)
+	if len(paths) == 0 {
+		return
+	}
+	n.Logger().Info(LOG_PSET, "link to neighbor failed, tearing down paths", "peer", neighbor, "paths", len(paths))
+
+	for _, path := range paths {
+		// sender 为失败的邻居：表示故障清理，teardown 不携带 vset
+		n.RoutingTable.TearDownPath(path.PathId, path.Ea, neighbor)
+
+		// 本节点自己是路径端点时，没有 teardown 会送达本节点，直接处理对端的丢失
+		switch me {
+		case path.Ea:
+			n.endpointLost(path.Eb, nil)
+		case path.Eb:
+			n.endpointLost(path.Ea, nil)
+		}
+	}
+}
+
+/*
+   e := (sender = na) ? ea : eb
+   Remove(vset, e)
+   if (vset’ != null)
+       Add(vset, null, vset’)
+   else
+       proxy := PickRandomActive(pset)
+       Send <setup_req, me, e, proxy, vset> to proxy
+*/
+// endpointLost 在本节点作为端点的一条 vset-path 被拆除后更新 vset。
+// 到 e 还有其他路径时保留 e；vset' 为空说明是链路故障，经由代理重新向 e 发起 setup_req。
+func (n *Node) endpointLost(e uint32, vset_ []uint32) {
+	if e == 0 || e == n.ID {
+		return
+	}
+	if !n.RoutingTable.HasPathTo(e) {
+		n.VsetManager.Remove(e)
+	}
+
+	vset := n.VsetManager.GetAll()
+	if len(vset_) > 0 {
+		// 合并vset'到本地vset
+		n.Add(vset, 0, vset_)
+		return
+	}
+
+	proxy, ok := n.PsetManager.GetProxy()
+	if !ok {
+		n.Logger().Warn(LOG_ROUTING, "no active neighbor to repair vset-path", "endpoint", e)
+		return
+	}
+	if !n.allowSetupReq(e) {
+		// 刚向 e 发过请求，由 retrySetupReqs 负责重发
+		return
+	}
+	n.Logger().Info(LOG_ROUTING, "repairing vset-path", "endpoint", e, "proxy", proxy)
+	n.SendSetupReq(n.ID, e, n.ID, proxy, proxy, vset)
+}
+
+// checkVsetPaths 周期性地修复 vset 与路由表之间的不一致，弥补丢失的 setup/teardown：
+// 本节点作为端点、但对端不在 vset 中的路径被拆除（通知对端重新协商）；
+// vset 中没有任何路径可达的节点被移除，并经由代理重新发起 setup_req；
+// 最后重发尚未得到结果的 setup_req。
+func (n *Node) checkVsetPaths() {
+	if !n.IsActive() {
+		return
+	}
+	me := n.ID
+
+	for _, path := range n.RoutingTable.getOwnPaths() {
+		other := path.Eb
+		if other == me {
+			other = path.Ea
+		}
+		if !n.VsetManager.Contains(other) {
+			n.Logger().Info(LOG_ROUTING, "path is not backed by the vset, tearing it down", "pid", path.PathId, "endpoint", other)
+			n.RoutingTable.TearDownPath(path.PathId, path.Ea, 0)
+		}
+	}
+
+	for _, id := range n.VsetManager.GetAll() {
+		if !n.RoutingTable.HasPathTo(id) {
+			n.Logger().Info(LOG_VSET, "vset neighbor has no vset-path", "peer", id)
+			n.endpointLost(id, nil)
+		}
+	}
+
+	n.retrySetupReqs()
+}
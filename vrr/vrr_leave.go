@@ -0,0 +1,51 @@
+package vrr
+
+/*
+主动离开：
+    for each (id ∈ vset) TearDownPathTo(id)          // teardown 携带 vset，对端互相建立路径
+    for each (<ea, eb, na, nb, pid> ∈ rt) TearDownPath(<pid, ea>, me)  // 经过本节点的路径，两端经由代理修复
+    Send <hello, 空的 pset 列表, 无候选者>            // 已链接的邻居立即把本节点标记为失败
+    Unregister(me)
+与崩溃相比，邻居不必等待 2*VRR_FAIL_TIMEOUT 个 HELLO 周期才发现本节点消失，
+失效的 vset-path 在离开时就被拆除。
+*/
+
+// NodeUnregisterer 是可以注销节点的网络，Leave 在最后一步调用它
+type NodeUnregisterer interface {
+	UnregisterNode(nodeID uint32)
+}
+
+// Leave 让节点主动离开网络：拆除所有 vset-path 并把本节点的 vset 交给对端，
+// 在最后一个 HELLO 中宣告离开，然后停止节点、清空状态，网络实现 NodeUnregisterer 时从网络注销。
+// 节点已经停止时不做任何事。离开后可以像重启一样重新加入子网并 Start。
+func (n *Node) Leave() {
+	if n.stopped() {
+		return
+	}
+	// 先停止消息处理，拆除路径的过程中不再接受新的路径
+	n.Stop()
+
+	vset := n.VsetManager.GetAll()
+	n.Logger().Info(LOG_NODE, "leaving the network", "vset", vset)
+
+	// 1. 以本节点为端点的路径：teardown 携带本节点的 vset，对端据此与其他虚拟邻居建立路径
+	for _, id := range vset {
+		n.RoutingTable.TearDownPathTo(id)
+	}
+	// 2. 其余路径(本节点是中间节点或端点已不在 vset 中)：按本节点失败拆除，teardown 不携带 vset，
+	//    端点经由代理重新建立路径
+	for _, route := range n.RoutingTable.Routes() {
+		n.RoutingTable.TearDownPath(route.PathId, route.Ea, n.ID)
+	}
+
+	// 3. 清空状态后发送最后一个 HELLO：不列出任何邻居，已链接的邻居按状态转移表
+	//    (linked + missing -> failed) 立即把本节点标记为失败。选举模式下不通告候选者
+	n.Reset()
+	n.sendHello(true)
+
+	// 4. 从网络注销
+	if u, ok := n.Network.(NodeUnregisterer); ok {
+		u.UnregisterNode(n.ID)
+	}
+	n.Logger().Info(LOG_NODE, "left the network")
+}
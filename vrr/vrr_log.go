@@ -0,0 +1,203 @@
+package vrr
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 日志子系统，每个子系统可以单独设置级别
+const (
+	LOG_NODE    = "node"    // 节点启动/停止、激活、自举选举、环合并
+	LOG_HELLO   = "hello"   // 每条 HELLO 的接收和处理，只有 Debug 级别
+	LOG_PSET    = "pset"    // 物理邻居的加入、状态变化、失败和删除
+	LOG_VSET    = "vset"    // 虚拟邻居的加入、挤出和移除
+	LOG_ROUTING = "routing" // setup/teardown、路由表变化、数据转发
+	LOG_NETWORK = "network" // 网络层的投递、丢包和节点注册
+)
+
+// LogSubsystems 是所有日志子系统的名称
+var LogSubsystems = []string{LOG_NODE, LOG_HELLO, LOG_PSET, LOG_VSET, LOG_ROUTING, LOG_NETWORK}
+
+// LogLevels 按子系统设置日志级别，未单独设置的子系统使用默认级别。可在运行时并发修改
+type LogLevels struct {
+	lock   sync.RWMutex
+	def    slog.Level
+	levels map[string]slog.Level
+}
+
+// NewLogLevels 创建所有子系统都使用级别 def 的设置
+func NewLogLevels(def slog.Level) *LogLevels {
+	return &LogLevels{def: def, levels: make(map[string]slog.Level)}
+}
+
+// ParseLogLevels 解析形如 "info,hello=debug,routing=warn" 的级别设置：
+// 不带子系统的项设置默认级别，其余项设置对应子系统的级别
+func ParseLogLevels(spec string) (*LogLevels, error) {
+	levels := NewLogLevels(slog.LevelInfo)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		subsystem, name, found := strings.Cut(item, "=")
+		if !found {
+			subsystem, name = "", item
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			return nil, fmt.Errorf("vrr: log level %q: %w", item, err)
+		}
+		if subsystem == "" {
+			levels.SetDefault(level)
+			continue
+		}
+		if !containsSubsystem(subsystem) {
+			return nil, fmt.Errorf("vrr: unknown log subsystem %q", subsystem)
+		}
+		levels.Set(subsystem, level)
+	}
+	return levels, nil
+}
+
+func containsSubsystem(name string) bool {
+	for _, s := range LogSubsystems {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDefault 设置未单独设置的子系统的级别
+func (l *LogLevels) SetDefault(level slog.Level) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.def = level
+}
+
+// Set 设置子系统的级别
+func (l *LogLevels) Set(subsystem string, level slog.Level) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.levels[subsystem] = level
+}
+
+// Level 返回子系统的级别
+func (l *LogLevels) Level(subsystem string) slog.Level {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if level, ok := l.levels[subsystem]; ok {
+		return level
+	}
+	return l.def
+}
+
+// Enabled 判断子系统是否输出 level 级别的日志
+func (l *LogLevels) Enabled(subsystem string, level slog.Level) bool {
+	return level >= l.Level(subsystem)
+}
+
+// String 返回可以被 ParseLogLevels 解析的字符串表示形式
+func (l *LogLevels) String() string {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	items := []string{strings.ToLower(l.def.String())}
+	names := make([]string, 0, len(l.levels))
+	for name := range l.levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		items = append(items, name+"="+strings.ToLower(l.levels[name].String()))
+	}
+	return strings.Join(items, ",")
+}
+
+// Logger 是按子系统过滤级别的结构化日志。每条日志带有 subsystem 属性，
+// 节点的 Logger 还带有 node 属性；消息类型和路径ID分别以 type 和 pid 属性记录
+type Logger struct {
+	base   *slog.Logger // nil 表示记录时的 slog.Default()
+	levels *LogLevels
+	attrs  []any // 附加到每条日志的属性
+}
+
+// defaultLogger 是没有设置日志的节点和网络共用的日志
+var defaultLogger = NewLogger(nil, nil)
+
+// DefaultLogger 返回默认日志，它输出到 slog.Default()，修改它的级别设置会影响所有使用默认日志的节点和网络
+func DefaultLogger() *Logger {
+	return defaultLogger
+}
+
+// NewLogger 创建日志，base 为 nil 时使用 slog.Default()，levels 为 nil 时所有子系统使用 Info 级别。
+// base 的 Handler 自身的级别同样生效，需要输出 Debug 日志时应使用允许 Debug 级别的 Handler
+func NewLogger(base *slog.Logger, levels *LogLevels) *Logger {
+	if levels == nil {
+		levels = NewLogLevels(slog.LevelInfo)
+	}
+	return &Logger{base: base, levels: levels}
+}
+
+// With 返回带有额外属性的日志，共享同一份级别设置
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{base: l.base, levels: l.levels, attrs: append(append([]any(nil), l.attrs...), args...)}
+}
+
+// Levels 返回日志的级别设置，修改后立即生效
+func (l *Logger) Levels() *LogLevels {
+	return l.levels
+}
+
+// Enabled 判断子系统是否输出 level 级别的日志
+func (l *Logger) Enabled(subsystem string, level slog.Level) bool {
+	return l != nil && l.levels.Enabled(subsystem, level)
+}
+
+// Log 以 level 级别记录子系统的一条日志，args 为交替的键和值
+func (l *Logger) Log(subsystem string, level slog.Level, msg string, args ...any) {
+	if !l.Enabled(subsystem, level) {
+		return
+	}
+	base := l.base
+	if base == nil {
+		base = slog.Default()
+	}
+	attrs := make([]any, 0, 2+len(l.attrs)+len(args))
+	attrs = append(attrs, "subsystem", subsystem)
+	attrs = append(attrs, l.attrs...)
+	base.Log(context.Background(), level, msg, append(attrs, args...)...)
+}
+
+// Debug 记录子系统的一条 Debug 日志
+func (l *Logger) Debug(subsystem, msg string, args ...any) {
+	l.Log(subsystem, slog.LevelDebug, msg, args...)
+}
+
+// Info 记录子系统的一条 Info 日志
+func (l *Logger) Info(subsystem, msg string, args ...any) {
+	l.Log(subsystem, slog.LevelInfo, msg, args...)
+}
+
+// Warn 记录子系统的一条 Warn 日志
+func (l *Logger) Warn(subsystem, msg string, args ...any) {
+	l.Log(subsystem, slog.LevelWarn, msg, args...)
+}
+
+// SetLogger 设置节点的日志，节点ID作为 node 属性附加到每条日志。可以在节点运行时调用
+func (n *Node) SetLogger(logger *Logger) {
+	n.hookLock.Lock()
+	defer n.hookLock.Unlock()
+	n.config.Logger = logger
+	n.logger = logger.With("node", n.ID)
+}
+
+// Logger 返回节点的日志
+func (n *Node) Logger() *Logger {
+	n.hookLock.RLock()
+	defer n.hookLock.RUnlock()
+	return n.logger
+}
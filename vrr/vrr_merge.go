@@ -0,0 +1,59 @@
+package vrr
+
+// 虚拟环合并
+//
+// 每个活跃节点维护一个环标识 RingID，自举节点以自己的ID作为环标识，
+// 通过代理加入的节点从 setup 消息中继承发起者的环标识；如果发起者当时也还没有环标识，
+// 则从与自己共享 vset-path 的活跃物理邻居的 HELLO 中继承（共享路径说明双方在同一个环中）。
+// 两个独立自举的虚拟环在物理上连通后，边界节点会在 HELLO 中看到不同的环标识：
+// 标识较大的一侧采用较小的标识，并经由该邻居作为代理向自己的ID发送 setup_req，
+// 与新节点加入的过程相同，从而让两侧的节点互相学习 vset 并收敛为一个环。
+// 新的环标识随 HELLO 在原环中逐跳扩散，原环中的每个节点都会这样重新加入一次。
+
+// bootstrapRing 在节点自举时以自己的ID作为环标识，调用者持有 n.lock
+func (n *Node) bootstrapRing() {
+	if n.RingID == 0 {
+		n.RingID = n.ID
+	}
+}
+
+// checkRingMerge 处理来自活跃且已链接物理邻居的环标识
+func (n *Node) checkRingMerge(neighbor uint32, ringID uint32) {
+	if ringID == 0 {
+		return
+	}
+	// 路由表有自己的锁，在持有 n.lock 之前查询
+	shared := n.RoutingTable.HasNextHop(neighbor)
+
+	// 环标识的检查与修改在同一次持锁中完成，避免与 receiveSetup 并发采用不同的环标识
+	n.lock.Lock()
+	local := n.RingID
+	if !n.Active || ringID == local {
+		n.lock.Unlock()
+		return
+	}
+
+	if local == 0 {
+		if shared {
+			n.RingID = ringID
+		}
+		n.lock.Unlock()
+		if shared {
+			n.Logger().Info(LOG_NODE, "adopted ring from neighbor", "ring", ringID, "peer", neighbor)
+		}
+		return
+	}
+
+	// 标识较小的环胜出，由较大一侧发起合并
+	if ringID > local {
+		n.lock.Unlock()
+		return
+	}
+	n.RingID = ringID
+	n.lock.Unlock()
+
+	n.Logger().Info(LOG_NODE, "ring partition detected, merging",
+		"peer", neighbor, "ring", ringID, "local_ring", local, "proxy", neighbor)
+	vset := n.VsetManager.GetAll()
+	n.SendSetupReq(n.ID, n.ID, n.ID, neighbor, neighbor, vset)
+}
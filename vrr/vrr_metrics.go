@@ -0,0 +1,389 @@
+package vrr
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 指标类型
+const (
+	METRIC_COUNTER = "counter"
+	METRIC_GAUGE   = "gauge"
+)
+
+// 消息被丢弃的原因。网络层的丢弃计入发送节点，节点层的丢弃计入丢弃它的节点
+const (
+	DROP_LOSS           = "loss"           // 链路丢包
+	DROP_INBOX_FULL     = "inbox_full"     // 接收节点的 inbox 已满
+	DROP_UNKNOWN_TARGET = "unknown_target" // 下一跳不存在，或广播的发送者不在任何子网中
+	DROP_SEND_ERROR     = "send_error"     // 编码或发送失败，或收到无法解码的数据报
+	DROP_NO_ROUTE       = "no_route"       // 节点没有到目的地的下一跳
+	DROP_INVALID        = "invalid"        // 负载类型或内容不合法
+	DROP_RECV_FULL      = "recv_full"      // 应用来不及读取 RecvChan
+)
+
+// setup 失败的原因
+const (
+	SETUP_REJECTED = "rejected" // 目标无法把发起者加入 vset
+	SETUP_LOOP     = "loop"     // 路由表中已有相同 <pid, ea>，setup 出现环路
+	SETUP_NO_ROUTE = "no_route" // 没有通往代理的下一跳
+)
+
+// Label 是指标样本的一个标签
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample 是指标的一个样本
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// MetricFamily 是同名指标的全部样本
+type MetricFamily struct {
+	Name    string
+	Help    string
+	Type    string // METRIC_COUNTER 或 METRIC_GAUGE
+	Samples []Sample
+}
+
+// Sum 返回带有全部给定标签的样本之和，不给标签时为全部样本之和
+func (f MetricFamily) Sum(labels ...Label) float64 {
+	var sum float64
+	for _, s := range f.Samples {
+		if hasLabels(s.Labels, labels) {
+			sum += s.Value
+		}
+	}
+	return sum
+}
+
+func hasLabels(have, want []Label) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Collector 在抓取时提供指标，节点和网络都实现了它
+type Collector interface {
+	Collect() []MetricFamily
+}
+
+// Registry 汇总一组 Collector 的指标。不同 Collector 的同名指标合并为一个 MetricFamily，
+// 它本身是输出 Prometheus 文本格式的 http.Handler
+type Registry struct {
+	lock       sync.RWMutex
+	collectors []Collector
+}
+
+// NewRegistry 创建空的指标注册表
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register 注册 Collector，重复注册被忽略
+func (r *Registry) Register(c Collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, existing := range r.collectors {
+		if existing == c {
+			return
+		}
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// Unregister 注销 Collector
+func (r *Registry) Unregister(c Collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, existing := range r.collectors {
+		if existing == c {
+			r.collectors = append(r.collectors[:i], r.collectors[i+1:]...)
+			return
+		}
+	}
+}
+
+// Gather 抓取所有 Collector 的指标，按名称排序，样本按标签排序
+func (r *Registry) Gather() []MetricFamily {
+	r.lock.RLock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.lock.RUnlock()
+
+	byName := make(map[string]*MetricFamily)
+	var names []string
+	for _, c := range collectors {
+		for _, f := range c.Collect() {
+			merged, ok := byName[f.Name]
+			if !ok {
+				merged = &MetricFamily{Name: f.Name, Help: f.Help, Type: f.Type}
+				byName[f.Name] = merged
+				names = append(names, f.Name)
+			}
+			merged.Samples = append(merged.Samples, f.Samples...)
+		}
+	}
+
+	sort.Strings(names)
+	families := make([]MetricFamily, 0, len(names))
+	for _, name := range names {
+		f := byName[name]
+		sort.SliceStable(f.Samples, func(i, j int) bool {
+			return labelsLess(f.Samples[i].Labels, f.Samples[j].Labels)
+		})
+		families = append(families, *f)
+	}
+	return families
+}
+
+// Family 返回名为 name 的指标，不存在时 ok 为 false
+func (r *Registry) Family(name string) (MetricFamily, bool) {
+	for _, f := range r.Gather() {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return MetricFamily{}, false
+}
+
+// Sum 返回指标 name 中带有全部给定标签的样本之和
+func (r *Registry) Sum(name string, labels ...Label) float64 {
+	f, _ := r.Family(name)
+	return f.Sum(labels...)
+}
+
+// labelsLess 按标签值比较，数字ID按数值比较
+func labelsLess(a, b []Label) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].Value == b[i].Value {
+			continue
+		}
+		x, errA := strconv.ParseUint(a[i].Value, 10, 64)
+		y, errB := strconv.ParseUint(b[i].Value, 10, 64)
+		if errA == nil && errB == nil {
+			return x < y
+		}
+		return a[i].Value < b[i].Value
+	}
+	return len(a) < len(b)
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出指标
+func WritePrometheus(w io.Writer, families []MetricFamily) error {
+	var b strings.Builder
+	for _, f := range families {
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.Name, f.Help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			b.WriteString(f.Name)
+			if len(s.Labels) > 0 {
+				b.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, "%s=\"%s\"", l.Name, escapeLabelValue(l.Value))
+				}
+				b.WriteByte('}')
+			}
+			b.WriteByte(' ')
+			b.WriteString(strconv.FormatFloat(s.Value, 'g', -1, 64))
+			b.WriteByte('\n')
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// ServeHTTP 以 Prometheus 文本格式输出当前的指标
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := WritePrometheus(w, r.Gather()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CounterVec 是按一组标签分组的计数器，可以并发使用
+type CounterVec struct {
+	lock   sync.Mutex
+	labels []string
+	keys   []string            // 按首次出现顺序记录的分组
+	values map[string][]string // 分组对应的标签值
+	counts map[string]uint64
+}
+
+// NewCounterVec 创建按标签 labels 分组的计数器
+func NewCounterVec(labels ...string) *CounterVec {
+	return &CounterVec{
+		labels: labels,
+		values: make(map[string][]string),
+		counts: make(map[string]uint64),
+	}
+}
+
+// Add 将标签值为 values 的计数器加 delta，values 与创建时的标签一一对应
+func (c *CounterVec) Add(delta uint64, values ...string) {
+	key := strings.Join(values, "\xff")
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.values[key]; !ok {
+		c.keys = append(c.keys, key)
+		c.values[key] = append([]string(nil), values...)
+	}
+	c.counts[key] += delta
+}
+
+// Inc 将标签值为 values 的计数器加一
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Get 返回标签值为 values 的计数
+func (c *CounterVec) Get(values ...string) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.counts[strings.Join(values, "\xff")]
+}
+
+// Total 返回所有分组的计数之和
+func (c *CounterVec) Total() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var total uint64
+	for _, count := range c.counts {
+		total += count
+	}
+	return total
+}
+
+// Samples 返回每个分组的样本，prefix 中的标签加在分组标签之前
+func (c *CounterVec) Samples(prefix ...Label) []Sample {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	samples := make([]Sample, 0, len(c.keys))
+	for _, key := range c.keys {
+		labels := append([]Label(nil), prefix...)
+		for i, value := range c.values[key] {
+			labels = append(labels, Label{c.labels[i], value})
+		}
+		samples = append(samples, Sample{Labels: labels, Value: float64(c.counts[key])})
+	}
+	return samples
+}
+
+// MessageTypeLabel 返回消息类型在指标中的标签值，例如 "hello"、"setup_req"
+func MessageTypeLabel(msgType uint8) string {
+	return strings.ToLower(strings.TrimPrefix(GetMessageTypeString(msgType), "VRR_"))
+}
+
+// nodeMetrics 是节点的计数器
+type nodeMetrics struct {
+	sent      *CounterVec // type
+	received  *CounterVec // type
+	forwarded *CounterVec // type
+	dropped   *CounterVec // type, reason
+	setupReqs *CounterVec // 无标签
+	setupOK   *CounterVec // 无标签
+	setupFail *CounterVec // reason
+}
+
+func newNodeMetrics() *nodeMetrics {
+	return &nodeMetrics{
+		sent:      NewCounterVec("type"),
+		received:  NewCounterVec("type"),
+		forwarded: NewCounterVec("type"),
+		dropped:   NewCounterVec("type", "reason"),
+		setupReqs: NewCounterVec(),
+		setupOK:   NewCounterVec(),
+		setupFail: NewCounterVec("reason"),
+	}
+}
+
+// send 统计并发送消息。逻辑发起者不是本节点的消息同时计为转发
+func (n *Node) send(msg Message) {
+	msgType := MessageTypeLabel(msg.Type)
+	n.metrics.sent.Inc(msgType)
+	if msg.Src != n.ID {
+		n.metrics.forwarded.Inc(msgType)
+	}
+	n.Network.Send(msg)
+}
+
+// countDrop 记录本节点因 reason 丢弃了一条消息
+func (n *Node) countDrop(msg Message, reason string) {
+	n.metrics.dropped.Inc(MessageTypeLabel(msg.Type), reason)
+	n.trace(TRACE_DROP, msg, 0, reason)
+}
+
+// countSetupFail 记录本节点上的一次 setup 失败
+func (n *Node) countSetupFail(reason string) {
+	n.metrics.setupFail.Inc(reason)
+}
+
+// countByStatus 返回各状态的物理邻居个数
+func (pm *PsetManager) countByStatus() map[uint32]int {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+	counts := make(map[uint32]int)
+	for e := pm.psetList.Front(); e != nil; e = e.Next() {
+		counts[e.Value.(*PsetNode).Status]++
+	}
+	return counts
+}
+
+// Collect 返回节点的计数器以及 vset 大小、路由条目数、各状态的物理邻居数和激活状态
+func (n *Node) Collect() []MetricFamily {
+	node := Label{"node", strconv.FormatUint(uint64(n.ID), 10)}
+	m := n.metrics
+
+	active := 0.0
+	if n.IsActive() {
+		active = 1
+	}
+	psetCounts := n.PsetManager.countByStatus()
+	pset := make([]Sample, 0, PSET_UNKNOWN)
+	for status := uint32(PSET_LINKED); status < PSET_UNKNOWN; status++ {
+		pset = append(pset, Sample{Labels: []Label{node, {"state", psetStates[status]}}, Value: float64(psetCounts[status])})
+	}
+	gauge := func(v int) []Sample {
+		return []Sample{{Labels: []Label{node}, Value: float64(v)}}
+	}
+
+	return []MetricFamily{
+		{"vrr_messages_sent_total", "Messages sent by the node, including forwarded ones.", METRIC_COUNTER, m.sent.Samples(node)},
+		{"vrr_messages_received_total", "Messages received by the node.", METRIC_COUNTER, m.received.Samples(node)},
+		{"vrr_messages_forwarded_total", "Messages sent by the node on behalf of another source.", METRIC_COUNTER, m.forwarded.Samples(node)},
+		{"vrr_messages_dropped_total", "Messages dropped, by message type and reason.", METRIC_COUNTER, m.dropped.Samples(node)},
+		{"vrr_setup_requests_total", "setup_req messages originated by the node.", METRIC_COUNTER, m.setupReqs.Samples(node)},
+		{"vrr_setup_success_total", "vset-paths established with the node as destination.", METRIC_COUNTER, m.setupOK.Samples(node)},
+		{"vrr_setup_failures_total", "Setups that failed at the node, by reason.", METRIC_COUNTER, m.setupFail.Samples(node)},
+		{"vrr_vset_size", "Number of virtual neighbors.", METRIC_GAUGE, gauge(len(n.VsetManager.GetAll()))},
+		{"vrr_routes", "Number of routing table entries.", METRIC_GAUGE, gauge(len(n.RoutingTable.Routes()))},
+		{"vrr_pset_neighbors", "Number of physical neighbors, by link state.", METRIC_GAUGE, pset},
+		{"vrr_active", "Whether the node is active (1) or not (0).", METRIC_GAUGE, []Sample{{Labels: []Label{node}, Value: active}}},
+	}
+}
@@ -1,8 +1,8 @@
 package vrr
 
 import (
-	"log"
 	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 	// "github.com/tangwan16/vrr-go/Network"
@@ -10,6 +10,18 @@ import (
 
 // Start 启动节点的消息处理循环，与广播周期性HELLO消息
 func (n *Node) Start() {
+	// 停止后重新启动：重建停止信号
+	if n.stopped() {
+		n.StopChan = make(chan struct{})
+		n.stopOnce = sync.Once{}
+	}
+
+	// 虚拟时间模式下不启动 goroutine，消息和 HELLO 都由时钟调度执行
+	if IsVirtual(n.clock) {
+		n.startVirtual()
+		return
+	}
+
 	n.wg.Add(2) //启动两个goroutine
 
 	// 启动一个 goroutine 来处理传入的消息，sendSetupReq,Setup
@@ -32,29 +44,70 @@ func (n *Node) Start() {
 		/* 		helloTicker := time.NewTicker(300 * time.Millisecond) // 每0.3秒向HelloTicker对象内部通道C发送时间信号tick
 		   		defer helloTicker.Stop() */
 		// --- 使用带有 Jitter 的 Timer 替代固定的 Ticker ---
-		const baseInterval = 500 * time.Millisecond
-		const jitter = 300 * time.Millisecond // 随机抖动范围
 		// 计算一个随机的下一次触发时间
-		randomDuration := baseInterval + time.Duration(rand.Int63n(int64(jitter)*2)-int64(jitter))
-		timer := time.NewTimer(randomDuration)
+		timer := time.NewTimer(n.helloInterval())
 		defer timer.Stop()
 
 		for {
 			select {
 			case <-timer.C:
 				n.DetectFailures()
+				n.checkVsetPaths()
+				n.syncPaths()
 				n.ActiveTimeout()
 				n.SendHello()
 				// 重置计时器以进行下一次触发
-				randomDuration = baseInterval + time.Duration(rand.Int63n(int64(jitter)*2)-int64(jitter))
-				timer.Reset(randomDuration)
+				timer.Reset(n.helloInterval())
 			case <-n.StopChan:
 				return
 			}
 		}
 	}()
 
-	log.Printf("Node %d: Started message processing and periodic HELLO sender", n.ID)
+	n.Logger().Info(LOG_NODE, "started message processing and periodic HELLO sender")
+}
+
+// startVirtual 在虚拟时钟上调度周期性 HELLO，消息由网络通过 Receive 同步投递
+func (n *Node) startVirtual() {
+	var tick func()
+	tick = func() {
+		if n.stopped() {
+			return
+		}
+		n.DetectFailures()
+		n.checkVsetPaths()
+		n.syncPaths()
+		n.ActiveTimeout()
+		n.SendHello()
+		n.helloTimer = n.clock.AfterFunc(n.helloInterval(), tick)
+	}
+	n.helloTimer = n.clock.AfterFunc(n.helloInterval(), tick)
+
+	n.Logger().Info(LOG_NODE, "started periodic HELLO sender on virtual clock")
+}
+
+// helloInterval 返回带随机抖动的下一次 HELLO 间隔
+func (n *Node) helloInterval() time.Duration {
+	jitter := int64(n.config.HelloJitter) // 随机抖动范围，负数表示不加抖动
+	if jitter <= 0 {
+		return n.config.HelloInterval
+	}
+	return n.config.HelloInterval + time.Duration(n.randInt63n(jitter*2)-jitter)
+}
+
+// stopped 判断节点是否已经停止
+func (n *Node) stopped() bool {
+	select {
+	case <-n.StopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stopped 判断节点是否已经被 Stop 停止且尚未重新启动
+func (n *Node) Stopped() bool {
+	return n.stopped()
 }
 
 // Stop 停止节点
@@ -62,30 +115,47 @@ func (n *Node) Stop() {
 	n.stopOnce.Do(func() {
 		// 1. 发送停止信号
 		close(n.StopChan)
+		if n.helloTimer != nil {
+			n.helloTimer.Stop()
+		}
 		// 2. 等待所有 goroutine 真正退出
 		n.wg.Wait()
 		// 3. 在所有任务都结束后，打印统一的日志
-		log.Printf("Node %d: Closed message processing and periodic HELLO sender", n.ID)
+		n.Logger().Info(LOG_NODE, "stopped message processing and periodic HELLO sender")
 	})
 }
 
-// NewNode 创建节点
-func NewNode(id uint32, Network Networker) *Node {
-	n := &Node{
-		ID:        id,
-		InboxChan: make(chan Message, 256),
-		StopChan:  make(chan struct{}),
-		Network:   Network,
-		Active:    false,
-	}
+// Reset 清空节点的协议状态（pset、vset、路由表、活跃状态和环标识），模拟节点重启后丢失所有状态。
+// 应在节点停止时调用；之后可以再次 Start。
+func (n *Node) Reset() {
+	n.PsetManager.reset()
+	n.VsetManager.reset()
+	n.RoutingTable.reset()
+	n.PsetStateManager.Update()
 
-	// 为这个新节点创建一套独立的管理器
-	n.PsetManager = NewPsetManager(n)
-	n.VsetManager = NewVsetManager(n)
-	n.RoutingTable = NewRoutingTableManager(n)
-	n.PsetStateManager = NewPsetStateManager(n)
-	// fmt.Printf("psetManager、VsetManager、psetStateManager、routingTable created for node %d done\n", n.ID)
+	n.setupLock.Lock()
+	n.setupSent = make(map[uint32]*setupAttempt)
+	n.setupLock.Unlock()
 
+	n.lock.Lock()
+	n.Active = false
+	n.Timeout = 0
+	n.RingID = 0
+	n.lock.Unlock()
+
+	// 丢弃停止期间积压的消息
+	for {
+		select {
+		case <-n.InboxChan:
+		default:
+			return
+		}
+	}
+}
+
+// NewNode 使用默认参数创建节点
+func NewNode(id uint32, Network Networker) *Node {
+	n, _ := NewNodeWithConfig(id, Network, DefaultConfig())
 	return n
 }
 
@@ -96,27 +166,84 @@ func (n *Node) SetActive(active bool) {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 	n.Active = active
+	if active {
+		n.bootstrapRing()
+	}
+}
+
+// IsActive 返回节点是否活跃，可以在节点运行时从其他 goroutine 调用
+func (n *Node) IsActive() bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.Active
+}
+
+// GetRingID 返回节点所在虚拟环的标识，0 表示未知；可以在节点运行时从其他 goroutine 调用
+func (n *Node) GetRingID() uint32 {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.RingID
+}
+
+// adoptRingID 在节点还没有环标识时采用给定的环标识，返回是否采用
+func (n *Node) adoptRingID(ringID uint32) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if ringID == 0 || n.RingID != 0 {
+		return false
+	}
+	n.RingID = ringID
+	return true
+}
+
+// SetClock 设置节点使用的时钟，需要在 Start 之前调用。
+// 使用 VirtualClock 时节点运行在离散事件虚拟时间下，网络应使用同一个时钟。
+func (n *Node) SetClock(clock Clock) {
+	n.clock = clock
+	n.config.Clock = clock
+}
+
+// Clock 返回节点使用的时钟
+func (n *Node) Clock() Clock {
+	return n.clock
+}
+
+// SetSeed 用固定种子重置节点的随机数生成器（HELLO 抖动、路径ID、代理选择）
+func (n *Node) SetSeed(seed int64) {
+	n.rngLock.Lock()
+	defer n.rngLock.Unlock()
+	n.rng = rand.New(rand.NewSource(seed))
+	n.config.Seed = seed
+}
+
+// Receive 同步处理一条消息。虚拟时间模式下由网络在消息到达时直接调用；
+// 节点停止后到达的消息被丢弃。
+func (n *Node) Receive(msg Message) {
+	if n.stopped() {
+		return
+	}
+	n.rcvMessage(msg)
 }
 
 // detectFailures 检测失败的邻居节点
 // to do:为什么上来直接增加失败计数？
 func (n *Node) DetectFailures() {
-	for e := n.PsetManager.psetList.Front(); e != nil; e = e.Next() {
-		pNode := e.Value.(*PsetNode)
-
+	// 遍历 pset 的快照：PsetStateManager 的工作 goroutine 可能同时在修改 pset
+	for _, pNode := range n.PsetManager.GetAll() {
 		// 定期增加失败计数，只有收到消息，才会重置失败计数
 		count, _ := n.IncFailCount(pNode.NodeId)
 
 		// 检查是否需要标记为失败
-		if count >= VRR_FAIL_TIMEOUT && pNode.Status != PSET_FAILED {
-			log.Printf("Node %d: Marking failed node: %d", n.ID, pNode.NodeId)
+		if count >= int32(n.config.FailTimeout) && pNode.Status != PSET_FAILED {
+			n.Logger().Info(LOG_PSET, "marking neighbor failed", "peer", pNode.NodeId)
 			n.PsetManager.Update(pNode.NodeId, PSET_FAILED, pNode.Active)
 			n.PsetStateManager.Update()
+			n.LinkFailed(pNode.NodeId)
 		}
 
 		// 检查是否需要删除节点
-		if count >= 2*VRR_FAIL_TIMEOUT {
-			log.Printf("Node %d: Deleting failed node: %d", n.ID, pNode.NodeId)
+		if count >= int32(2*n.config.FailTimeout) {
+			n.Logger().Info(LOG_PSET, "deleting failed neighbor", "peer", pNode.NodeId)
 			n.PsetManager.Remove(pNode.NodeId)
 			// n.psetStateManager.Update(n.psetManager)
 		}
@@ -125,23 +252,41 @@ func (n *Node) DetectFailures() {
 
 // activeTimeout 处理活跃状态超时（每个时间单位调用一次）
 func (n *Node) ActiveTimeout() {
+	n.lock.Lock()
 	// 如果已经活跃，直接返回
 	if n.Active {
+		n.lock.Unlock()
 		return
 	}
 
 	// 超时计数器递增
 	n.Timeout++
-	// log.Printf("Node %d: Timeout: (%d)", n.ID, n.Timeout)
+	ticks := n.Timeout
+	n.lock.Unlock()
 
 	// 达到超时阈值时激活节点
-	if n.Timeout >= VRR_ACTIVE_TIMEOUT {
-		n.Active = true
-		log.Printf("Node %d: Activated after Timeout (%d ticks)", n.ID, n.Timeout)
-		// 自己自举成功后，这会抢占其他可能即将超时的节点，并引导它们加入自己的网络。
-		n.SendHello()
+	if ticks < n.config.ActiveTimeout {
+		return
+	}
+	// 选举模式下，只有当选的候选者才能自举，其他节点等待通过代理加入
+	// 选举需要读取 pset，在持有 n.lock 之前完成
+	if n.Bootstrap != BOOTSTRAP_TIMEOUT && !n.electedToBootstrap() {
+		return
+	}
 
+	n.lock.Lock()
+	// 选举期间可能已经通过代理加入了网络，或者收到消息重置了计数
+	if n.Active || n.Timeout < n.config.ActiveTimeout {
+		n.lock.Unlock()
+		return
 	}
+	n.Active = true
+	n.bootstrapRing()
+	n.lock.Unlock()
+
+	n.Logger().Info(LOG_NODE, "activated after timeout", "ticks", ticks)
+	// 自己自举成功后，这会抢占其他可能即将超时的节点，并引导它们加入自己的网络。
+	n.SendHello()
 }
 
 // ResetActiveTimeout 重置节点活跃超时
@@ -0,0 +1,59 @@
+package vrr
+
+import "sort"
+
+/*
+路由核对：
+    每 PathSync 个 HELLO 周期
+        for each (n ∈ pset, n 已链接)
+            Send <path_sync, {<pid, ea> ∈ rt | n ∈ {na, nb}}> to n
+    Receive (<path_sync, paths>, sender)
+        for each (<pid, ea> ∈ paths)
+            if (<pid, ea> ∉ rt ∨ sender ∉ {na, nb})
+                Send <teardown, <pid, ea>, null> to sender
+teardown 因丢包或链路中断而丢失、或者邻居重启后清空了路由表时，路径只剩下一端的半条。
+checkVsetPaths 只看本节点自己的路由表，发现不了下一跳已经没有对应的条目；
+邻居回复的 teardown 按正常流程拆除半条路径并传向另一侧，端点经由代理重新建立路径。
+*/
+
+// syncPaths 每 PathSync 个 HELLO 周期调用一次 SendPathSync，把经过各个已链接邻居的路径发给该邻居核对
+func (n *Node) syncPaths() {
+	n.pathSyncTicks++
+	if n.pathSyncTicks < n.config.PathSync {
+		return
+	}
+	n.pathSyncTicks = 0
+
+	byHop := n.RoutingTable.pathsToSync()
+	hops := make([]uint32, 0, len(byHop))
+	for hop := range byHop {
+		hops = append(hops, hop)
+	}
+	sort.Slice(hops, func(i, j int) bool { return hops[i] < hops[j] })
+
+	for _, hop := range hops {
+		if n.PsetManager.GetStatus(hop) != PSET_LINKED {
+			// 链路失败时由 DetectFailures 拆除经过它的路径
+			continue
+		}
+		paths := byHop[hop]
+		for len(paths) > 0 {
+			chunk := paths[:min(len(paths), VRR_MAX_SYNC_PATHS)]
+			paths = paths[len(chunk):]
+			n.SendPathSync(hop, chunk)
+		}
+	}
+}
+
+// receivePathSync 处理路由核对消息：对本节点没有、或者没有经过发送者的路径回复 teardown
+func (n *Node) receivePathSync(msg Message, payload *PathSyncPayload) {
+	for _, path := range payload.Paths {
+		route, ok := n.RoutingTable.Lookup(path.Pid, path.Endpoint)
+		if ok && (route.Na == msg.Sender || route.Nb == msg.Sender) {
+			continue
+		}
+		n.Logger().Info(LOG_ROUTING, "neighbor routes a path that is not here, tearing it down",
+			"type", "VRR_PATH_SYNC", "pid", path.Pid, "ea", path.Endpoint, "peer", msg.Sender)
+		n.SendTeardown(path.Pid, path.Endpoint, nil, msg.Sender)
+	}
+}
@@ -3,11 +3,10 @@ package vrr
 import (
 	"container/list"
 	"fmt"
-	"log"
-	"math/rand"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // Physical Set Setup
@@ -16,6 +15,16 @@ type PsetNode struct {
 	Status    uint32
 	Active    bool
 	FailCount int32 //atomic
+
+	Candidate     uint32 // 邻居在 HELLO 中通告的自举候选者
+	CandidateHops uint8  // 邻居到该候选者的跳数
+	CandidateSeq  uint32 // 邻居转发的候选者序号
+}
+
+// candidateSeen 记录某个候选者的最新序号及其增长的时间
+type candidateSeen struct {
+	seq uint32
+	at  time.Time
 }
 
 // PSetManager 封装了单个节点的物理邻居集状态和操作逻辑。
@@ -23,6 +32,8 @@ type PsetManager struct {
 	ownerNode *Node        // 指向拥有此管理器的节点
 	lock      sync.RWMutex // 使用读写锁以优化性能
 	psetList  list.List    // 每个管理器实例都有自己的psetList
+
+	candidates map[uint32]candidateSeen // 邻居通告过的候选者的序号，判断候选者是否已经离开
 }
 
 // NewPPsetManager 是 PPsetManager 的构造函数。
@@ -30,9 +41,18 @@ func NewPsetManager(owner *Node) *PsetManager {
 	return &PsetManager{
 		ownerNode: owner,
 		// psetList 字段已经是 list.List 类型，它被零值初始化为一个可用的空列表。
+		candidates: make(map[uint32]candidateSeen),
 	}
 }
 
+// reset 清空物理邻居集
+func (pm *PsetManager) reset() {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	pm.psetList.Init()
+	pm.candidates = make(map[uint32]candidateSeen)
+}
+
 // Add  向物理邻居集中添加一个节点。
 func (pm *PsetManager) Add(nodeID uint32, status uint32, Active bool) bool {
 	pm.lock.Lock()
@@ -41,7 +61,7 @@ func (pm *PsetManager) Add(nodeID uint32, status uint32, Active bool) bool {
 	// 检查节点是否已存在
 	for e := pm.psetList.Front(); e != nil; e = e.Next() {
 		if e.Value.(*PsetNode).NodeId == nodeID {
-			log.Printf("Node %d: Neighbor Node %d already exists", pm.ownerNode.ID, nodeID)
+			pm.ownerNode.Logger().Debug(LOG_PSET, "neighbor already exists", "peer", nodeID)
 			return false // 节点已存在
 		}
 	}
@@ -56,7 +76,7 @@ func (pm *PsetManager) Add(nodeID uint32, status uint32, Active bool) bool {
 
 	// 添加到列表
 	pm.psetList.PushBack(newNode)
-	log.Printf("Node %d: Added neighbor Node %d", pm.ownerNode.ID, nodeID)
+	pm.ownerNode.Logger().Info(LOG_PSET, "neighbor added", "peer", nodeID)
 	return true
 }
 
@@ -70,13 +90,62 @@ func (pm *PsetManager) Update(nodeID uint32, status uint32, Active bool) bool {
 		if pNode.NodeId == nodeID {
 			pNode.Status = status
 			pNode.Active = Active
-			log.Printf("Node %d: PSet updated neighbor %d", pm.ownerNode.ID, nodeID)
+			pm.ownerNode.Logger().Debug(LOG_PSET, "neighbor updated", "peer", nodeID)
 			return true
 		}
 	}
 	return false
 }
 
+// SetCandidate 记录物理邻居通告的自举候选者，序号比已知的更新时刷新该候选者的时间。
+func (pm *PsetManager) SetCandidate(nodeID uint32, candidate uint32, hops uint8, seq uint32) bool {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	now := pm.ownerNode.clock.Now()
+	if candidate != 0 {
+		seen, ok := pm.candidates[candidate]
+		if !ok || int32(seq-seen.seq) > 0 {
+			pm.candidates[candidate] = candidateSeen{seq: seq, at: now}
+		}
+	}
+
+	found := false
+	for e := pm.psetList.Front(); e != nil; e = e.Next() {
+		pNode := e.Value.(*PsetNode)
+		if pNode.NodeId == nodeID {
+			pNode.Candidate = candidate
+			pNode.CandidateHops = hops
+			pNode.CandidateSeq = seq
+			found = true
+			break
+		}
+	}
+	pm.pruneCandidates(now)
+	return found
+}
+
+// pruneCandidates 删除已经过期、且没有物理邻居还在通告的候选者，调用者持有 pm.lock。
+// 还有邻居通告的过期候选者必须保留：删除后再收到旧的序号会被当作新的通告重新认可。
+func (pm *PsetManager) pruneCandidates(now time.Time) {
+	expiry := pm.ownerNode.candidateExpiry()
+	for id, seen := range pm.candidates {
+		if now.Sub(seen.at) <= expiry {
+			continue
+		}
+		advertised := false
+		for e := pm.psetList.Front(); e != nil; e = e.Next() {
+			if e.Value.(*PsetNode).Candidate == id {
+				advertised = true
+				break
+			}
+		}
+		if !advertised {
+			delete(pm.candidates, id)
+		}
+	}
+}
+
 // find 在物理邻居集中查找一个节点。
 func (pm *PsetManager) find(nodeID uint32) *PsetNode {
 	pm.lock.RLock()
@@ -105,6 +174,28 @@ func (pm *PsetManager) Contains(nodeID uint32) bool {
 	return false // 遍历完成未找到，返回 false
 }
 
+// GetAll 返回物理邻居集的快照（条目的副本），按加入顺序排列
+func (pm *PsetManager) GetAll() []PsetNode {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+
+	nodes := make([]PsetNode, 0, pm.psetList.Len())
+	for e := pm.psetList.Front(); e != nil; e = e.Next() {
+		tmp := e.Value.(*PsetNode)
+		// 逐字段复制：FailCount 会被原子地并发修改，不能整体拷贝结构体
+		nodes = append(nodes, PsetNode{
+			NodeId:        tmp.NodeId,
+			Status:        tmp.Status,
+			Active:        tmp.Active,
+			FailCount:     atomic.LoadInt32(&tmp.FailCount),
+			Candidate:     tmp.Candidate,
+			CandidateHops: tmp.CandidateHops,
+			CandidateSeq:  tmp.CandidateSeq,
+		})
+	}
+	return nodes
+}
+
 // GetActive 获取物理邻居集中一个节点的活跃状态。
 func (pm *PsetManager) GetActive(nodeID uint32) (bool, bool) {
 	pm.lock.RLock() // 使用读锁，因为这是只读操作
@@ -148,7 +239,7 @@ func (pm *PsetManager) Remove(nodeID uint32) bool {
 	for e := pm.psetList.Front(); e != nil; e = e.Next() {
 		if e.Value.(*PsetNode).NodeId == nodeID {
 			pm.psetList.Remove(e)
-			log.Printf("Node %d: PSet removed neighbor %d", pm.ownerNode.ID, nodeID)
+			pm.ownerNode.Logger().Info(LOG_PSET, "neighbor removed", "peer", nodeID)
 			return true
 		}
 	}
@@ -227,8 +318,7 @@ func (pm *PsetManager) GetProxy() (uint32, bool) {
 	}
 
 	// 从符合条件的节点中随机选择一个
-	// rand.Seed() 应该在程序启动时调用一次，而不是每次都调用
-	r := rand.Intn(len(activeNodes))
+	r := pm.ownerNode.randIntn(len(activeNodes))
 	proxy := activeNodes[r]
 
 	return proxy, true
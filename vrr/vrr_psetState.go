@@ -2,7 +2,6 @@ package vrr
 
 import (
 	"fmt"
-	"log"
 	"strings"
 	"sync"
 )
@@ -52,15 +51,20 @@ type PsetStateUpdate struct {
 	node   uint32
 	trans  int
 	active bool
+
+	ringID        uint32
+	candidate     uint32
+	candidateHops uint8
+	candidateSeq  uint32
 }
 
 // NewPsetStateManager 创建新的 PsetStateManager
 func NewPsetStateManager(owner *Node) *PsetStateManager {
 	psm := &PsetStateManager{
 		ownerNode:           owner,
-		LinkActive:          make([]uint32, 0, VRR_PSET_SIZE),
-		LinkNotActive:       make([]uint32, 0, VRR_PSET_SIZE),
-		Pending:             make([]uint32, 0, VRR_PSET_SIZE),
+		LinkActive:          make([]uint32, 0, owner.config.PsetSize),
+		LinkNotActive:       make([]uint32, 0, owner.config.PsetSize),
+		Pending:             make([]uint32, 0, owner.config.PsetSize),
 		psetStateUpdateChan: make(chan PsetStateUpdate, 100),
 	}
 	// 启动后台工作者goroutine
@@ -70,57 +74,88 @@ func NewPsetStateManager(owner *Node) *PsetStateManager {
 
 // ScheduleUpdate 对应C代码中的 schedule_work，将更新任务放入队列
 func (psm *PsetStateManager) ScheduleUpdate(update PsetStateUpdate) {
+	// 虚拟时间模式下同步处理，保证执行顺序确定
+	if IsVirtual(psm.ownerNode.clock) {
+		psm.handleUpdate(update)
+		return
+	}
 	// 非阻塞发送，如果队列满了，可以打印日志或丢弃，防止阻塞网络处理goroutine
 	select {
 	case psm.psetStateUpdateChan <- update:
 		// 任务成功入队
 	default:
-		log.Printf("Node %d: Pset update queue is full. Discarding update for node %d.", psm.ownerNode.ID, update.node)
+		psm.ownerNode.Logger().Warn(LOG_PSET, "update queue full, discarding update", "peer", update.node)
 	}
 }
 
 // updateHandler 是在后台运行的工作者，对应C代码的 pset_update_handler
 func (psm *PsetStateManager) updateHandler() {
-	n := psm.ownerNode
-	me := n.ID
 	// log.Printf("Node %d: started to receive Hello Msg for updating Pset state", me.ID)
 
 	// TODO：是否要加锁以安全地访问和修改PSetManager的状态
 	// 使用 for-range 循环不断地从channel中接收任务
 	for tmp := range psm.psetStateUpdateChan {
-		curState := n.PsetManager.GetStatus(tmp.node)
-		nextState := helloTrans[curState][tmp.trans]
-		curActive, _ := n.PsetManager.GetActive(tmp.node)
-
-		// 只有当状态或活跃性实际发生变化时，才进行处理和打印日志
-		if curState != nextState || curActive != tmp.active {
-			log.Printf("Node %d: Pset update for Node %d: %s[%s] ==> %s",
-				me, tmp.node, psetStates[curState], psetTrans[tmp.trans], psetStates[nextState])
-			if curState == PSET_UNKNOWN {
-				// 发送Hello消息节点为新节点，添加到PSet中
-				n.PsetManager.Add(tmp.node, nextState, tmp.active)
-			} else {
-				// 状态或活跃性有变化，更新PSet
-				n.PsetManager.Update(tmp.node, nextState, tmp.active)
-			}
-			// 只有在PSet发生变化时才需要更新快照
-			psm.Update()
+		psm.handleUpdate(tmp)
+	}
+	psm.ownerNode.Logger().Debug(LOG_PSET, "update handler stopped")
+}
+
+// handleUpdate 处理一条 HELLO 报文解析出的邻居更新
+func (psm *PsetStateManager) handleUpdate(tmp PsetStateUpdate) {
+	n := psm.ownerNode
+	me := n.ID
+
+	curState := n.PsetManager.GetStatus(tmp.node)
+	nextState := helloTrans[curState][tmp.trans]
+	curActive, _ := n.PsetManager.GetActive(tmp.node)
+
+	// 只有当状态或活跃性实际发生变化时，才进行处理和打印日志
+	if curState != nextState || curActive != tmp.active {
+		psm.ownerNode.Logger().Info(LOG_PSET, "neighbor state changed", "peer", tmp.node,
+			"from", psetStates[curState], "trans", psetTrans[tmp.trans], "to", psetStates[nextState])
+		if curState == PSET_UNKNOWN {
+			// 发送Hello消息节点为新节点，添加到PSet中
+			n.PsetManager.Add(tmp.node, nextState, tmp.active)
+		} else {
+			// 状态或活跃性有变化，更新PSet
+			n.PsetManager.Update(tmp.node, nextState, tmp.active)
 		}
+		// 只有在PSet发生变化时才需要更新快照
+		psm.Update()
 
-		// 如果当前节点自己是非活跃节点(未在虚拟邻居集中),找到一个已加入网络活跃的节点，发送setup_req请求
-		if !n.Active && tmp.active && nextState == PSET_LINKED {
-			log.Printf("Node %d: New Active/linked neighbor %d found. Sending setup_req to self via proxy %d.", me, tmp.node, tmp.node)
-			vset := n.VsetManager.GetAll()
-			n.SendSetupReq(me, me, me, tmp.node, tmp.node, vset)
+		// 邻居不再把本节点列为邻居，链路失效，拆除经过它的路径
+		if nextState == PSET_FAILED && curState != PSET_FAILED && curState != PSET_UNKNOWN {
+			n.LinkFailed(tmp.node)
 		}
 	}
-	log.Printf("Node %d: ended to receive Hello Msg for updating Pset state", me)
+
+	// 记录邻居通告的自举候选者（非活跃邻居才会通告）
+	n.PsetManager.SetCandidate(tmp.node, tmp.candidate, tmp.candidateHops, tmp.candidateSeq)
+
+	active := n.IsActive()
+
+	// 活跃的已链接邻居属于另一个虚拟环时，发起环合并
+	if active && tmp.active && nextState == PSET_LINKED {
+		n.checkRingMerge(tmp.node, tmp.ringID)
+	}
+
+	// 如果当前节点自己是非活跃节点(未在虚拟邻居集中),找到一个已加入网络活跃的节点，发送setup_req请求
+	if !active && tmp.active && nextState == PSET_LINKED {
+		psm.ownerNode.Logger().Info(LOG_VSET, "new active linked neighbor, sending setup_req to self", "peer", tmp.node, "proxy", tmp.node)
+		vset := n.VsetManager.GetAll()
+		n.SendSetupReq(me, me, me, tmp.node, tmp.node, vset)
+	}
 }
 
 // Update ：根据pset 更新 PsetState
 func (psm *PsetStateManager) Update() {
-	// 清空当前状态
 	pm := psm.ownerNode.PsetManager
+	psm.lock.Lock()
+	defer psm.lock.Unlock()
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+
+	// 清空当前状态
 	psm.LinkActive = psm.LinkActive[:0]
 	psm.LinkNotActive = psm.LinkNotActive[:0]
 	psm.Pending = psm.Pending[:0]
@@ -156,3 +191,18 @@ func (psm *PsetStateManager) String() string {
 
 	return builder.String()
 }
+
+// Snapshot 返回三个邻居列表的副本
+func (psm *PsetStateManager) Snapshot() (linkActive, linkNotActive, pending []uint32) {
+	psm.lock.RLock()
+	defer psm.lock.RUnlock()
+	return append([]uint32{}, psm.LinkActive...), append([]uint32{}, psm.LinkNotActive...), append([]uint32{}, psm.Pending...)
+}
+
+// PsetStatusString 返回物理邻居状态 PSET_* 的名称
+func PsetStatusString(status uint32) string {
+	if int(status) < len(psetStates) {
+		return psetStates[status]
+	}
+	return "invalid"
+}
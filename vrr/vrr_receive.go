@@ -1,9 +1,5 @@
 package vrr
 
-import (
-	"log"
-)
-
 const (
 	// 消息类型
 	VRR_HELLO      = 0x1
@@ -12,6 +8,8 @@ const (
 	VRR_SETUP_FAIL = 0x4
 	VRR_TEARDOWN   = 0x5
 	VRR_DATA       = 0x6
+	VRR_PATH_SYNC  = 0x7
+	VRR_VSET_SHARE = 0x8
 )
 
 // --- 节点消息处理器 ---
@@ -23,14 +21,16 @@ func (n *Node) rcvMessage(msg Message) {
 	// 防止在邻居关系还没稳定建立起来之前，错误地标记即将加入邻居的节点为PSET_FAILED
 	// 为HELLo提供更大的容错期
 	n.ResetFailCount(msg.Src)
+	n.metrics.received.Inc(MessageTypeLabel(msg.Type))
+	n.trace(TRACE_RECEIVE, msg, msg.Sender, "")
 
 	msgType := GetMessageTypeString(msg.Type)
 	if msgType == "VRR_HELLO" {
 		// 处理 VRR_HELLO 消息
-		log.Printf("Node %d: Received periodic HELLO Msg from Node %d in same subnet", n.ID, msg.Src)
+		n.Logger().Debug(LOG_HELLO, "received HELLO", "src", msg.Src)
 
 	} else {
-		log.Printf("Node %d: Received message type %s from Node %d to Node %d", n.ID, msgType, msg.Src, msg.Dst)
+		n.Logger().Debug(LOG_ROUTING, "received message", "type", msgType, "src", msg.Src, "dst", msg.Dst)
 		// 节点正在参与虚拟网络活动，自举计数 timeout重置
 		n.ResetActiveTimeout()
 	}
@@ -42,40 +42,61 @@ func (n *Node) rcvMessage(msg Message) {
 		if payload, ok := msg.Payload.(*HelloPayload); ok {
 			n.receiveHello(msg, payload)
 		} else {
-			log.Printf("Node %d: Invalid payload for HELLO message", n.ID)
+			n.Logger().Warn(LOG_NODE, "invalid payload", "type", msgType)
+			n.countDrop(msg, DROP_INVALID)
 		}
 	case VRR_SETUP_REQ:
 		if payload, ok := msg.Payload.(*SetupReqPayload); ok {
 			n.receiveSetupReq(msg, payload)
 		} else {
-			log.Printf("Node %d: Invalid payload for SETUP_REQ message", n.ID)
+			n.Logger().Warn(LOG_NODE, "invalid payload", "type", msgType)
+			n.countDrop(msg, DROP_INVALID)
 		}
 	case VRR_SETUP:
 		if payload, ok := msg.Payload.(*SetupPayload); ok {
 			n.receiveSetup(msg, payload)
 		} else {
-			log.Printf("Node %d: Invalid payload for SETUP message", n.ID)
+			n.Logger().Warn(LOG_NODE, "invalid payload", "type", msgType)
+			n.countDrop(msg, DROP_INVALID)
 		}
 	case VRR_SETUP_FAIL:
 		if payload, ok := msg.Payload.(*SetupFailPayload); ok {
 			n.receiveSetupFail(msg, payload)
 		} else {
-			log.Printf("Node %d: Invalid payload for SETUP_FAIL message", n.ID)
+			n.Logger().Warn(LOG_NODE, "invalid payload", "type", msgType)
+			n.countDrop(msg, DROP_INVALID)
 		}
 	case VRR_TEARDOWN:
 		if payload, ok := msg.Payload.(*TeardownPayload); ok {
 			n.receiveTeardown(msg, payload)
 		} else {
-			log.Printf("Node %d: Invalid payload for TEARDOWN message", n.ID)
+			n.Logger().Warn(LOG_NODE, "invalid payload", "type", msgType)
+			n.countDrop(msg, DROP_INVALID)
 		}
 	case VRR_DATA:
 		if payload, ok := msg.Payload.(*DataPayload); ok {
 			n.receiveData(msg, payload)
 		} else {
-			log.Printf("Node %d: Invalid payload for DATA message", n.ID)
+			n.Logger().Warn(LOG_NODE, "invalid payload", "type", msgType)
+			n.countDrop(msg, DROP_INVALID)
+		}
+	case VRR_PATH_SYNC:
+		if payload, ok := msg.Payload.(*PathSyncPayload); ok {
+			n.receivePathSync(msg, payload)
+		} else {
+			n.Logger().Warn(LOG_NODE, "invalid payload", "type", msgType)
+			n.countDrop(msg, DROP_INVALID)
+		}
+	case VRR_VSET_SHARE:
+		if payload, ok := msg.Payload.(*VsetSharePayload); ok {
+			n.receiveVsetShare(msg, payload)
+		} else {
+			n.Logger().Warn(LOG_NODE, "invalid payload", "type", msgType)
+			n.countDrop(msg, DROP_INVALID)
 		}
 	default:
-		log.Printf("Node %d: Unknown message type: %s", n.ID, GetMessageTypeString(msg.Type))
+		n.Logger().Warn(LOG_NODE, "unknown message type", "type", msgType)
+		n.countDrop(msg, DROP_INVALID)
 	}
 }
 
@@ -84,29 +105,38 @@ func (n *Node) rcvMessage(msg Message) {
 func (n *Node) receiveData(msg Message, payload *DataPayload) {
 	if msg.Dst == n.ID {
 		// 数据包到达目的地
-		log.Printf("Node %d: Data packet delivered from %d, payload size: %d",
-			n.ID, msg.Src, len(payload.Data))
-		// TODO: 递交给上层应用|Data处理逻辑
+		n.Logger().Info(LOG_ROUTING, "data delivered", "src", msg.Src, "port", payload.Port, "size", len(payload.Data))
+		// 递交给上层应用
+		n.deliver(msg, payload)
 	} else {
 		nextHop := n.RoutingTable.GetNext(msg.Dst)
 		if nextHop == 0 {
-			log.Printf("Node %d: No route to forward data to Node %d", n.ID, msg.Dst)
+			n.Logger().Warn(LOG_ROUTING, "no route to forward data", "dst", msg.Dst)
+			n.countDrop(msg, DROP_NO_ROUTE)
 			return
 		}
 
-		// 转发DataMsg
+		// 转发DataMsg，复制 Payload 以累加跳数
+		msg.Sender = n.ID
 		msg.NextHop = nextHop
-		n.Network.Send(msg)
+		msg.Payload = &DataPayload{
+			Port: payload.Port,
+			Hops: payload.Hops + 1,
+			Data: payload.Data,
+		}
+		n.send(msg)
 
-		log.Printf("Node %d: Forwarded data to %d via %d", n.ID, msg.Dst, nextHop)
+		n.Logger().Debug(LOG_ROUTING, "forwarded data", "dst", msg.Dst, "next_hop", nextHop)
 	}
 }
 
 // ------------------Vrr 论文实现方法------------------
 // receiveHello 处理Hello消息
 func (n *Node) receiveHello(msg Message, payload *HelloPayload) {
-	if len(payload.HelloInfoLinkActive) > VRR_PSET_SIZE || len(payload.HelloInfoLinkNotActive) > VRR_PSET_SIZE || len(payload.HelloInfoPending) > VRR_PSET_SIZE {
-		log.Printf("Node %d: Invalid HelloInfo Size.Dropping packet.", n.ID)
+	psetSize := n.config.PsetSize
+	if len(payload.HelloInfoLinkActive) > psetSize || len(payload.HelloInfoLinkNotActive) > psetSize || len(payload.HelloInfoPending) > psetSize {
+		n.Logger().Warn(LOG_HELLO, "invalid HELLO info size, dropping packet", "src", msg.Src)
+		n.countDrop(msg, DROP_INVALID)
 		return
 	}
 	// 解析hello消息的路由消息内容
@@ -144,9 +174,13 @@ func (n *Node) receiveHello(msg Message, payload *HelloPayload) {
 		}
 	}
 	update := PsetStateUpdate{
-		node:   src,
-		trans:  trans,
-		active: active,
+		node:          src,
+		trans:         trans,
+		active:        active,
+		ringID:        payload.RingID,
+		candidate:     payload.Candidate,
+		candidateHops: payload.CandidateHops,
+		candidateSeq:  payload.CandidateSeq,
 	}
 	// 将任务交给PsetStateManager的工作队列
 	n.PsetStateManager.ScheduleUpdate(update)
@@ -181,7 +215,7 @@ func (n *Node) receiveSetupReq(msg Message, payload *SetupReqPayload) {
 
 	// 本节点是src到dst的中间节点
 	if nextHop != 0 {
-		log.Printf("Node %d: Forwarding SETUP_REQ to next hop %d", me, nextHop)
+		n.Logger().Info(LOG_ROUTING, "forwarding setup_req", "type", "VRR_SETUP_REQ", "src", src, "dst", dst, "next_hop", nextHop)
 		// 转发SetupReq消息给nextHop
 		n.SendSetupReq(src, dst, me, nextHop, proxy, vset_)
 		return
@@ -189,13 +223,17 @@ func (n *Node) receiveSetupReq(msg Message, payload *SetupReqPayload) {
 		// 本节点就是dst或最接近dst的节点
 
 		vset := n.VsetManager.GetAll()
+		// src 已在本地 vset 中却仍发来请求，说明对端没有到本节点的路径（例如环合并时的半条路径），
+		// 重新建立一条 vset-path 而不是回复失败
+		known := n.VsetManager.Contains(src)
 		added := n.Add(vset, src, vset_)
-		if added {
-			// 从自己开始setup
+		if added || known {
+			// 从自己开始setup，携带加入 src 之后的 vset：同时加入的节点由此得知先加入的节点
 			// n.SendSetup(me, src, me, me, , proxy, vset)
-			n.LocalRcvSetup(src, n.NewPid(), proxy, vset)
+			n.LocalRcvSetup(src, n.NewPid(), proxy, n.VsetManager.GetAll())
 		} else {
 			// 添加失败，发送Setup失败消息
+			n.countSetupFail(SETUP_REJECTED)
 			n.SendSetupFail(me, src, me, me, proxy, vset)
 		}
 	}
@@ -230,51 +268,74 @@ func (n *Node) receiveSetup(msg Message, payload *SetupPayload) {
 	vset_ := payload.Vset_
 
 	if sender == me {
-		log.Printf("Node %d: Received setup from myself", me)
+		n.Logger().Warn(LOG_ROUTING, "received setup from myself", "type", "VRR_SETUP", "pid", pid)
 	} else {
 		inPset := n.PsetManager.GetStatus(sender)
 		if inPset == PSET_UNKNOWN {
 			n.RoutingTable.TearDownPath(pid, src, sender)
-			log.Printf("Node %d: Sender %d is not in pset!", me, sender)
+			n.Logger().Warn(LOG_ROUTING, "setup sender is not in pset", "type", "VRR_SETUP", "pid", pid, "sender", sender)
 		}
 
 	}
 
-	// 确定下一跳
+	// 确定下一跳：dst 是已链接的物理邻居时直接发送，失败或待定的链路不可用
 	var nextHop uint32
-	if n.PsetManager.GetStatus(dst) == PSET_UNKNOWN {
-		if dst == me {
-			nextHop = 0
-		} else {
-			nextHop = n.RoutingTable.GetNext(proxy)
-		}
-	} else {
-		// dst 在 pset 中
+	switch {
+	case dst == me:
+		nextHop = 0
+	case n.PsetManager.GetStatus(dst) == PSET_LINKED:
 		nextHop = dst
+	default:
+		nextHop = n.nextHopToProxy(proxy)
 	}
 
 	added := n.RoutingTable.Add(src, dst, sender, nextHop, pid)
 	if !added {
-		// to do:明确是写sender还是null
-		n.RoutingTable.TearDownPath(pid, src, 0)
-		log.Printf("Node %d: Couldn't add route, tearing down path to %d", me, src)
+		// 已有相同 <pid, src> 的条目，说明 setup 出现了环路，拆除该路径并停止转发
+		n.RoutingTable.TearDownPath(pid, src, sender)
+		n.Logger().Info(LOG_ROUTING, "couldn't add route, tearing down path", "type", "VRR_SETUP", "pid", pid, "ea", src)
+		n.countSetupFail(SETUP_LOOP)
+		return
+	}
+
+	// 继承 setup 发起者的环标识
+	if dst == me {
+		n.adoptRingID(payload.RingID)
 	}
 
 	// 转发Setup消息给nexthop
 	if nextHop != 0 {
-		n.SendSetup(src, dst, me, nextHop, pid, proxy, vset_)
+		// 直接转发原消息，保留 Payload 中发起者的环标识
+		n.Logger().Info(LOG_ROUTING, "forwarding setup", "type", "VRR_SETUP", "src", src, "dst", dst, "pid", pid, "next_hop", nextHop)
+		msg.Sender = me
+		msg.NextHop = nextHop
+		n.send(msg)
+		return
+	}
+	// 无下一跳且目标不是我：路径无法继续建立
+	if dst != me {
+		n.Logger().Info(LOG_ROUTING, "no next hop towards proxy, tearing down path", "type", "VRR_SETUP", "pid", pid, "proxy", proxy, "dst", dst)
+		n.countSetupFail(SETUP_NO_ROUTE)
+		n.RoutingTable.TearDownPath(pid, src, 0)
 		return
 	}
 	// 本节点就是dst
+	// 双方同时向对方发起 setup 时，src 已经在本地 vset 中，保留这条新路径
+	known := n.VsetManager.Contains(src)
 	vset := n.VsetManager.GetAll()
 	add := n.Add(vset, src, vset_)
 
-	if add {
-		log.Printf("Node %d: vset-paths established by setup message from %d", me, src)
+	if add || known {
+		n.Logger().Info(LOG_ROUTING, "vset-path established", "type", "VRR_SETUP", "pid", pid, "src", src)
+		n.metrics.setupOK.Inc()
+		n.lock.Lock()
 		n.Active = true
+		n.lock.Unlock()
+		n.shareVset(src, sender, vset_)
 		return
 	} else {
-		log.Printf("Node %d: Couldn't add %d to vset, tearing down path", me, src)
+		n.Logger().Info(LOG_VSET, "couldn't add to vset, tearing down path", "type", "VRR_SETUP", "pid", pid, "peer", src)
+		n.countSetupFail(SETUP_REJECTED)
 		//  路径本身是好的，但我（目标节点）由于某种策略无法将源节点加入我的vset
 		// 这是一个“逻辑拒绝”，而不是“链路错误”
 	}
@@ -303,6 +364,10 @@ Receive (<teardown, <pid,ea>, vset‘>, sender)
 func (n *Node) receiveTeardown(msg Message, payload *TeardownPayload) {
 
 	route := n.RoutingTable.RemoveRoute(payload.Pid, payload.Endpoint)
+	if route == nil {
+		// 路径已被拆除（例如从两端同时拆除），忽略重复的teardown
+		return
+	}
 
 	// 确定下一个要发送teardown的节点，到达ea或eb时，next=0
 	var nextHop uint32
@@ -318,29 +383,18 @@ func (n *Node) receiveTeardown(msg Message, payload *TeardownPayload) {
 		msg.Sender = n.ID
 		msg.NextHop = nextHop
 		// 2. 直接将修改后的消息发送出去
-		n.Network.Send(msg)
+		n.send(msg)
 		// n.SendTeardown(payload.Pid, payload.Endpoint, payload.Vset_, next)
 	} else {
 		// 到达ea或eb节点，更新本地vset
+		// Na 指向 ea 一侧：teardown 从 Na 来说明对端是 ea，否则对端是 eb
 		var e uint32
 		if msg.Sender == route.Na {
-			e = route.Eb
-		} else {
 			e = route.Ea
-		}
-		n.VsetManager.Remove(e)
-
-		// 如果vset'不为空
-		if len(payload.Vset_) > 0 {
-			vset := n.VsetManager.GetAll()
-			// 合并vset'到本地vset
-			n.Add(vset, 0, payload.Vset_)
 		} else {
-			//
-			// vset'为空，发生了链路错误，通过其他代码重新建立连接
-			// proxy, _ := n.PsetManager.GetProxy()
-			// n.SendSetupReq(n.ID, e, proxy)
+			e = route.Eb
 		}
+		n.endpointLost(e, payload.Vset_)
 	}
 }
 
@@ -354,13 +408,20 @@ Receive (<setup_fail,src,dst,proxy,vset'>, sender)
 */
 // receiveSetupFail 处理Setup失败消息
 func (n *Node) receiveSetupFail(msg Message, payload *SetupFailPayload) {
-	// 确定下一跳
-	var nextHop uint32
+	if msg.Dst == n.ID {
+		// 自己是目的地，将src添加到vset并处理
+		srcVsetWithSrc := append(payload.Vset_, msg.Src)
+		vset := n.VsetManager.GetAll()
+		n.Add(vset, 0, srcVsetWithSrc)
+		return
+	}
 
-	if n.PsetManager.IsActiveLinkedPset(msg.Dst) {
+	// 确定下一跳，dst 通常是尚未活跃的新节点，只要求链路已链接
+	var nextHop uint32
+	if n.PsetManager.GetStatus(msg.Dst) == PSET_LINKED {
 		nextHop = msg.Dst
 	} else {
-		nextHop = n.RoutingTable.GetNext(payload.Proxy)
+		nextHop = n.nextHopToProxy(payload.Proxy)
 	}
 
 	if nextHop != 0 {
@@ -368,37 +429,103 @@ func (n *Node) receiveSetupFail(msg Message, payload *SetupFailPayload) {
 		// 1、更新消息信封的路由信息并转发
 		msg.Sender = n.ID
 		msg.NextHop = nextHop
-		n.Network.Send(msg)
+		n.send(msg)
 		// n.SendSetupFail(msg.Src, msg.Dst, n.ID, nextHop, payload.Proxy, payload.Vset_)
-	} else if msg.Dst == n.ID {
-		// 自己是目的地，将src添加到vset并处理
-		srcVsetWithSrc := append(payload.Vset_, msg.Src)
+	} else {
+		n.countDrop(msg, DROP_NO_ROUTE)
+	}
+}
+
+// shareVset 在 src 经由 setup 加入 vset 后，把 src 的 vset' 中缺少的本地 vset 成员告诉 src。
+// setup_req 携带的是请求发出时的 vset，同时加入的节点可能彼此不知道；
+// 本地 vset 由 vset_share 消息沿刚建立的路径回送
+func (n *Node) shareVset(src, sender uint32, vset_ []uint32) {
+	if sender == n.ID {
+		return
+	}
+	known := make(map[uint32]bool, len(vset_)+1)
+	known[src] = true
+	for _, id := range vset_ {
+		known[id] = true
+	}
+	vset := n.VsetManager.GetAll()
+	for _, id := range vset {
+		if !known[id] {
+			n.SendVsetShare(src, sender, vset)
+			return
+		}
+	}
+}
+
+/*
+Receive (<vset_share,src,dst,vset'>, sender)
+    nh := (dst ∈ pset) ? dst : NextHop(rt, dst)
+    if (dst = me)
+        Add(vset, null, vset')
+    else if (nh != null)
+        Send <vset_share, src, dst, vset'> to nh
+*/
+// receiveVsetShare 处理 vset_share 消息：目标尝试添加其中的节点，中间节点沿路径转发
+func (n *Node) receiveVsetShare(msg Message, payload *VsetSharePayload) {
+	if msg.Dst == n.ID {
 		vset := n.VsetManager.GetAll()
-		n.Add(vset, 0, srcVsetWithSrc)
+		n.Add(vset, 0, payload.Vset_)
+		return
+	}
+
+	nextHop := n.nextHopToProxy(msg.Dst)
+	if nextHop != 0 {
+		msg.Sender = n.ID
+		msg.NextHop = nextHop
+		n.send(msg)
+	} else {
+		n.countDrop(msg, DROP_NO_ROUTE)
 	}
 }
 
+// nextHopToProxy 确定 setup/setup_fail 消息前往代理节点的下一跳。
+// 代理本身是已链接的物理邻居时直接发送给它，避免刚加入的节点因路由表为空而无法回送。
+func (n *Node) nextHopToProxy(proxy uint32) uint32 {
+	if proxy != n.ID && n.PsetManager.GetStatus(proxy) == PSET_LINKED {
+		return proxy
+	}
+	return n.RoutingTable.GetNext(proxy)
+}
+
 func (n *Node) LocalRcvSetup(dst, pid, proxy uint32, vset_ []uint32) {
 	me := n.ID
-	// 确定下一跳
+	// 确定下一跳：dst 是已链接的物理邻居时直接发送，失败或待定的链路不可用
 	var nextHop uint32
-	if n.PsetManager.GetStatus(dst) == PSET_UNKNOWN {
-		nextHop = n.RoutingTable.GetNext(proxy)
-	} else {
+	if n.PsetManager.GetStatus(dst) == PSET_LINKED {
 		nextHop = dst
+	} else {
+		nextHop = n.nextHopToProxy(proxy)
+	}
+	if nextHop == 0 {
+		// 到代理已不可达（例如请求发出后代理或 dst 失效），不建立没有下一跳的路径，
+		// 否则 dst 会一直留在 vset 中；撤销刚才对 vset 的添加，由 dst 之后重新发起请求
+		n.Logger().Info(LOG_ROUTING, "no next hop towards proxy, dropping setup", "type", "VRR_SETUP", "pid", pid, "proxy", proxy, "dst", dst)
+		n.countSetupFail(SETUP_NO_ROUTE)
+		if !n.RoutingTable.HasPathTo(dst) {
+			n.VsetManager.Remove(dst)
+		}
+		return
 	}
 
+	// 本节点生成的 pid 与自己已有的路径 <pid, me> 重复时保留已有的路径，换一个新的 pid 重试
 	added := n.RoutingTable.Add(me, dst, 0, nextHop, pid)
+	for i := 0; !added && i < VRR_PID_RETRIES; i++ {
+		old := pid
+		pid = n.VrrNewPathID()
+		n.Logger().Info(LOG_ROUTING, "path ID already in use, retrying with a new one", "type", "VRR_SETUP", "pid", old, "new_pid", pid, "dst", dst)
+		added = n.RoutingTable.Add(me, dst, 0, nextHop, pid)
+	}
 	if !added {
-		// to do:明确是写sender还是null
-		n.RoutingTable.TearDownPath(pid, me, 0)
-		log.Printf("Node %d: Couldn't add route, tearing down path to %d", me, me)
+		n.Logger().Warn(LOG_ROUTING, "couldn't add route with a fresh path ID, dropping setup", "type", "VRR_SETUP", "pid", pid, "dst", dst)
+		n.countSetupFail(SETUP_LOOP)
+		return
 	}
 
 	// 转发Setup消息给nexthop
-	if nextHop != 0 {
-		n.SendSetup(me, dst, me, nextHop, pid, proxy, vset_)
-		return
-	}
-	return
+	n.SendSetup(me, dst, me, nextHop, pid, proxy, vset_)
 }
@@ -0,0 +1,64 @@
+package vrr
+
+import "sort"
+
+// 虚拟环上的距离和顺序。节点ID构成一个 2^32 的环：顺时针方向为ID增大的方向，
+// 越过 2^32-1 后回到 0。VsetManager 判断虚拟邻居(ShouldAdd/bump)和路由表查找最近端点
+// 都使用这里的定义，保证两者对“最近”的理解一致。
+
+// RingClockwise 返回从 from 顺时针走到 to 的距离
+func RingClockwise(from, to uint32) uint32 {
+	return to - from // 无符号减法自动回绕
+}
+
+// RingCounterClockwise 返回从 from 逆时针走到 to 的距离
+func RingCounterClockwise(from, to uint32) uint32 {
+	return from - to
+}
+
+// RingDistance 返回两个ID在环上的距离，即顺时针和逆时针距离中较小的一个
+func RingDistance(a, b uint32) uint32 {
+	cw, ccw := RingClockwise(a, b), RingCounterClockwise(a, b)
+	if cw < ccw {
+		return cw
+	}
+	return ccw
+}
+
+// RingCloser 判断 a 是否比 b 更接近 dest：环上距离更小，距离相同时取较小的ID
+func RingCloser(dest, a, b uint32) bool {
+	da, db := RingDistance(dest, a), RingDistance(dest, b)
+	if da != db {
+		return da < db
+	}
+	return a < b
+}
+
+// RingSort 按从 me 出发的顺时针距离对 ids 原地排序：最前面的是 me 的后继，最后面的是 me 的前驱
+func RingSort(me uint32, ids []uint32) {
+	sort.Slice(ids, func(i, j int) bool {
+		return RingClockwise(me, ids[i]) < RingClockwise(me, ids[j])
+	})
+}
+
+// RingNeighbors 返回 ids 中 me 在虚拟环上的邻居：顺时针和逆时针方向各 size/2 个最近的节点，
+// 节点不足 size 个时为全部节点。me、0 和重复的ID被忽略，结果按顺时针距离排序
+func RingNeighbors(me uint32, ids []uint32, size int) []uint32 {
+	ring := make([]uint32, 0, len(ids))
+	seen := make(map[uint32]bool, len(ids))
+	for _, id := range ids {
+		if id == me || id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ring = append(ring, id)
+	}
+	RingSort(me, ring)
+	if len(ring) <= size {
+		return ring
+	}
+
+	radius := size / 2
+	neighbors := append([]uint32(nil), ring[:radius]...)
+	return append(neighbors, ring[len(ring)-radius:]...)
+}
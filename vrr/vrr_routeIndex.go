@@ -0,0 +1,111 @@
+package vrr
+
+import "sort"
+
+// endpointIndex 是路由表的端点索引，随路由条目的添加和移除一起维护：
+// 所有端点按ID升序排成一个环，查找离目标最近的端点（考虑回绕和排除）只需一次二分查找；
+// 每个端点记录以它为 Ea 或 Eb 的条目，以及其中的最佳路由（PathId 最大，相同时取 Ea 较小的）。
+type endpointIndex struct {
+	ring    []uint32                                   // 所有端点，升序，不含 0
+	entries map[uint32]map[routeKey]*RoutingTableEntry // 端点 -> 以它为端点的条目
+	best    map[uint32]*RoutingTableEntry              // 端点 -> 最佳路由
+}
+
+func newEndpointIndex() *endpointIndex {
+	return &endpointIndex{
+		entries: make(map[uint32]map[routeKey]*RoutingTableEntry),
+		best:    make(map[uint32]*RoutingTableEntry),
+	}
+}
+
+// endpointsOf 返回条目的端点，Ea 与 Eb 相同时只返回一个，忽略 0
+func endpointsOf(entry *RoutingTableEntry) []uint32 {
+	switch {
+	case entry.Ea == 0 && entry.Eb == 0:
+		return nil
+	case entry.Ea == 0:
+		return []uint32{entry.Eb}
+	case entry.Eb == 0 || entry.Ea == entry.Eb:
+		return []uint32{entry.Ea}
+	}
+	return []uint32{entry.Ea, entry.Eb}
+}
+
+// betterRoute 判断 a 是否是比 b 更好的路由
+func betterRoute(a, b *RoutingTableEntry) bool {
+	if b == nil {
+		return true
+	}
+	if a.PathId != b.PathId {
+		return a.PathId > b.PathId
+	}
+	return a.Ea < b.Ea
+}
+
+// add 把条目加入索引
+func (idx *endpointIndex) add(key routeKey, entry *RoutingTableEntry) {
+	for _, ep := range endpointsOf(entry) {
+		routes, ok := idx.entries[ep]
+		if !ok {
+			routes = make(map[routeKey]*RoutingTableEntry)
+			idx.entries[ep] = routes
+			i := sort.Search(len(idx.ring), func(k int) bool { return idx.ring[k] >= ep })
+			idx.ring = append(idx.ring, 0)
+			copy(idx.ring[i+1:], idx.ring[i:])
+			idx.ring[i] = ep
+		}
+		routes[key] = entry
+		if betterRoute(entry, idx.best[ep]) {
+			idx.best[ep] = entry
+		}
+	}
+}
+
+// remove 把条目从索引中移除，端点不再有任何条目时从环上删除
+func (idx *endpointIndex) remove(key routeKey, entry *RoutingTableEntry) {
+	for _, ep := range endpointsOf(entry) {
+		routes := idx.entries[ep]
+		delete(routes, key)
+		if len(routes) == 0 {
+			delete(idx.entries, ep)
+			delete(idx.best, ep)
+			i := sort.Search(len(idx.ring), func(k int) bool { return idx.ring[k] >= ep })
+			if i < len(idx.ring) && idx.ring[i] == ep {
+				idx.ring = append(idx.ring[:i], idx.ring[i+1:]...)
+			}
+			continue
+		}
+		if idx.best[ep] == entry {
+			var best *RoutingTableEntry
+			for _, route := range routes {
+				if betterRoute(route, best) {
+					best = route
+				}
+			}
+			idx.best[ep] = best
+		}
+	}
+}
+
+// closest 返回离 dest 最近的端点(RingCloser：环上距离最小，距离相同时取较小的ID)；
+// exclude 不为 0 时跳过该端点。没有可用端点时返回 0
+func (idx *endpointIndex) closest(dest, exclude uint32) uint32 {
+	n := len(idx.ring)
+	if n == 0 {
+		return 0
+	}
+	// 最近的端点一定是 dest 在环上顺时针或逆时针方向的第一个端点，
+	// 该端点被排除时再往前一个，因此每个方向最多看两个
+	i := sort.Search(n, func(k int) bool { return idx.ring[k] >= dest })
+	var closest uint32
+	consider := func(ep uint32) {
+		if ep != exclude && (closest == 0 || RingCloser(dest, ep, closest)) {
+			closest = ep
+		}
+	}
+	for k := 0; k < 2 && k < n; k++ {
+		consider(idx.ring[(i+k)%n])
+		consider(idx.ring[((i-1-k)%n+n)%n])
+	}
+	return closest
+}
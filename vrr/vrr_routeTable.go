@@ -2,7 +2,6 @@ package vrr
 
 import (
 	"fmt"
-	"log"
 	"sort"
 	"strings"
 	"sync"
@@ -10,9 +9,18 @@ import (
 
 // RoutingTableManager 封装了单个节点的路由表状态和操作。
 type RoutingTableManager struct {
-	ownerNode *Node                         // 指向拥有此管理器的节点
-	lock      sync.RWMutex                  // 使用读写锁以优化性能
-	routes    map[uint32]*RoutingTableEntry // 键是 PathId，值是路由条目
+	ownerNode *Node                           // 指向拥有此管理器的节点
+	lock      sync.RWMutex                    // 使用读写锁以优化性能
+	routes    map[routeKey]*RoutingTableEntry // 键是 <pid, ea>，值是路由条目
+	index     *endpointIndex                  // 端点索引，随 routes 一起维护
+	synced    map[routeKey]bool               // 上一次路由核对时已经存在的条目
+}
+
+// routeKey 是论文中路由条目的标识 <pid, ea>：PathId 由端点随机生成，
+// 不同端点生成的 PathId 可能相同，只有和 Ea 一起才能唯一确定一条路径
+type routeKey struct {
+	pid uint32
+	ea  uint32
 }
 
 // Struct for use in VRR Routing Table
@@ -28,148 +36,186 @@ type RoutingTableEntry struct {
 func NewRoutingTableManager(owner *Node) *RoutingTableManager {
 	return &RoutingTableManager{
 		ownerNode: owner,
-		routes:    make(map[uint32]*RoutingTableEntry),
+		routes:    make(map[routeKey]*RoutingTableEntry),
+		index:     newEndpointIndex(),
 	}
 }
 
-// getNextHop  在路由条目列表中查找最佳下一跳。
-func (rt *RoutingTableManager) getNextHop(closestEndpoint uint32) uint32 {
-	var bestRoute *RoutingTableEntry
-	var nextHop uint32
-	// 1.根据closestEndpoint查找所有相关路由条目。并知道Path ID最大的路由条目是最佳路由。
-	for _, route := range rt.routes {
-		if route.Ea == closestEndpoint || route.Eb == closestEndpoint {
-			if bestRoute == nil || route.PathId > bestRoute.PathId {
-				bestRoute = route
-			}
-		}
-	}
+// reset 清空路由表
+func (rt *RoutingTableManager) reset() {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	rt.routes = make(map[routeKey]*RoutingTableEntry)
+	rt.index = newEndpointIndex()
+	rt.synced = nil
+}
 
+// getNextHop 返回朝向 closestEndpoint 的最佳路由（PathId 最大的条目）上的下一跳
+func (rt *RoutingTableManager) getNextHop(closestEndpoint uint32) uint32 {
+	bestRoute := rt.index.best[closestEndpoint]
 	if bestRoute == nil {
 		return 0
 	}
-	// 2. 根据找到的最佳路由路由条目，判断Ea和Eb那个是closestEndpoint确定下一跳。
+	// 根据找到的最佳路由条目，判断Ea和Eb哪个是closestEndpoint确定下一跳。
 	switch closestEndpoint {
 	case bestRoute.Ea:
-		nextHop = bestRoute.Na
+		return bestRoute.Na
 	case bestRoute.Eb:
-		nextHop = bestRoute.Nb
-	default:
-		nextHop = 0
+		return bestRoute.Nb
 	}
-	return nextHop
-
+	return 0
 }
 
 // getClosestEndpoint 查找最接近目标的端点endpoint
 func (rt *RoutingTableManager) getClosestEndpoint(dest uint32) uint32 {
-	var closestEndpoint uint32
-	var minDistance uint32 = ^uint32(0) // 最大的 uint32 值
+	return rt.index.closest(dest, 0)
+}
 
-	endpoints := make(map[uint32]bool)
+// getClosestEndpointExclude 查找最接近目标的端点，但排除指定的源端点
+func (rt *RoutingTableManager) getClosestEndpointExclude(dest uint32, excludeSrc uint32) uint32 {
+	return rt.index.closest(dest, excludeSrc)
+}
 
-	for _, route := range rt.routes {
-		if route.Ea != 0 {
-			endpoints[route.Ea] = true
-		}
-		if route.Eb != 0 {
-			endpoints[route.Eb] = true
+// getEntriesByEndpoint 查找并返回本节点与指定端点之间的所有路由条目。
+// 只经过本节点转发的路径不属于本节点，不能由本节点的 vset 变化拆除。
+func (rt *RoutingTableManager) getTearDownPathsByEndpoint(endpoint uint32) []*RoutingTableEntry {
+	rt.lock.RLock()
+	defer rt.lock.RUnlock()
+	me := rt.ownerNode.ID
+
+	var foundPaths []*RoutingTableEntry
+	for _, route := range rt.index.entries[endpoint] {
+		if (route.Ea == me && route.Eb == endpoint) || (route.Eb == me && route.Ea == endpoint) {
+			foundPaths = append(foundPaths, route)
 		}
 	}
+	sortRoutes(foundPaths)
+	return foundPaths
+}
 
-	if len(endpoints) == 0 {
-		return 0 // 没有可用的外部端点
-	}
+// HasNextHop 判断是否有路由条目以指定节点作为下一跳
+func (rt *RoutingTableManager) HasNextHop(nodeID uint32) bool {
+	rt.lock.RLock()
+	defer rt.lock.RUnlock()
 
-	for ep := range endpoints {
-		distance := get_diff(dest, ep)
-		if distance < minDistance {
-			minDistance = distance
-			closestEndpoint = ep
+	for _, route := range rt.routes {
+		if route.Na == nodeID || route.Nb == nodeID {
+			return true
 		}
 	}
-
-	if closestEndpoint == 0 {
-		return 0 // 未找到最近的端点
-	}
-
-	return closestEndpoint
+	return false
 }
 
-// getClosestEndpointExclude 查找最接近目标的端点，但排除指定的源端点
-func (rt *RoutingTableManager) getClosestEndpointExclude(dest uint32, excludeSrc uint32) uint32 {
-	var closestEndpoint uint32
-	var minDistance uint32 = ^uint32(0) // 最大的 uint32 值
-
-	endpoints := make(map[uint32]bool)
+// getPathsByNextHop 查找并返回所有以指定节点为下一跳的路由条目。
+func (rt *RoutingTableManager) getPathsByNextHop(nodeID uint32) []*RoutingTableEntry {
+	rt.lock.RLock()
+	defer rt.lock.RUnlock()
 
+	var foundPaths []*RoutingTableEntry
 	for _, route := range rt.routes {
-		if route.Ea != 0 && route.Ea != excludeSrc {
-			endpoints[route.Ea] = true
-		}
-		if route.Eb != 0 && route.Eb != excludeSrc {
-			endpoints[route.Eb] = true
+		if route.Na == nodeID || route.Nb == nodeID {
+			foundPaths = append(foundPaths, route)
 		}
 	}
+	sortRoutes(foundPaths)
+	return foundPaths
+}
 
-	if len(endpoints) == 0 {
-		return 0 // 没有可用的外部端点
-	}
-
-	for ep := range endpoints {
-		distance := get_diff(dest, ep)
-		if distance < minDistance {
-			minDistance = distance
-			closestEndpoint = ep
+// sortRoutes 按 (PathId, Ea) 排序路由条目，使拆除等操作的顺序确定
+func sortRoutes(routes []*RoutingTableEntry) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].PathId != routes[j].PathId {
+			return routes[i].PathId < routes[j].PathId
 		}
-	}
+		return routes[i].Ea < routes[j].Ea
+	})
+}
 
-	if closestEndpoint == 0 {
-		return 0 // 未找到最近的端点
+// pathsToSync 按下一跳整理在上一次路由核对时就已存在的路由条目，并记下当前的条目供下一次使用。
+// 刚加入的条目要等到下一次核对：它的 setup 可能还在发往下一跳的途中
+func (rt *RoutingTableManager) pathsToSync() map[uint32][]PathRef {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+
+	routes := make([]*RoutingTableEntry, 0, len(rt.routes))
+	synced := make(map[routeKey]bool, len(rt.routes))
+	for key, route := range rt.routes {
+		routes = append(routes, route)
+		synced[key] = true
 	}
+	sortRoutes(routes)
 
-	return closestEndpoint
+	paths := make(map[uint32][]PathRef)
+	for _, route := range routes {
+		if !rt.synced[routeKey{route.PathId, route.Ea}] {
+			continue
+		}
+		for _, hop := range []uint32{route.Na, route.Nb} {
+			if hop != 0 {
+				paths[hop] = append(paths[hop], PathRef{Pid: route.PathId, Endpoint: route.Ea})
+			}
+		}
+	}
+	rt.synced = synced
+	return paths
 }
 
-// getEntriesByEndpoint 查找并返回所有以指定ID为端点的路由条目。
-func (rt *RoutingTableManager) getTearDownPathsByEndpoint(endpoint uint32) []*RoutingTableEntry {
+// getOwnPaths 返回本节点作为端点的所有路由条目
+func (rt *RoutingTableManager) getOwnPaths() []*RoutingTableEntry {
 	rt.lock.RLock()
 	defer rt.lock.RUnlock()
+	me := rt.ownerNode.ID
 
 	var foundPaths []*RoutingTableEntry
 	for _, route := range rt.routes {
-		if route.Ea == endpoint || route.Eb == endpoint {
+		if route.Ea == me || route.Eb == me {
 			foundPaths = append(foundPaths, route)
 		}
 	}
+	sortRoutes(foundPaths)
 	return foundPaths
 }
 
+// HasPathTo 判断本节点与指定端点之间是否还有 vset-path
+func (rt *RoutingTableManager) HasPathTo(endpoint uint32) bool {
+	rt.lock.RLock()
+	defer rt.lock.RUnlock()
+	me := rt.ownerNode.ID
+
+	for _, route := range rt.index.entries[endpoint] {
+		if (route.Ea == me && route.Eb == endpoint) || (route.Eb == me && route.Ea == endpoint) {
+			return true
+		}
+	}
+	return false
+}
+
 // -------------------VRR 论文方法实现---------------------------------------------
 /*
 Add(rt, <ea , eb , na , nb , pid> )
 	adds the entry to the routing table unless there is already an
 	entry with the same pid, ea
 */
-// AddRoute  向路由表添加一个路由条目。
-// to do :the entry with the same pid, ea should not be added
+// Add 向路由表添加一个路由条目，已有相同 <pid, ea> 的条目时不添加并返回 false
 func (rt *RoutingTableManager) Add(ea, eb, na, nb, pathID uint32) bool {
 	rt.lock.Lock()
 	defer rt.lock.Unlock()
 
-	// 检查 PathId 是否已存在
-	if _, exists := rt.routes[pathID]; exists {
-		log.Printf("Node %d: Route with pathID %d already exists", rt.ownerNode.ID, pathID)
+	key := routeKey{pathID, ea}
+	if _, exists := rt.routes[key]; exists {
+		rt.ownerNode.Logger().Debug(LOG_ROUTING, "route already exists", "pid", pathID, "ea", ea)
 		return false
 	}
-	rt.routes[pathID] = &RoutingTableEntry{
+	entry := &RoutingTableEntry{
 		Ea:     ea,
 		Eb:     eb,
 		Na:     na,
 		Nb:     nb,
 		PathId: pathID,
 	}
-	log.Printf("Node %d: Added route (pathID: %d, ea: %d, eb: %d, na: %d, nb: %d)", rt.ownerNode.ID, pathID, ea, eb, na, nb)
+	rt.routes[key] = entry
+	rt.index.add(key, entry)
+	rt.ownerNode.Logger().Info(LOG_ROUTING, "route added", "pid", pathID, "ea", ea, "eb", eb, "na", na, "nb", nb)
 	return true
 }
 
@@ -177,19 +223,20 @@ func (rt *RoutingTableManager) Add(ea, eb, na, nb, pathID uint32) bool {
 Remove(rt, <pid, ea> )
 	removes and returns the entry identified by pid, ea from the routing table
 */
-// RemoveRoute 从路由表中移除一个路由条目。
-// tip:根据pathID删除条目即可，因为pathID是唯一标识
-func (rt *RoutingTableManager) RemoveRoute(pathID uint32, endpoint uint32) *RoutingTableEntry {
+// RemoveRoute 从路由表中移除由 <pid, ea> 标识的路由条目并返回它，不存在时返回 nil
+func (rt *RoutingTableManager) RemoveRoute(pathID uint32, ea uint32) *RoutingTableEntry {
 	rt.lock.Lock()
 	defer rt.lock.Unlock()
 
-	entry, found := rt.routes[pathID]
+	key := routeKey{pathID, ea}
+	entry, found := rt.routes[key]
 	if !found {
 		return nil
 	}
 
-	delete(rt.routes, pathID)
-	log.Printf("Node %d: Removed route (pathID: %d)", rt.ownerNode.ID, pathID)
+	delete(rt.routes, key)
+	rt.index.remove(key, entry)
+	rt.ownerNode.Logger().Info(LOG_ROUTING, "route removed", "pid", pathID, "ea", ea)
 	return entry
 }
 
@@ -246,10 +293,10 @@ TearDownPath(<pid,ea>, sender)
             vset’ := (sender != null) ? vset : null
             Send <teardown, <pid, ea> , vset’> to n
 */
-// TearDownPath 撤销路径
-func (rt *RoutingTableManager) TearDownPath(pathID, endpoint, sender uint32) {
+// TearDownPath 撤销由 <pid, ea> 标识的路径
+func (rt *RoutingTableManager) TearDownPath(pathID, ea, sender uint32) {
 	// 移除路由
-	route := rt.RemoveRoute(endpoint, pathID)
+	route := rt.RemoveRoute(pathID, ea)
 	if route == nil {
 		return
 	}
@@ -265,19 +312,19 @@ func (rt *RoutingTableManager) TearDownPath(pathID, endpoint, sender uint32) {
 		srcVsetToSend = nil // 不包含vset
 	}
 
-	// 发送teardown消息
-	if route.Na != 0 && n.PsetManager.IsActiveLinkedPset(route.Na) {
-		n.SendTeardown(pathID, endpoint, srcVsetToSend, route.Na)
+	// 发送teardown消息：下一跳可能是刚收到 setup、尚未被标记为活跃的节点，只要求链路已链接
+	if route.Na != 0 && n.PsetManager.GetStatus(route.Na) == PSET_LINKED {
+		n.SendTeardown(pathID, ea, srcVsetToSend, route.Na)
 	}
-	if route.Nb != 0 && n.PsetManager.IsActiveLinkedPset(route.Nb) {
-		n.SendTeardown(pathID, endpoint, srcVsetToSend, route.Nb)
+	if route.Nb != 0 && n.PsetManager.GetStatus(route.Nb) == PSET_LINKED {
+		n.SendTeardown(pathID, ea, srcVsetToSend, route.Nb)
 	}
 }
 
 // TearDownPathTo 拆除所有以指定ID为端点的vset-paths。
 func (rt *RoutingTableManager) TearDownPathTo(endpoint uint32) {
 
-	log.Printf("Node %d: Tearing down all paths to endpoint %d", rt.ownerNode.ID, endpoint)
+	rt.ownerNode.Logger().Info(LOG_ROUTING, "tearing down all paths to endpoint", "endpoint", endpoint)
 
 	// 从路由表中查找所有相关的路径
 	// 这个操作需要路由表管理器提供一个新方法来获取这些路径
@@ -287,13 +334,58 @@ func (rt *RoutingTableManager) TearDownPathTo(endpoint uint32) {
 	// sender设置为0，因为这是由本节点的内部策略（vset bump）触发的，没有外部发送者。
 	// 这表示一次“正常的路径维护”，而不是“网络故障”。
 	for _, path := range pathsToTearDown {
-		log.Printf("Node %d:   - Tearing down path (pid=%d, ea=%d)", rt.ownerNode.ID, path.PathId, path.Ea)
+		rt.ownerNode.Logger().Info(LOG_ROUTING, "tearing down path", "pid", path.PathId, "ea", path.Ea)
 		// 使用 path.Ea 作为TearDownPath的端点参数，因为pid+ea是唯一标识
 		rt.TearDownPath(path.PathId, path.Ea, 0)
 	}
 }
 
 // ---------------------public api--------------------------------------------
+// Lookup 按 <pid, ea> 查找路由条目，返回条目的副本
+func (rt *RoutingTableManager) Lookup(pathID, ea uint32) (RoutingTableEntry, bool) {
+	rt.lock.RLock()
+	defer rt.lock.RUnlock()
+
+	entry, found := rt.routes[routeKey{pathID, ea}]
+	if !found {
+		return RoutingTableEntry{}, false
+	}
+	return *entry, true
+}
+
+// RoutesTo 返回以指定节点为端点（Ea 或 Eb）的所有路由条目的副本，按 (PathId, Ea) 排序
+func (rt *RoutingTableManager) RoutesTo(endpoint uint32) []RoutingTableEntry {
+	rt.lock.RLock()
+	var routes []*RoutingTableEntry
+	for _, route := range rt.index.entries[endpoint] {
+		routes = append(routes, route)
+	}
+	rt.lock.RUnlock()
+
+	sortRoutes(routes)
+	snapshot := make([]RoutingTableEntry, len(routes))
+	for i, route := range routes {
+		snapshot[i] = *route
+	}
+	return snapshot
+}
+
+// Routes 返回路由表的快照（条目的副本），按 (PathId, Ea) 排序，供一致性检查等外部工具读取
+func (rt *RoutingTableManager) Routes() []RoutingTableEntry {
+	rt.lock.RLock()
+	routes := make([]*RoutingTableEntry, 0, len(rt.routes))
+	for _, route := range rt.routes {
+		routes = append(routes, route)
+	}
+	sortRoutes(routes)
+	snapshot := make([]RoutingTableEntry, len(routes))
+	for i, route := range routes {
+		snapshot[i] = *route
+	}
+	rt.lock.RUnlock()
+	return snapshot
+}
+
 // String 返回 RoutingTableManager 状态的可读字符串表示形式
 func (rt *RoutingTableManager) String() string {
 	rt.lock.RLock()
@@ -303,17 +395,16 @@ func (rt *RoutingTableManager) String() string {
 		return "RoutingTable: {empty}"
 	}
 
-	// 为了保证输出顺序一致，我们对 PathId 进行排序
-	keys := make([]uint32, 0, len(rt.routes))
-	for k := range rt.routes {
-		keys = append(keys, k)
+	// 为了保证输出顺序一致，按 (PathId, Ea) 排序
+	routes := make([]*RoutingTableEntry, 0, len(rt.routes))
+	for _, route := range rt.routes {
+		routes = append(routes, route)
 	}
-	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	sortRoutes(routes)
 
 	var builder strings.Builder
 	builder.WriteString("RoutingTable:\n")
-	for _, pathID := range keys {
-		route := rt.routes[pathID]
+	for _, route := range routes {
 		builder.WriteString(fmt.Sprintf("\t- PathID: %d, Ea: %d, Eb: %d, Na: %d, Nb: %d\n",
 			route.PathId, route.Ea, route.Eb, route.Na, route.Nb))
 	}
@@ -1,10 +1,10 @@
 package vrr
 
-import "log"
+import "sync/atomic"
 
 // SendSetupReq 构建并发送一个 setup request 数据包
 func (n *Node) SendSetupReq(src, dest, sender, nextHop uint32, proxy uint32, vset_ []uint32) bool {
-	log.Printf("Node %d: SendSetupReq to dest=%d via proxy=%d", src, dest, proxy)
+	n.Logger().Info(LOG_ROUTING, "sending setup_req", "type", "VRR_SETUP_REQ", "src", src, "dst", dest, "proxy", proxy, "next_hop", nextHop)
 
 	// 2. 创建消息信封 (Message)，并装入 Payload
 	msg := Message{
@@ -19,15 +19,18 @@ func (n *Node) SendSetupReq(src, dest, sender, nextHop uint32, proxy uint32, vse
 			Vset_: vset_,
 		},
 	}
+	if src == n.ID {
+		n.metrics.setupReqs.Inc()
+	}
 
-	n.Network.Send(msg)
+	n.send(msg)
 	return true
 }
 
 // SendSetup 构建并发送一个 setup 数据包
 func (n *Node) SendSetup(src, dest, sender uint32, nextHop uint32, pid, proxy uint32, vset []uint32) bool {
-	log.Printf("Node %d: SendSetup src=%d dest=%d pathID=%d proxy=%d nextHop=%d",
-		n.ID, src, dest, pid, proxy, nextHop)
+	n.Logger().Info(LOG_ROUTING, "sending setup", "type", "VRR_SETUP",
+		"src", src, "dst", dest, "pid", pid, "proxy", proxy, "next_hop", nextHop)
 
 	msg := Message{
 		Type:    VRR_SETUP,
@@ -37,20 +40,21 @@ func (n *Node) SendSetup(src, dest, sender uint32, nextHop uint32, pid, proxy ui
 		NextHop: nextHop, // 消息发送给 nextHop
 
 		Payload: &SetupPayload{
-			Pid:   pid,
-			Proxy: proxy,
-			Vset_: append([]uint32(nil), vset...), // 复制切片
+			Pid:    pid,
+			Proxy:  proxy,
+			Vset_:  append([]uint32(nil), vset...), // 复制切片
+			RingID: n.GetRingID(),
 		},
 	}
 
-	n.Network.Send(msg)
+	n.send(msg)
 	return true
 }
 
 // SendSetupFail 构建并发送一个 setup fail 数据包
 func (n *Node) SendSetupFail(src, dst, sender, nextHop, proxy uint32, vset []uint32) bool {
-	log.Printf("Node %d: SendSetupFail src=%d dst=%d proxy=%d nextHop=%d",
-		n.ID, src, dst, proxy, nextHop)
+	n.Logger().Info(LOG_ROUTING, "sending setup_fail", "type", "VRR_SETUP_FAIL",
+		"src", src, "dst", dst, "proxy", proxy, "next_hop", nextHop)
 
 	msg := Message{
 		Type:    VRR_SETUP_FAIL,
@@ -65,14 +69,14 @@ func (n *Node) SendSetupFail(src, dst, sender, nextHop, proxy uint32, vset []uin
 		},
 	}
 
-	n.Network.Send(msg)
+	n.send(msg)
 	return true
 }
 
 // SendTeardown 构建并发送一个 teardown 数据包
 func (n *Node) SendTeardown(pathID, endpoint uint32, vset_ []uint32, nextHop uint32) bool {
-	log.Printf("Node %d: SendTeardown pathID=%d endpoint=%d nextHop=%d",
-		n.ID, pathID, endpoint, nextHop)
+	n.Logger().Info(LOG_ROUTING, "sending teardown", "type", "VRR_TEARDOWN",
+		"pid", pathID, "ea", endpoint, "next_hop", nextHop)
 
 	msg := Message{
 		Type:    VRR_TEARDOWN,
@@ -88,16 +92,85 @@ func (n *Node) SendTeardown(pathID, endpoint uint32, vset_ []uint32, nextHop uin
 		},
 	}
 
-	n.Network.Send(msg)
+	n.send(msg)
+	return true
+}
+
+// SendPathSync 构建并发送一个 path_sync 数据包，列出本节点路由表中下一跳为 nextHop 的路径
+func (n *Node) SendPathSync(nextHop uint32, paths []PathRef) bool {
+	n.Logger().Debug(LOG_ROUTING, "sending path_sync", "type", "VRR_PATH_SYNC",
+		"next_hop", nextHop, "paths", len(paths))
+
+	msg := Message{
+		Type:    VRR_PATH_SYNC,
+		Src:     n.ID,
+		Dst:     nextHop,
+		Sender:  n.ID,
+		NextHop: nextHop,
+
+		Payload: &PathSyncPayload{
+			Paths: append([]PathRef(nil), paths...), // 复制切片
+		},
+	}
+
+	n.send(msg)
+	return true
+}
+
+// SendVsetShare 构建并发送一个 vset_share 数据包，把本地 vset 告诉刚经由 nextHop 加入 vset 的 dst
+func (n *Node) SendVsetShare(dst, nextHop uint32, vset []uint32) bool {
+	n.Logger().Debug(LOG_VSET, "sending vset_share", "type", "VRR_VSET_SHARE",
+		"dst", dst, "next_hop", nextHop, "vset", vset)
+
+	msg := Message{
+		Type:    VRR_VSET_SHARE,
+		Src:     n.ID,
+		Dst:     dst,
+		Sender:  n.ID,
+		NextHop: nextHop,
+
+		Payload: &VsetSharePayload{
+			Vset_: append([]uint32(nil), vset...), // 复制切片
+		},
+	}
+
+	n.send(msg)
 	return true
 }
 
 // SendHello 构建并发送一个 hello 数据包（广播）
 func (n *Node) SendHello() bool {
+	return n.sendHello(false)
+}
+
+// sendHello 构建并发送 hello 数据包；leaving 为 true 时是 Leave 发出的最后一个 HELLO，
+// 不通告自举候选者，避免离开的节点把自己作为候选者并推进序号
+func (n *Node) sendHello(leaving bool) bool {
 	// log.Printf("Node %d: SendHelloPkt (broadcasting)", n.ID)
 
 	// 更新 psetState 快照
 	n.PsetStateManager.Update()
+	linkActive, linkNotActive, pending := n.PsetStateManager.Snapshot()
+
+	// 活跃状态与环标识由其他 goroutine 修改，一次读取保证二者一致
+	n.lock.RLock()
+	active, ringID := n.Active, n.RingID
+	n.lock.RUnlock()
+	// 只有活跃节点通告自己所在虚拟环的标识
+	if !active {
+		ringID = 0
+	}
+
+	// 选举模式下，非活跃节点在 HELLO 中通告自己认可的候选者
+	var candidate, candidateSeq uint32
+	var candidateHops uint8
+	if !active && !leaving && n.Bootstrap != BOOTSTRAP_TIMEOUT {
+		candidate, candidateHops, candidateSeq = n.Candidate()
+		if candidate == n.ID {
+			// 自己是候选者时每个 HELLO 周期推进序号，邻居据此判断候选者仍然在线
+			candidateSeq = atomic.AddUint32(&n.electionSeq, 1)
+		}
+	}
 
 	msg := Message{
 		Type:    VRR_HELLO,
@@ -106,27 +179,42 @@ func (n *Node) SendHello() bool {
 		Sender:  n.ID,
 		NextHop: 0, // 广播，无需指定下一跳
 		Payload: &HelloPayload{
-			SenderActive:           n.Active,
-			HelloInfoLinkActive:    append([]uint32(nil), n.PsetStateManager.LinkActive...),
-			HelloInfoLinkNotActive: append([]uint32(nil), n.PsetStateManager.LinkNotActive...),
-			HelloInfoPending:       append([]uint32(nil), n.PsetStateManager.Pending...),
+			SenderActive:           active,
+			HelloInfoLinkActive:    linkActive,
+			HelloInfoLinkNotActive: linkNotActive,
+			HelloInfoPending:       pending,
+			RingID:                 ringID,
+			Candidate:              candidate,
+			CandidateHops:          candidateHops,
+			CandidateSeq:           candidateSeq,
 		},
 	}
 
-	n.Network.Send(msg)
+	n.send(msg)
 	return true
 }
 
-// SendData 发送数据消息
+// SendData 发送数据消息到目的节点的默认端口 0
 func (n *Node) SendData(dest uint32, data []byte) bool {
+	return n.SendDataPort(dest, 0, data)
+}
+
+// SendDataPort 发送数据消息到目的节点的指定端口
+func (n *Node) SendDataPort(dest uint32, port uint16, data []byte) bool {
+	return n.sendData(dest, port, data, 0)
+}
+
+// sendData 发送数据消息，traceID 为 0 表示不追踪
+func (n *Node) sendData(dest uint32, port uint16, data []byte, traceID uint64) bool {
 	// 查找路由
 	nextHop := n.RoutingTable.GetNext(dest)
 	if nextHop == 0 {
-		log.Printf("Node %d: No route to destination %d", n.ID, dest)
+		n.Logger().Warn(LOG_ROUTING, "no route to destination", "dst", dest)
+		n.countDrop(Message{Type: VRR_DATA, Src: n.ID, Dst: dest, TraceID: traceID}, DROP_NO_ROUTE)
 		return false
 	}
 
-	log.Printf("Node %d: SendData to dest=%d via nextHop=%d", n.ID, dest, nextHop)
+	n.Logger().Debug(LOG_ROUTING, "sending data", "dst", dest, "next_hop", nextHop)
 
 	msg := Message{
 		Type:    VRR_DATA,
@@ -134,21 +222,20 @@ func (n *Node) SendData(dest uint32, data []byte) bool {
 		Dst:     dest,
 		Sender:  n.ID,
 		NextHop: nextHop,
+		TraceID: traceID,
 		Payload: &DataPayload{
+			Port: port,
 			Data: append([]byte(nil), data...),
 		},
 	}
 
-	n.Network.Send(msg)
+	n.send(msg)
 	return true
 }
 
 // NewPid 作为 Node 的方法生成一个随机的 32 位路径 ID
 // 确保生成的 ID 不与当前节点的 vset 中的任何节点 ID 冲突
 func (n *Node) NewPid() uint32 {
-	rngMutex.Lock()
-	defer rngMutex.Unlock()
-
 	// 获取当前节点的所有 vset 节点 ID
 	vsetNodes := n.VsetManager.GetAll()
 
@@ -166,7 +253,7 @@ func (n *Node) NewPid() uint32 {
 	maxRetries := 100 // 防止无限循环
 
 	for i := 0; i < maxRetries; i++ {
-		pathID = rng.Uint32()
+		pathID = n.randUint32()
 
 		// 避免使用特殊值
 		if pathID == 0 || pathID == 0xFFFFFFFF {
@@ -179,6 +266,6 @@ func (n *Node) NewPid() uint32 {
 		}
 	}
 
-	log.Printf("Node %d: Generated new path ID: %d (0x%x)", n.ID, pathID, pathID)
+	n.Logger().Debug(LOG_ROUTING, "generated new path ID", "pid", pathID)
 	return pathID
 }
@@ -1,12 +1,15 @@
 package vrr
 
 import (
+	"math/rand"
 	"sync"
+	"time"
 )
 
 const (
 	VRR_ID_LEN = 4
 
+	// 以下为节点参数的默认值，可通过 Config 为每个节点单独设置
 	VRR_VSET_SIZE = 4
 
 	VRR_FAIL_TIMEOUT = 8 /* multiple of delay to mark
@@ -14,6 +17,20 @@ const (
 	VRR_ACTIVE_TIMEOUT = 8 /* multiple of delay to activate
 	* this node without virtual
 	* neighbors */
+
+	// 同一目标的 setup_req 的最小重发间隔，防止 setup_fail 携带的 vset' 反复触发新请求形成风暴
+	VRR_SETUP_RETRY = 500 * time.Millisecond
+	// 同一目标的 setup_req 按 VRR_SETUP_RETRY 间隔发送的次数，请求或回复丢失时由 checkVsetPaths 重发，
+	// 之后每次重发的间隔加倍，共加倍 VRR_SETUP_BACKOFF 次后放弃，目标崩溃或不可达时不会无限重发
+	VRR_SETUP_TRIES   = 3
+	VRR_SETUP_BACKOFF = 6
+	// 本节点发起的 setup 的 pid 与已有路径 <pid, me> 重复时，换新 pid 重试的次数
+	VRR_PID_RETRIES = 3
+	// 每隔多少个 HELLO 周期与物理邻居核对一次经过它的路由条目，清除 teardown 丢失后遗留的半条路径
+	VRR_PATH_SYNC_PERIOD = 4
+
+	// 一个 path_sync 消息中最多列出的路径个数，更多的路径分成多个消息发送
+	VRR_MAX_SYNC_PATHS = 4096
 )
 
 type Networker interface {
@@ -33,6 +50,7 @@ type Message struct {
 	Dst     uint32 // 消息的最终逻辑目的地ID, 广播为0
 	NextHop uint32 // 下一跳节点ID（用于转发）
 	Sender  uint32 // 实际发送者节点ID（上一跳）
+	TraceID uint64 // 追踪ID，0 表示不追踪；转发时保持不变
 
 	Payload Payload // 消息的具体内容
 }
@@ -43,6 +61,8 @@ func (*SetupPayload) isPayload()     {}
 func (*SetupFailPayload) isPayload() {}
 func (*TeardownPayload) isPayload()  {}
 func (*DataPayload) isPayload()      {}
+func (*PathSyncPayload) isPayload()  {}
+func (*VsetSharePayload) isPayload() {}
 
 // HelloPayload 对应 HELLO 消息
 type HelloPayload struct {
@@ -50,6 +70,15 @@ type HelloPayload struct {
 	HelloInfoLinkActive    []uint32
 	HelloInfoLinkNotActive []uint32
 	HelloInfoPending       []uint32
+
+	// 活跃发送者所在虚拟环的标识，0 表示未知，用于检测并合并独立的虚拟环
+	RingID uint32
+
+	// 选举自举模式下，非活跃发送者认可的候选者及其跳数，0 表示不参与选举；
+	// CandidateSeq 是候选者自己每个 HELLO 周期加一的序号，转发者原样传递，用于让离开的候选者过期
+	Candidate     uint32
+	CandidateHops uint8
+	CandidateSeq  uint32
 }
 
 // SetupReqPayload 对应 SETUP_REQ 消息
@@ -59,9 +88,10 @@ type SetupReqPayload struct {
 }
 
 type SetupPayload struct {
-	Pid   uint32
-	Proxy uint32
-	Vset_ []uint32
+	Pid    uint32
+	Proxy  uint32
+	Vset_  []uint32
+	RingID uint32 // 发起者所在虚拟环的标识
 }
 
 type SetupFailPayload struct {
@@ -70,15 +100,50 @@ type SetupFailPayload struct {
 }
 
 type TeardownPayload struct {
-	Pid      uint32
-	Endpoint uint32
+	Pid      uint32 // 与 Endpoint 一起构成路径标识 <pid, ea>
+	Endpoint uint32 // 路径的端点 ea
 	Vset_    []uint32
 }
 
 type DataPayload struct {
+	Port uint16 // 目的端口，用于在目的节点上分发给对应的上层应用
+	Hops uint8  // 已经经过的转发次数（中间节点每转发一次加一）
 	Data []byte
 }
 
+// PathRef 是路径标识 <pid, ea>
+type PathRef struct {
+	Pid      uint32
+	Endpoint uint32
+}
+
+// PathSyncPayload 对应 PATH_SYNC 消息：发送者路由表中下一跳为接收者的路径
+type PathSyncPayload struct {
+	Paths []PathRef
+}
+
+// VsetSharePayload 对应 VSET_SHARE 消息：发送者的 vset，接收者尝试添加其中的节点
+type VsetSharePayload struct {
+	Vset_ []uint32
+}
+
+// setupAttempt 记录向某个目标发送 setup_req 的情况
+type setupAttempt struct {
+	last  time.Time // 最近一次发送的时间
+	tries int       // 已发送的次数
+}
+
+// interval 返回距离上一次发送至少要等待的时间：前 SetupTries 次为 SetupRetry，之后逐次加倍
+func (a *setupAttempt) interval(config Config) time.Duration {
+	backoff := min(max(a.tries-config.SetupTries+1, 0), VRR_SETUP_BACKOFF)
+	return config.SetupRetry << backoff
+}
+
+// exhausted 判断是否已经发送了 SetupTries+VRR_SETUP_BACKOFF 次，之后不再重发
+func (a *setupAttempt) exhausted(config Config) bool {
+	return a.tries >= config.SetupTries+VRR_SETUP_BACKOFF
+}
+
 // Node 模拟一个 VRR 节点
 type Node struct {
 	ID        uint32       // 节点的唯一标识符
@@ -89,7 +154,11 @@ type Node struct {
 	lock   sync.RWMutex // 保护节点内部状态（如active）的读写锁
 	Active bool         // 节点是否在虚拟集合和路由中 receive setup会设置为active=true
 
-	Timeout int // 活跃状态超时计数器，对应 vrr_node.Timeout
+	Timeout   int    // 活跃状态超时计数器，对应 vrr_node.Timeout
+	Bootstrap uint8  // 自举模式，BOOTSTRAP_TIMEOUT 或基于节点ID的选举模式
+	RingID    uint32 // 节点所在虚拟环的标识（自举节点的ID），0 表示未知
+
+	config Config // 协议参数
 
 	// --- 状态管理器 ---
 	PsetManager      *PsetManager         // 物理邻居集管理器
@@ -97,6 +166,35 @@ type Node struct {
 	RoutingTable     *RoutingTableManager // 路由表管理器
 	PsetStateManager *PsetStateManager    // 物理邻居集管理器
 
+	// --- 上层应用交付 ---
+	handlers    map[uint16]DataHandler // 按端口注册的数据处理函数
+	handlerLock sync.RWMutex           // 保护 handlers 的读写锁
+	RecvChan    chan Delivery          // 未注册处理函数的数据包交付通道，供 Recv 读取
+
+	// --- setup_req 限速 ---
+	setupSent map[uint32]*setupAttempt // 每个目标的 setup_req 发送记录
+	setupLock sync.Mutex
+
+	// --- 选举 ---
+	electionSeq uint32 // 本节点作为候选者通告的序号，原子访问
+
+	// --- 路由核对 ---
+	pathSyncTicks int // 距离上一次路由核对经过的 HELLO 周期数，只在 HELLO 周期中访问
+
+	// --- 时钟、随机数与日志 ---
+	logger     *Logger    // 带 node 属性的日志
+	clock      Clock      // 时间源，默认为 RealClock
+	helloTimer Timer      // 虚拟时间模式下的 HELLO 定时器
+	rng        *rand.Rand // 节点独立的随机数生成器，可通过 SetSeed 固定种子
+	rngLock    sync.Mutex // 保护 rng
+
+	// --- 指标与追踪 ---
+	metrics *nodeMetrics // 收发、转发、丢弃和 setup 计数器
+	tracer  Tracer       // 带有 TraceID 的消息的事件钩子，nil 表示不追踪
+
+	// hookLock 保护 logger、tracer 以及 config 中的 Logger/Tracer，它们可以在节点运行时替换
+	hookLock sync.RWMutex
+
 	// 并发控制
 	StopChan chan struct{} // 用于通知goroutine停止的信号通道
 	stopOnce sync.Once     // 确保 StopChan 只关闭一次
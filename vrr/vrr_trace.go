@@ -0,0 +1,84 @@
+package vrr
+
+import "time"
+
+// 追踪事件的种类
+const (
+	TRACE_SEND    = "send"    // 网络把消息从 Node 发往下一跳 Peer
+	TRACE_RECEIVE = "receive" // Node 收到上一跳 Peer 发来的消息
+	TRACE_DROP    = "drop"    // 消息在 Node 被丢弃，原因见 Reason(DROP_*)
+	TRACE_DELIVER = "deliver" // 数据包在目的节点 Node 交给上层应用
+)
+
+// TraceEvent 是带有 TraceID 的消息经过的一个事件
+type TraceEvent struct {
+	TraceID uint64
+	Kind    string    // TRACE_*
+	Time    time.Time // 事件发生时节点或网络的时钟时间
+	Node    uint32    // 事件发生的节点
+	Peer    uint32    // TRACE_SEND 时为下一跳，TRACE_RECEIVE 时为上一跳，其余为 0
+	Type    uint8     // 消息类型
+	Src     uint32
+	Dst     uint32
+	Reason  string // TRACE_DROP 的原因
+}
+
+// Tracer 接收追踪事件。节点和网络在处理 TraceID 非 0 的消息时调用它，
+// 实现需要能被多个 goroutine 并发调用
+type Tracer interface {
+	TraceEvent(ev TraceEvent)
+}
+
+// NewTraceEvent 根据消息创建追踪事件
+func NewTraceEvent(kind string, at time.Time, node, peer uint32, msg Message) TraceEvent {
+	return TraceEvent{
+		TraceID: msg.TraceID,
+		Kind:    kind,
+		Time:    at,
+		Node:    node,
+		Peer:    peer,
+		Type:    msg.Type,
+		Src:     msg.Src,
+		Dst:     msg.Dst,
+	}
+}
+
+// SetTracer 设置节点的追踪钩子，nil 表示不追踪。可以在节点运行时调用
+func (n *Node) SetTracer(tracer Tracer) {
+	n.hookLock.Lock()
+	defer n.hookLock.Unlock()
+	n.config.Tracer = tracer
+	n.tracer = tracer
+}
+
+// trace 在消息带有 TraceID 且设置了追踪钩子时记录节点上的事件
+func (n *Node) trace(kind string, msg Message, peer uint32, reason string) {
+	if msg.TraceID == 0 {
+		return
+	}
+	n.hookLock.RLock()
+	tracer := n.tracer
+	n.hookLock.RUnlock()
+	if tracer == nil {
+		return
+	}
+	ev := NewTraceEvent(kind, n.clock.Now(), n.ID, peer, msg)
+	ev.Reason = reason
+	tracer.TraceEvent(ev)
+}
+
+// newTraceID 生成非 0 的追踪ID
+func (n *Node) newTraceID() uint64 {
+	for {
+		if id := uint64(n.randUint32())<<32 | uint64(n.randUint32()); id != 0 {
+			return id
+		}
+	}
+}
+
+// SendDataTraced 与 SendDataPort 相同，但为数据包分配追踪ID并返回，
+// 之后可以用该ID从 Tracer 中查询逐跳路径、每跳延迟和丢弃位置
+func (n *Node) SendDataTraced(dest uint32, port uint16, data []byte) (uint64, bool) {
+	traceID := n.newTraceID()
+	return traceID, n.sendData(dest, port, data, traceID)
+}
@@ -1,45 +1,54 @@
 package vrr
 
 import (
-	"log"
 	"math/rand"
-	"sync"
-	"time"
 )
 
-// 全局随机数生成器（线程安全）
-var (
-	rng      = rand.New(rand.NewSource(time.Now().UnixNano()))
-	rngMutex sync.Mutex
-)
+// randInt63n 使用节点的随机数生成器返回 [0, n) 内的随机数
+func (n *Node) randInt63n(max int64) int64 {
+	n.rngLock.Lock()
+	defer n.rngLock.Unlock()
+	return n.rng.Int63n(max)
+}
+
+// randIntn 使用节点的随机数生成器返回 [0, n) 内的随机数
+func (n *Node) randIntn(max int) int {
+	n.rngLock.Lock()
+	defer n.rngLock.Unlock()
+	return n.rng.Intn(max)
+}
+
+// randUint32 使用节点的随机数生成器返回一个随机的 32 位数
+func (n *Node) randUint32() uint32 {
+	n.rngLock.Lock()
+	defer n.rngLock.Unlock()
+	return n.rng.Uint32()
+}
 
 // generate random id,return []byte
 func GenerateRandomBytes(size int) []byte {
 	randomBytes := make([]byte, size)
 	_, err := rand.Read(randomBytes)
 	if err != nil {
-		log.Printf("vrr: generateRandomBytes failed: %v", err)
+		defaultLogger.Warn(LOG_NODE, "generate random bytes failed", "err", err)
 		return nil
 	}
 	return randomBytes
 }
 
-// get_diff 计算两个无符号整数之间的绝对差值。
-func get_diff(x, y uint32) uint32 {
-	i := x - y //无符号值，不会出现负值
-	j := y - x
-	if i < j {
-		return i
+// ContainsID 判断ID列表中是否包含指定的ID
+func ContainsID(ids []uint32, id uint32) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
 	}
-	return j
+	return false
 }
 
 // VrrNewPathID 作为 Node 的方法生成一个随机的 32 位路径 ID
 // 确保生成的 ID 不与当前节点的 vset 中的任何节点 ID 冲突
 func (n *Node) VrrNewPathID() uint32 {
-	rngMutex.Lock()
-	defer rngMutex.Unlock()
-
 	// 获取当前节点的所有 vset 节点 ID
 	vsetNodes := n.VsetManager.GetAll()
 
@@ -57,7 +66,7 @@ func (n *Node) VrrNewPathID() uint32 {
 	maxRetries := 100 // 防止无限循环
 
 	for i := 0; i < maxRetries; i++ {
-		pathID = rng.Uint32()
+		pathID = n.randUint32()
 
 		// 避免使用特殊值
 		if pathID == 0 || pathID == 0xFFFFFFFF {
@@ -70,7 +79,7 @@ func (n *Node) VrrNewPathID() uint32 {
 		}
 	}
 
-	log.Printf("Node %d: Generated new path ID: %d (0x%x)", n.ID, pathID, pathID)
+	n.Logger().Debug(LOG_ROUTING, "generated new path ID", "pid", pathID)
 	return pathID
 }
 
@@ -89,6 +98,10 @@ func GetMessageTypeString(msgType uint8) string {
 		return "VRR_TEARDOWN"
 	case VRR_DATA:
 		return "VRR_DATA"
+	case VRR_PATH_SYNC:
+		return "VRR_PATH_SYNC"
+	case VRR_VSET_SHARE:
+		return "VRR_VSET_SHARE"
 	default:
 		return "UNKNOWN"
 	}
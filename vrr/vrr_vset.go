@@ -3,17 +3,13 @@ package vrr
 import (
 	"container/list"
 	"fmt"
-	"log"
-	"math"
 	"sort"
 	"sync"
 )
 
 // Virtual Set Setup
 type VsetNode struct {
-	NodeId    uint32
-	DiffLeft  int //ME 向左多少距离能到 vset_node
-	DiffRight int //ME 向右多少距离能到 vset_node
+	NodeId uint32
 }
 
 // VsetManager 封装了单个节点的虚拟邻居集状态和操作逻辑。
@@ -31,70 +27,55 @@ func NewVsetManager(owner *Node) *VsetManager {
 	}
 }
 
+// reset 清空虚拟邻居集
+func (vm *VsetManager) reset() {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+	vm.vsetList.Init()
+}
+
 // insertNode 将一个新节点插入到虚拟邻居集中。
 // 这是一个内部方法，应在持有写锁的情况下调用。
 func (vm *VsetManager) insertNode(nodeId uint32) {
-	// 使用 vm.ownerNode.ID 替代全局的 ME
-	meID := vm.ownerNode.ID
+	// 将新条目添加到此管理器的 vsetList 中
+	vm.vsetList.PushBack(&VsetNode{NodeId: nodeId})
 
-	// 创建一个新的 vset_list 条目
-	tmp := &VsetNode{
-		NodeId: nodeId,
-		// 根据节点与所有者的关系计算 diff
-		DiffLeft:  int(math.MaxUint32 - get_diff(nodeId, meID)),
-		DiffRight: int(get_diff(nodeId, meID)),
-	}
+	// log.Printf("Node %d: VSet inserted neighbor %d", vm.ownerNode.ID, nodeId)
+}
 
-	if nodeId < meID {
-		tmp.DiffLeft = int(get_diff(nodeId, meID))
-		tmp.DiffRight = int(math.MaxUint32 - get_diff(nodeId, meID))
+// ids 返回 vsetList 中所有节点的ID。
+// 这是一个内部方法，应在持有锁的情况下调用。
+func (vm *VsetManager) ids() []uint32 {
+	ids := make([]uint32, 0, vm.vsetList.Len())
+	for e := vm.vsetList.Front(); e != nil; e = e.Next() {
+		ids = append(ids, e.Value.(*VsetNode).NodeId)
 	}
-
-	// 将新条目添加到此管理器的 vsetList 中
-	vm.vsetList.PushBack(tmp)
-
-	// log.Printf("Node %d: VSet inserted neighbor %d", meID, tmp.NodeId)
+	return ids
 }
 
-// bump 检查VSet大小，如果超出限制则“挤出”一个节点。
+// bump 检查VSet大小，如果超出限制则“挤出”一个节点：
+// 保留环上顺时针和逆时针方向各 Config.VsetSize/2 个最近的节点，移除其余的节点。
 // 这是一个内部方法，应在持有写锁的情况下调用。
 func (vm *VsetManager) bump() (uint32, bool) {
-	radius := VRR_VSET_SIZE / 2
-	vsetSize := vm.vsetList.Len()
-
 	// 如果VSet大小未超限，则无需操作
-	if vsetSize <= VRR_VSET_SIZE {
+	if vm.vsetList.Len() <= vm.ownerNode.config.VsetSize {
 		return 0, false
 	}
 
-	left := make([]int, vsetSize)
-	right := make([]int, vsetSize)
-	i := 0
-
-	// 填充左右差异数组
-	for e := vm.vsetList.Front(); e != nil; e = e.Next() {
-		tmp := e.Value.(*VsetNode)
-		left[i] = tmp.DiffLeft
-		right[i] = tmp.DiffRight
-		i++
-	}
-
-	// 排序
-	sort.Ints(left)
-	sort.Ints(right)
+	keep := RingNeighbors(vm.ownerNode.ID, vm.ids(), vm.ownerNode.config.VsetSize)
 
 	// 找到并移除被“挤出”的节点
 	for e := vm.vsetList.Front(); e != nil; e = e.Next() {
 		tmp := e.Value.(*VsetNode)
-		if tmp.DiffLeft == left[radius] && tmp.DiffRight == right[radius] {
+		if !ContainsID(keep, tmp.NodeId) {
 			removeNodeID := tmp.NodeId
 			vm.vsetList.Remove(e) // 从列表中移除
-			log.Printf("Node %d: VSet bumped neighbor %d", vm.ownerNode.ID, removeNodeID)
+			vm.ownerNode.Logger().Info(LOG_VSET, "vset neighbor bumped", "peer", removeNodeID)
 			return removeNodeID, true
 		}
 	}
 
-	log.Printf("Node %d: VSet bump algorithm failed!", vm.ownerNode.ID)
+	vm.ownerNode.Logger().Warn(LOG_VSET, "vset bump algorithm failed")
 	return 0, false
 }
 
@@ -117,17 +98,25 @@ func (vm *VsetManager) Add(node uint32) (uint32, bool) {
 	return vm.bump()
 }
 
-// GetAll 获取VSet中所有节点的ID。
-func (vm *VsetManager) GetAll() []uint32 {
-	vm.lock.RLock() // 获取读锁
+// Contains 检查虚拟邻居集中是否存在指定的节点。
+func (vm *VsetManager) Contains(node uint32) bool {
+	vm.lock.RLock()
 	defer vm.lock.RUnlock()
 
-	vsetAll := make([]uint32, 0, vm.vsetList.Len())
 	for e := vm.vsetList.Front(); e != nil; e = e.Next() {
-		vsetAll = append(vsetAll, e.Value.(*VsetNode).NodeId)
+		if e.Value.(*VsetNode).NodeId == node {
+			return true
+		}
 	}
+	return false
+}
 
-	return vsetAll
+// GetAll 获取VSet中所有节点的ID。
+func (vm *VsetManager) GetAll() []uint32 {
+	vm.lock.RLock() // 获取读锁
+	defer vm.lock.RUnlock()
+
+	return vm.ids()
 }
 
 // -------------------VRR 论文方法实现
@@ -144,14 +133,9 @@ func (vm *VsetManager) ShouldAdd(node uint32) bool {
 
 	meID := vm.ownerNode.ID
 
-	// 获取新节点的diffLeft和diffRight
-	diffLeft := int(get_diff(node, meID))
-	diffRight := int(get_diff(node, meID))
-	if node > meID {
-		diffLeft = math.MaxUint32 - diffLeft
-	}
-	if node < meID {
-		diffRight = math.MaxUint32 - diffRight
+	// 自己和空ID永远不属于vset（合并时收到的 vset' 可能包含自己）
+	if node == meID || node == 0 {
+		return false
 	}
 
 	// 检查节点是否已存在
@@ -161,35 +145,13 @@ func (vm *VsetManager) ShouldAdd(node uint32) bool {
 		}
 	}
 
-	vsetSize := vm.vsetList.Len()
 	// 如果VSet未满，直接添加
-	if vsetSize < VRR_VSET_SIZE {
+	if vm.vsetList.Len() < vm.ownerNode.config.VsetSize {
 		return true // VSet未满，可以直接添加
 	}
 
-	radius := VRR_VSET_SIZE / 2
-	left := make([]int, vsetSize)
-	right := make([]int, vsetSize)
-	i := 0
-
-	for e := vm.vsetList.Front(); e != nil; e = e.Next() {
-		tmp := e.Value.(*VsetNode)
-		left[i] = tmp.DiffLeft
-		right[i] = tmp.DiffRight
-		i++
-	}
-
-	sort.Ints(left)
-	sort.Ints(right)
-
-	// 检查新节点是否比现有节点“更近”
-	for i = 0; i < radius; i++ {
-		if left[i] > diffLeft || right[i] > diffRight {
-			return true
-		}
-	}
-
-	return false
+	// 检查新节点是否是 vset ∪ {node} 中环上顺时针或逆时针方向最近的节点之一
+	return ContainsID(RingNeighbors(meID, append(vm.ids(), node), vm.ownerNode.config.VsetSize), node)
 }
 
 /*
@@ -204,7 +166,7 @@ func (vm *VsetManager) Remove(node uint32) bool {
 	for e := vm.vsetList.Front(); e != nil; e = e.Next() {
 		if e.Value.(*VsetNode).NodeId == node {
 			vm.vsetList.Remove(e)
-			log.Printf("Node %d: VSet removed neighbor %d", vm.ownerNode.ID, node)
+			vm.ownerNode.Logger().Info(LOG_VSET, "vset neighbor removed", "peer", node)
 			return true // 成功移除
 		}
 	}
@@ -232,7 +194,7 @@ func (n *Node) Add(vset []uint32, src uint32, vset_ []uint32) bool {
 
 	// 对 vset_ 中的每个节点，检查是否应该添加，如果应该添加，则选择一个代理并发送 setup_req
 	for _, id := range vset_ {
-		if n.VsetManager.ShouldAdd(id) {
+		if n.VsetManager.ShouldAdd(id) && n.allowSetupReq(id) {
 			proxy, ok := n.PsetManager.GetProxy()
 			if ok {
 				n.SendSetupReq(me, id, me, proxy, proxy, vset)
@@ -243,11 +205,11 @@ func (n *Node) Add(vset []uint32, src uint32, vset_ []uint32) bool {
 	// AddMsgSrcToLocalVset(src,vset_)
 	// 如果 src 不为零且应该添加到 vset 中
 	if src != 0 && n.VsetManager.ShouldAdd(src) {
-		log.Printf("Node %d: Added src Node %d to vset", n.ID, src)
+		n.Logger().Info(LOG_VSET, "vset neighbor added", "peer", src)
 		removedNodeId, _ := n.VsetManager.Add(src)
 		if removedNodeId != 0 {
 			n.RoutingTable.TearDownPathTo(removedNodeId)
-			log.Printf("Node %d: Should tear down path to removed node %d", n.ID, removedNodeId)
+			n.Logger().Info(LOG_VSET, "tearing down paths to bumped neighbor", "peer", removedNodeId)
 		}
 
 		return true
@@ -257,6 +219,60 @@ func (n *Node) Add(vset []uint32, src uint32, vset_ []uint32) bool {
 	return false
 }
 
+// allowSetupReq 判断现在是否可以向 id 发送 setup_req，允许时记录发送时间
+func (n *Node) allowSetupReq(id uint32) bool {
+	n.setupLock.Lock()
+	defer n.setupLock.Unlock()
+	now := n.clock.Now()
+	a, ok := n.setupSent[id]
+	if !ok {
+		a = &setupAttempt{}
+		n.setupSent[id] = a
+	} else if now.Sub(a.last) < a.interval(n.config) {
+		return false
+	}
+	a.last = now
+	a.tries++
+	return true
+}
+
+// retrySetupReqs 重发尚未得到结果的 setup_req：目标仍不在 vset 中且仍应加入时经由代理重发，
+// 前 Config.SetupTries 次每隔 Config.SetupRetry 一次，之后间隔逐次加倍，加倍 VRR_SETUP_BACKOFF 次后放弃。
+// 放弃的记录在最后一个间隔内仍然挡住新请求，之后 vset' 等再次提到目标时重新开始计数
+func (n *Node) retrySetupReqs() {
+	now := n.clock.Now()
+	var retry []uint32
+	n.setupLock.Lock()
+	for id, a := range n.setupSent {
+		// 先清除已经有结果的记录，退避中的旧记录不会挡住之后对同一目标的新请求
+		if n.VsetManager.Contains(id) || !n.VsetManager.ShouldAdd(id) {
+			delete(n.setupSent, id)
+			continue
+		}
+		if now.Sub(a.last) < a.interval(n.config) {
+			continue
+		}
+		if a.exhausted(n.config) {
+			n.Logger().Info(LOG_ROUTING, "giving up setup_req", "type", "VRR_SETUP_REQ", "dst", id, "tries", a.tries)
+			delete(n.setupSent, id)
+			continue
+		}
+		retry = append(retry, id)
+	}
+	n.setupLock.Unlock()
+
+	sort.Slice(retry, func(i, j int) bool { return retry[i] < retry[j] })
+	vset := n.VsetManager.GetAll()
+	for _, id := range retry {
+		proxy, ok := n.PsetManager.GetProxy()
+		if !ok || !n.allowSetupReq(id) {
+			continue
+		}
+		n.Logger().Info(LOG_ROUTING, "retrying setup_req", "type", "VRR_SETUP_REQ", "dst", id, "proxy", proxy)
+		n.SendSetupReq(n.ID, id, n.ID, proxy, proxy, vset)
+	}
+}
+
 // String 返回 VsetManager 状态的可读字符串表示形式
 func (vm *VsetManager) String() string {
 	vm.lock.RLock()